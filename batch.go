@@ -0,0 +1,257 @@
+package lg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrQueueFull is returned when an entry is dropped because a batching
+// output's queue is full
+var ErrQueueFull = errors.New("lg: output queue is full")
+
+// batchOptions configures how a batcher groups and delivers entries
+type batchOptions struct {
+	maxCount     int
+	maxBytes     int
+	maxLatency   time.Duration
+	queueSize    int
+	retries      int
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	flushTimeout time.Duration
+}
+
+var defaultBatchOptions = batchOptions{
+	maxCount:     1000,
+	maxBytes:     1 << 20,
+	maxLatency:   time.Second,
+	queueSize:    10000,
+	retries:      5,
+	minBackoff:   100 * time.Millisecond,
+	maxBackoff:   30 * time.Second,
+	flushTimeout: 5 * time.Second,
+}
+
+// retryableError is returned by a batch's send function when sending it
+// again might succeed, optionally after a delay given by the destination
+type retryableError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+// partialError is returned by a batch's send function when only some of the
+// entries in it failed. Those which might succeed if they're sent again are
+// retried, and the rest are dropped.
+type partialError struct {
+	err      error
+	retry    [][]byte
+	rejected int
+}
+
+func (e *partialError) Error() string {
+	return e.err.Error()
+}
+
+// batcher queues entries and delivers them in batches, by count, size and
+// latency, retrying failed batches with exponential backoff
+type batcher struct {
+	name    string
+	options batchOptions
+	send    func(ctx context.Context, batch [][]byte) error
+
+	mutex  sync.RWMutex
+	closed bool
+	queue  chan []byte
+
+	dropped uint64
+
+	abort chan struct{}
+	done  chan struct{}
+}
+
+func newBatcher(
+	name string,
+	options batchOptions,
+	send func(ctx context.Context, batch [][]byte) error,
+) *batcher {
+	b := &batcher{
+		name:    name,
+		options: options,
+		send:    send,
+		queue:   make(chan []byte, options.queueSize),
+		abort:   make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go b.run()
+
+	return b
+}
+
+// add queues a copy of entry, without its trailing new line
+func (b *batcher) add(entry []byte) error {
+	entry = append([]byte(nil), trimNewLine(entry)...)
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if b.closed {
+		return os.ErrClosed
+	}
+
+	select {
+	case b.queue <- entry:
+		return nil
+	default:
+		atomic.AddUint64(&b.dropped, 1)
+		return ErrQueueFull
+	}
+}
+
+// close delivers any queued entries, waiting at most the flush timeout
+func (b *batcher) close() error {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.queue)
+	b.mutex.Unlock()
+
+	select {
+	case <-b.done:
+		return nil
+	case <-time.After(b.options.flushTimeout):
+		close(b.abort)
+		<-b.done
+		return fmt.Errorf("lg: timed out sending queued entries to %s", b.name)
+	}
+}
+
+func (b *batcher) run() {
+	defer close(b.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-b.abort:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var (
+		batch [][]byte
+		size  int
+		timer *time.Timer
+		due   <-chan time.Time
+	)
+
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, due = nil, nil
+		}
+		if len(batch) > 0 {
+			b.deliver(ctx, batch)
+		}
+		batch, size = nil, 0
+	}
+
+	for {
+		select {
+		case entry, ok := <-b.queue:
+			if !ok {
+				flush()
+				return
+			}
+
+			if len(batch) > 0 && size+len(entry) > b.options.maxBytes {
+				flush()
+			}
+
+			batch = append(batch, entry)
+			size += len(entry)
+			if timer == nil {
+				timer = time.NewTimer(b.options.maxLatency)
+				due = timer.C
+			}
+
+			if len(batch) >= b.options.maxCount || size >= b.options.maxBytes {
+				flush()
+			}
+
+		case <-due:
+			timer, due = nil, nil
+			flush()
+		}
+	}
+}
+
+// deliver sends batch, retrying it while the send function reports that it
+// might succeed, and drops it once it's out of retries
+func (b *batcher) deliver(ctx context.Context, batch [][]byte) {
+	backoff := b.options.minBackoff
+	for attempt := 0; ; attempt++ {
+		err := b.send(ctx, batch)
+		if err == nil {
+			return
+		}
+
+		var delay time.Duration
+		switch e := err.(type) {
+		case *retryableError:
+			delay = e.after
+		case *partialError:
+			if e.rejected > 0 {
+				b.drop(e.rejected, e.err)
+			}
+			if len(e.retry) == 0 {
+				return
+			}
+			batch = e.retry
+		default:
+			b.drop(len(batch), err)
+			return
+		}
+
+		if attempt >= b.options.retries {
+			b.drop(len(batch), err)
+			return
+		}
+
+		if delay == 0 {
+			delay = backoff
+			if backoff *= 2; backoff > b.options.maxBackoff {
+				backoff = b.options.maxBackoff
+			}
+		} else if delay > b.options.maxBackoff {
+			delay = b.options.maxBackoff
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			b.drop(len(batch), err)
+			return
+		}
+	}
+}
+
+// drop counts entries which couldn't be delivered, and reports them to
+// stderr, as they can't be reported to the destination itself
+func (b *batcher) drop(n int, err error) {
+	atomic.AddUint64(&b.dropped, uint64(n))
+	fmt.Fprintf(os.Stderr, "lg: dropped %d entries for %s: %v\n", n, b.name, err)
+}
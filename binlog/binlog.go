@@ -0,0 +1,154 @@
+// Package binlog reads entries written in lg's CBOR format back into
+// lg.Entry values.
+//
+// Example:
+//
+//   dec := binlog.NewDecoder(f)
+//   for {
+//     entry, err := dec.Decode()
+//     if err == io.EOF {
+//       break
+//     }
+//     ...
+//   }
+package binlog
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/autopilothq/lg"
+	"github.com/autopilothq/lg/encoding/cbor"
+)
+
+// Decoder reads entries from a stream written in the CBOR format
+type Decoder struct {
+	dec *cbor.Decoder
+}
+
+// NewDecoder returns a new Decoder which reads from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		dec: cbor.NewDecoder(r),
+	}
+}
+
+// Decode reads the next entry. It returns io.EOF if there are no more
+// entries, and io.ErrUnexpectedEOF if the stream ends part way through one.
+// Keys that it doesn't know are skipped.
+func (d *Decoder) Decode() (*lg.Entry, error) {
+	pairs, err := d.dec.ReadMapLen()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &lg.Entry{}
+	for i := 0; i < pairs; i++ {
+		key, err := d.dec.ReadString()
+		if err != nil {
+			return nil, err
+		}
+
+		if key == "f" {
+			if entry.Fields, err = d.decodeFields(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		val, err := d.dec.Decode()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+
+		if err = setField(entry, key, val); err != nil {
+			return nil, err
+		}
+	}
+
+	return entry, nil
+}
+
+// decodeFields reads the fields of an entry, keeping them in their order
+func (d *Decoder) decodeFields() (lg.Fields, error) {
+	pairs, err := d.dec.ReadMapLen()
+	if err != nil {
+		return lg.Fields{}, unexpectedEOF(err)
+	}
+
+	// the number of pairs comes from the stream, so isn't trusted to size args
+	var args []interface{}
+	for i := 0; i < pairs; i++ {
+		key, err := d.dec.ReadString()
+		if err != nil {
+			return lg.Fields{}, err
+		}
+
+		val, err := d.dec.Decode()
+		if err != nil {
+			return lg.Fields{}, unexpectedEOF(err)
+		}
+
+		args = append(args, lg.F{Key: key, Val: val})
+	}
+
+	fields, _ := lg.ExtractAllFields(args)
+	return fields, nil
+}
+
+func setField(entry *lg.Entry, key string, val interface{}) error {
+	var ok bool
+
+	switch key {
+	case "t":
+		entry.Timestamp, ok = val.(time.Time)
+
+	case "l":
+		var level int64
+		level, ok = val.(int64)
+		entry.Level = lg.Level(level)
+
+	case "p":
+		entry.Prefix, ok = val.(string)
+
+	case "m":
+		entry.Message, ok = val.(string)
+
+	case "c":
+		entry.Caller, ok = decodeCaller(val)
+
+	default:
+		ok = true
+	}
+
+	if !ok {
+		return fmt.Errorf("binlog: invalid value for %q: %v", key, val)
+	}
+	return nil
+}
+
+func decodeCaller(val interface{}) (*lg.Caller, bool) {
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	caller := &lg.Caller{}
+	caller.File, _ = obj["file"].(string)
+	caller.Function, _ = obj["func"].(string)
+
+	line, _ := obj["line"].(int64)
+	caller.Line = int(line)
+
+	return caller, true
+}
+
+// unexpectedEOF reports the end of the stream part way through an entry as
+// io.ErrUnexpectedEOF
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,13 @@
+package binlog_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestBinlog(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Binlog Suite")
+}
@@ -0,0 +1,181 @@
+package binlog_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/autopilothq/lg"
+	. "github.com/autopilothq/lg/binlog"
+	"github.com/autopilothq/lg/encoding/cbor"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// output collects what's written to it
+type output struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Write(p)
+}
+
+func (o *output) Bytes() []byte {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]byte(nil), o.buf.Bytes()...)
+}
+
+func decodeAll(b []byte) []*lg.Entry {
+	dec := NewDecoder(bytes.NewReader(b))
+
+	var entries []*lg.Entry
+	for {
+		entry, err := dec.Decode()
+		if err == io.EOF {
+			return entries
+		}
+		Expect(err).NotTo(HaveOccurred())
+		entries = append(entries, entry)
+	}
+}
+
+var _ = Describe("binlog", func() {
+
+	var out *output
+
+	BeforeEach(func() {
+		out = &output{}
+		lg.RemoveOutput(os.Stdout)
+		lg.AddOutput(out, lg.CBOR())
+	})
+
+	AfterEach(func() {
+		lg.RemoveOutput(out)
+		lg.AddOutput(os.Stdout)
+	})
+
+	It("reads entries back", func() {
+		before := time.Now()
+		lg.ExtendWithPrefix("Server").Warn("careful",
+			lg.F{"user", "bob"},
+			lg.F{"id", 42},
+			lg.F{"ratio", 0.5},
+			lg.F{"took", 1500 * time.Millisecond},
+			lg.F{"tags", []string{"a", "b"}},
+			lg.Err(errors.New("it broke")),
+		)
+		lg.Info("second")
+
+		entries := decodeAll(out.Bytes())
+		Expect(entries).To(HaveLen(2))
+
+		entry := entries[0]
+		Expect(entry.Timestamp).To(BeTemporally("~", before, time.Second))
+		Expect(entry.Level).To(Equal(lg.LevelWarn))
+		Expect(entry.Prefix).To(Equal("Server"))
+		Expect(entry.Message).To(Equal("careful"))
+		Expect(entry.Caller).To(BeNil())
+		Expect(entry.Fields.All()).To(Equal([]lg.F{
+			{"user", "bob"},
+			{"id", int64(42)},
+			{"ratio", 0.5},
+			{"took", 1500 * time.Millisecond},
+			{"tags", []interface{}{"a", "b"}},
+			{"err", "it broke"},
+		}))
+
+		Expect(entries[1].Level).To(Equal(lg.LevelInfo))
+		Expect(entries[1].Prefix).To(BeEmpty())
+		Expect(entries[1].Message).To(Equal("second"))
+		Expect(entries[1].Fields.Len()).To(Equal(0))
+	})
+
+	It("keeps timestamps to the nanosecond", func() {
+		ts := time.Date(2017, 9, 15, 12, 30, 0, 123456789, time.UTC)
+
+		enc := cbor.NewEncoder()
+		enc.StartMap(3)
+		enc.AddKey("t")
+		enc.AddTime(ts)
+		enc.AddKey("l")
+		enc.AddUint64(uint64(lg.LevelDebug))
+		enc.AddKey("m")
+		enc.AddString("hi")
+
+		entries := decodeAll(enc.Bytes())
+		Expect(entries[0].Timestamp).To(Equal(ts))
+		Expect(entries[0].Level).To(Equal(lg.LevelDebug))
+	})
+
+	It("reads callers", func() {
+		lg.SetReportCaller(true)
+		defer lg.SetReportCaller(false)
+
+		lg.Info("here")
+
+		entries := decodeAll(out.Bytes())
+		Expect(entries[0].Caller).NotTo(BeNil())
+		Expect(entries[0].Caller.File).To(HaveSuffix("binlog_test.go"))
+		Expect(entries[0].Caller.Line).To(BeNumerically(">", 0))
+		Expect(entries[0].Caller.Function).To(ContainSubstring("binlog_test"))
+	})
+
+	It("skips keys it doesn't know", func() {
+		enc := cbor.NewEncoder()
+		enc.StartMap(2)
+		enc.AddKey("x")
+		enc.StartArray()
+		enc.AddInt64(1)
+		enc.EndArray()
+		enc.AddKey("m")
+		enc.AddString("hi")
+
+		entries := decodeAll(enc.Bytes())
+		Expect(entries[0].Message).To(Equal("hi"))
+	})
+
+	It("fails on truncated entries", func() {
+		lg.Info("truncated")
+
+		b := out.Bytes()
+		_, err := NewDecoder(bytes.NewReader(b[:len(b)-3])).Decode()
+		Expect(err).To(Equal(io.ErrUnexpectedEOF))
+	})
+
+	It("doesn't allocate for fields that a truncated entry claims to have", func() {
+		enc := cbor.NewEncoder()
+		enc.StartMap(1)
+		enc.AddKey("f")
+
+		// a map of 1<<26 fields, followed by none of them
+		b := append(enc.Bytes(), 0xba, 0x04, 0x00, 0x00, 0x00)
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := NewDecoder(bytes.NewReader(b)).Decode()
+		runtime.ReadMemStats(&after)
+
+		Expect(err).To(Equal(io.ErrUnexpectedEOF))
+		Expect(after.TotalAlloc - before.TotalAlloc).To(BeNumerically("<", 1<<20))
+	})
+
+	It("fails on values of the wrong type", func() {
+		enc := cbor.NewEncoder()
+		enc.StartMap(1)
+		enc.AddKey("m")
+		enc.AddInt64(1)
+
+		_, err := NewDecoder(bytes.NewReader(enc.Bytes())).Decode()
+		Expect(err).To(MatchError(ContainSubstring(`invalid value for "m"`)))
+	})
+})
@@ -0,0 +1,83 @@
+package lg
+
+import (
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Caller describes the location in the source code that an entry was logged
+// from
+type Caller struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Function string `json:"func"`
+}
+
+// String returns the caller in the short form: dir/file.go:123
+func (c *Caller) String() string {
+	return c.ShortFile() + ":" + strconv.Itoa(c.Line)
+}
+
+// ShortFile returns the file name of the caller, along with the directory it
+// is in
+func (c *Caller) ShortFile() string {
+	dir, file := filepath.Split(c.File)
+	return filepath.Join(filepath.Base(dir), file)
+}
+
+var (
+	reportCaller int32
+
+	// callerHooks is the number of hooks, i.e. outputs, which need the caller
+	callerHooks int32
+)
+
+// SetReportCaller controls whether entries record the location they were
+// logged from. It's disabled by default as it adds considerable overhead to
+// every entry, but is enabled automatically while there are outputs which
+// need it.
+func SetReportCaller(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&reportCaller, 1)
+	} else {
+		atomic.StoreInt32(&reportCaller, 0)
+	}
+}
+
+// needsCaller returns whether an output with the options renders the caller
+func (o *Options) needsCaller() bool {
+	return o.format == FormatPlainText && o.layout != nil && o.layout.caller
+}
+
+const lgPackage = "github.com/autopilothq/lg."
+
+// getCaller returns the first caller outside of lg, or nil if caller
+// reporting is disabled
+func getCaller() *Caller {
+	if atomic.LoadInt32(&reportCaller) == 0 &&
+		atomic.LoadInt32(&callerHooks) == 0 {
+		return nil
+	}
+
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, lgPackage) {
+			return &Caller{
+				File:     frame.File,
+				Line:     frame.Line,
+				Function: frame.Function,
+			}
+		}
+
+		if !more {
+			return nil
+		}
+	}
+}
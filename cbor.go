@@ -0,0 +1,115 @@
+package lg
+
+import (
+	"time"
+
+	"github.com/autopilothq/lg/encoding"
+	"github.com/autopilothq/lg/encoding/cbor"
+)
+
+// Keys of the maps that the CBOR format writes entries as
+const (
+	cborTimeKey    = "t"
+	cborLevelKey   = "l"
+	cborPrefixKey  = "p"
+	cborMessageKey = "m"
+	cborFieldsKey  = "f"
+	cborCallerKey  = "c"
+)
+
+// makeCBORError returns an entry which reports that an entry couldn't be
+// encoded, so that the stream can still be decoded
+func makeCBORError(err error) []byte {
+	enc := cbor.NewEncoder()
+	enc.StartMap(3)
+	encoding.EncodeKeyValue(enc, cborTimeKey, time.Now().UTC())
+	encoding.EncodeKeyValue(enc, cborLevelKey, uint(LevelError))
+	encoding.EncodeStringKeyValue(
+		enc, cborMessageKey, "encoding error: "+err.Error())
+	return enc.Bytes()
+}
+
+func (e *Entry) toCBOR() []byte {
+	enc := cbor.NewEncoder()
+
+	if err := e.encodeCBOR(enc); err != nil {
+		return makeCBORError(err)
+	}
+
+	return enc.Bytes()
+}
+
+func (e *Entry) encodeCBOR(enc *cbor.Encoder) (err error) {
+	pairs := 3
+	if e.Prefix != "" {
+		pairs++
+	}
+	if len(e.Fields.contents) > 0 {
+		pairs++
+	}
+	if e.Caller != nil {
+		pairs++
+	}
+
+	if err = enc.StartMap(pairs); err != nil {
+		return err
+	}
+
+	if err = encoding.EncodeKeyValue(enc, cborTimeKey, e.Timestamp); err != nil {
+		return err
+	}
+
+	if err = encoding.EncodeKeyValue(enc, cborLevelKey, uint(e.Level)); err != nil {
+		return err
+	}
+
+	if e.Prefix != "" {
+		if err = encoding.EncodeStringKeyValue(enc, cborPrefixKey, e.Prefix); err != nil {
+			return err
+		}
+	}
+
+	if err = encoding.EncodeStringKeyValue(enc, cborMessageKey, e.Message); err != nil {
+		return err
+	}
+
+	if len(e.Fields.contents) > 0 {
+		if err = enc.AddKey(cborFieldsKey); err != nil {
+			return err
+		}
+
+		if err = enc.StartMap(len(e.Fields.contents)); err != nil {
+			return err
+		}
+
+		for _, fld := range e.Fields.contents {
+			if err = encoding.EncodeKeyValue(enc, fld.Key, fld.Val); err != nil {
+				return err
+			}
+		}
+	}
+
+	if e.Caller != nil {
+		if err = enc.AddKey(cborCallerKey); err != nil {
+			return err
+		}
+
+		if err = enc.StartMap(3); err != nil {
+			return err
+		}
+
+		if err = encoding.EncodeStringKeyValue(enc, "file", e.Caller.File); err != nil {
+			return err
+		}
+
+		if err = encoding.EncodeKeyValue(enc, "line", e.Caller.Line); err != nil {
+			return err
+		}
+
+		if err = encoding.EncodeStringKeyValue(enc, "func", e.Caller.Function); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,312 @@
+package lg
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// hashChain links each entry written to an output to the one before it, by
+// adding the sequence number of the entry, the hash of the previous entry,
+// and its own hash:
+//
+//   {"seq":2,"prev":"3f9c…",<entry>,"hash":"a41b…"}
+//
+// The hash covers everything before ,"hash", so editing, removing or
+// reordering entries breaks the chain. With a key the hash is an HMAC, so the
+// chain can't be rebuilt without the key.
+type hashChain struct {
+	key []byte
+
+	mutex sync.Mutex
+	seq   uint64
+	prev  string
+}
+
+// ChainLink identifies an entry in a hash chain, so that a chain can be
+// continued by a new output
+type ChainLink struct {
+	Seq  uint64
+	Hash string
+}
+
+// HashChain makes a JSON (or ECS) output tamper-evident, by chaining each
+// entry to the one before it with a hash. If key isn't empty the hashes are
+// HMAC-SHA256 signatures, otherwise they're SHA-256 hashes. The chain can be
+// checked with VerifyChain, or the lgaudit command.
+//
+// Example:
+//
+//   f, err := lg.FileOutput("/var/log/audit.log", lg.SyncEvery(1))
+//   ...
+//   last, err := lg.LastChainLink("/var/log/audit.log")
+//   ...
+//   lg.AddOutput(f, lg.JSON(), lg.HashChain(key), lg.ContinueChain(last))
+func HashChain(key []byte) func(*Options) {
+	return func(o *Options) {
+		o.chain = &hashChain{key: append([]byte(nil), key...)}
+	}
+}
+
+// ContinueChain continues the hash chain of an output from the given link,
+// e.g. the last entry of an existing file. Without it chains start from
+// sequence number 1. It must follow HashChain.
+func ContinueChain(link ChainLink) func(*Options) {
+	return func(o *Options) {
+		if o.chain == nil {
+			panic(errors.New("ContinueChain must follow HashChain"))
+		}
+		o.chain.seq = link.Seq
+		o.chain.prev = link.Hash
+	}
+}
+
+func newChainHash(key []byte) hash.Hash {
+	if len(key) > 0 {
+		return hmac.New(sha256.New, key)
+	}
+	return sha256.New()
+}
+
+// link returns the entry as a link in the chain, along with its hash. The
+// entry must be a JSON object followed by a new line.
+func (c *hashChain) link(entry []byte) ([]byte, string, error) {
+	body := bytes.TrimRight(entry, "\n")
+	if len(body) < 2 || body[0] != '{' || body[len(body)-1] != '}' {
+		return nil, "", errors.New("hash chains require JSON entries")
+	}
+
+	line := make([]byte, 0, len(body)+160)
+	line = append(line, `{"seq":`...)
+	line = strconv.AppendUint(line, c.seq+1, 10)
+	line = append(line, `,"prev":"`...)
+	line = append(line, c.prev...)
+	line = append(line, '"')
+
+	if inner := body[1 : len(body)-1]; len(bytes.TrimSpace(inner)) > 0 {
+		line = append(line, ',')
+		line = append(line, inner...)
+	}
+
+	h := newChainHash(c.key)
+	h.Write(line)
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	line = append(line, `,"hash":"`...)
+	line = append(line, sum...)
+	line = append(line, "\"}\n"...)
+
+	return line, sum, nil
+}
+
+// makeHookFn returns a hook that writes entries to output as links in the
+// chain. Entries are linked and written one at a time, so that the order of
+// the chain matches the order of the output.
+func (c *hashChain) makeHookFn(
+	output io.Writer, options *Options, format func(*Entry) []byte,
+) hookFn {
+	return func(e *Entry) error {
+		if shouldSkip(e, options) {
+			return nil
+		}
+
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		line, sum, err := c.link(format(e))
+		if err != nil {
+			return err
+		}
+
+		// writers can report an error for an entry that was written anyway,
+		// e.g. a FileWriter which couldn't rotate, and the chain must still
+		// move on past it
+		n, err := output.Write(line)
+		if n == len(line) {
+			c.seq++
+			c.prev = sum
+		}
+
+		if err == nil && n != len(line) {
+			err = io.ErrShortWrite
+		}
+		return err
+	}
+}
+
+// ChainError describes where and how a hash chain is broken
+type ChainError struct {
+	// Line is the line number of the entry that breaks the chain
+	Line int
+
+	// Seq is the sequence number of the entry that breaks the chain
+	Seq uint64
+
+	// Reason describes how the chain is broken
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("line %d (seq %d): %s", e.Line, e.Seq, e.Reason)
+}
+
+// chainLine is a parsed link in a hash chain
+type chainLine struct {
+	seq  uint64
+	prev string
+	hash string
+
+	// hashed is the part of the line that the hash covers
+	hashed []byte
+}
+
+const (
+	chainSeqPrefix  = `{"seq":`
+	chainPrevPrefix = `,"prev":"`
+	chainHashPrefix = `,"hash":"`
+)
+
+// parseChainLine parses a link from the line. The chain keys are found by
+// position rather than by decoding the JSON, as the entry may have fields
+// with the same keys.
+func parseChainLine(line []byte) (*chainLine, bool) {
+	if !bytes.HasPrefix(line, []byte(chainSeqPrefix)) {
+		return nil, false
+	}
+	rest := line[len(chainSeqPrefix):]
+
+	end := bytes.IndexByte(rest, ',')
+	if end < 0 {
+		return nil, false
+	}
+
+	seq, err := strconv.ParseUint(string(rest[:end]), 10, 64)
+	if err != nil || !bytes.HasPrefix(rest[end:], []byte(chainPrevPrefix)) {
+		return nil, false
+	}
+	rest = rest[end+len(chainPrevPrefix):]
+
+	end = bytes.IndexByte(rest, '"')
+	if end < 0 {
+		return nil, false
+	}
+	prev := string(rest[:end])
+
+	idx := bytes.LastIndex(line, []byte(chainHashPrefix))
+	if idx < 0 || !bytes.HasSuffix(line, []byte(`"}`)) {
+		return nil, false
+	}
+	hash := line[idx+len(chainHashPrefix) : len(line)-2]
+
+	if _, err = hex.DecodeString(string(hash)); err != nil {
+		return nil, false
+	}
+
+	return &chainLine{
+		seq:    seq,
+		prev:   prev,
+		hash:   string(hash),
+		hashed: line[:idx],
+	}, true
+}
+
+// VerifyChain checks the hash chain of the entries read from r, starting
+// from the given link. If from is nil, the chain must start at sequence
+// number 1, so that entries missing from the start are detected too. It
+// returns the last link in the chain, and a *ChainError describing the first
+// entry which was edited, or which shows that entries are missing or out of
+// order.
+func VerifyChain(r io.Reader, key []byte, from *ChainLink) (ChainLink, error) {
+	var last ChainLink
+	if from != nil {
+		last = *from
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		parsed, ok := parseChainLine(bytes.TrimSpace(line))
+		if !ok {
+			return last, &ChainError{Line: lineNo, Reason: "not a chained entry"}
+		}
+
+		fail := func(reason string) (ChainLink, error) {
+			return last, &ChainError{Line: lineNo, Seq: parsed.seq, Reason: reason}
+		}
+
+		switch expected := last.Seq + 1; {
+		case parsed.seq > expected:
+			return fail(fmt.Sprintf("gap: expected seq %d", expected))
+		case parsed.seq < expected:
+			return fail(fmt.Sprintf("out of order: expected seq %d", expected))
+		}
+
+		if parsed.prev != last.Hash {
+			return fail("broken link: prev doesn't match the previous hash")
+		}
+
+		h := newChainHash(key)
+		h.Write(parsed.hashed)
+		sum := hex.EncodeToString(h.Sum(nil))
+		if !hmac.Equal([]byte(sum), []byte(parsed.hash)) {
+			return fail("hash mismatch: entry has been modified")
+		}
+
+		last = ChainLink{Seq: parsed.seq, Hash: parsed.hash}
+	}
+
+	return last, scanner.Err()
+}
+
+// LastChainLink returns the link of the last entry in the file at path, so
+// that a chain can be continued with ContinueChain. It returns the zero link
+// if the file doesn't exist or is empty. The chain isn't verified.
+func LastChainLink(path string) (ChainLink, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ChainLink{}, nil
+	}
+	if err != nil {
+		return ChainLink{}, err
+	}
+	defer f.Close()
+
+	var last []byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+			last = append(last[:0], scanner.Bytes()...)
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return ChainLink{}, err
+	}
+
+	if last == nil {
+		return ChainLink{}, nil
+	}
+
+	parsed, ok := parseChainLine(bytes.TrimSpace(last))
+	if !ok {
+		return ChainLink{}, errors.New("last entry isn't chained")
+	}
+
+	return ChainLink{Seq: parsed.seq, Hash: parsed.hash}, nil
+}
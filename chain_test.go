@@ -0,0 +1,205 @@
+package lg_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/autopilothq/lg"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("hash chained output", func() {
+
+	var tlo *TestLogOutput
+	key := []byte("secret")
+
+	BeforeEach(func() {
+		tlo = &TestLogOutput{}
+		lg.RemoveOutput(os.Stdout)
+	})
+
+	AfterEach(func() {
+		lg.RemoveOutput(tlo)
+		lg.AddOutput(os.Stdout)
+	})
+
+	lines := func() []string {
+		return strings.SplitAfter(strings.TrimSuffix(tlo.String(), "\n"), "\n")
+	}
+
+	verify := func(lines []string, key []byte) (lg.ChainLink, error) {
+		return lg.VerifyChain(strings.NewReader(strings.Join(lines, "")), key, nil)
+	}
+
+	write := func(opts ...func(*lg.Options)) {
+		lg.AddOutput(tlo, append([]func(*lg.Options){lg.JSON()}, opts...)...)
+		lg.Info("one", lg.F{"hash", "not mine"})
+		lg.Info("two")
+		lg.Info("three")
+		lg.Info("four")
+	}
+
+	It("links entries with sequence numbers and hashes", func() {
+		write(lg.HashChain(nil))
+
+		l := lines()
+		Expect(l).To(HaveLen(4))
+		Expect(l[0]).To(MatchRegexp(
+			`^\{"seq":1,"prev":"","t":"[^"]+","l":"info",` +
+				`"f":\{"hash":"not mine"\},"m":"one","hash":"[0-9a-f]{64}"\}\n$`))
+		Expect(l[1]).To(MatchRegexp(`^\{"seq":2,"prev":"[0-9a-f]{64}",`))
+
+		link, err := verify(l, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(link.Seq).To(Equal(uint64(4)))
+	})
+
+	It("detects modified entries", func() {
+		write(lg.HashChain(key))
+
+		l := lines()
+		l[2] = strings.Replace(l[2], "three", "3", 1)
+
+		link, err := verify(l, key)
+		Expect(err).To(MatchError(ContainSubstring("line 3 (seq 3): hash mismatch")))
+		Expect(link.Seq).To(Equal(uint64(2)))
+	})
+
+	It("detects entries signed with another key", func() {
+		write(lg.HashChain(key))
+
+		_, err := verify(lines(), []byte("guess"))
+		Expect(err).To(MatchError(ContainSubstring("line 1 (seq 1): hash mismatch")))
+	})
+
+	It("detects missing entries", func() {
+		write(lg.HashChain(key))
+
+		l := lines()
+		_, err := verify([]string{l[0], l[1], l[3]}, key)
+		Expect(err).To(MatchError(ContainSubstring("line 3 (seq 4): gap: expected seq 3")))
+	})
+
+	It("detects entries missing from the start", func() {
+		write(lg.HashChain(key))
+
+		l := lines()
+		_, err := verify(l[1:], key)
+		Expect(err).To(MatchError(ContainSubstring("line 1 (seq 2): gap: expected seq 1")))
+	})
+
+	It("verifies chains from a known link", func() {
+		write(lg.HashChain(key))
+
+		l := lines()
+		link, err := verify(l[:2], key)
+		Expect(err).NotTo(HaveOccurred())
+
+		link, err = lg.VerifyChain(strings.NewReader(strings.Join(l[2:], "")), key, &link)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(link.Seq).To(Equal(uint64(4)))
+
+		_, err = lg.VerifyChain(strings.NewReader(strings.Join(l[2:], "")), key,
+			&lg.ChainLink{Seq: 2, Hash: "0123"})
+		Expect(err).To(MatchError(ContainSubstring("line 1 (seq 3): broken link")))
+	})
+
+	It("detects reordered entries", func() {
+		write(lg.HashChain(key))
+
+		l := lines()
+		_, err := verify([]string{l[0], l[2], l[1], l[3]}, key)
+		Expect(err).To(MatchError(ContainSubstring("line 2 (seq 3): gap")))
+
+		_, err = verify([]string{l[0], l[1], l[1], l[3]}, key)
+		Expect(err).To(MatchError(ContainSubstring("line 3 (seq 2): out of order")))
+	})
+
+	It("detects broken links", func() {
+		lg.AddOutput(tlo, lg.JSON(), lg.HashChain(key))
+		lg.Info("another")
+		lg.Info("two")
+		lg.Info("three")
+		other := tlo.String()
+
+		tlo.Reset()
+		lg.RemoveOutput(tlo)
+		write(lg.HashChain(key))
+
+		// the third entry of another chain, which has a valid hash
+		l := lines()
+		l[2] = strings.SplitAfter(other, "\n")[2]
+
+		_, err := verify(l, key)
+		Expect(err).To(MatchError(ContainSubstring("line 3 (seq 3): broken link")))
+	})
+
+	It("continues chains across files", func() {
+		dir, err := ioutil.TempDir("", "lg-chain")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "audit.log")
+
+		for i := 0; i < 2; i++ {
+			f, err := lg.FileOutput(path)
+			Expect(err).NotTo(HaveOccurred())
+
+			last, err := lg.LastChainLink(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(last.Seq).To(Equal(uint64(i * 2)))
+
+			lg.AddOutput(f, lg.JSON(), lg.HashChain(key), lg.ContinueChain(last))
+			lg.Info("a")
+			lg.Info("b")
+			lg.RemoveOutput(f)
+			Expect(f.Close()).To(Succeed())
+		}
+
+		contents, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		link, err := lg.VerifyChain(bytes.NewReader(contents), key, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(link.Seq).To(Equal(uint64(4)))
+	})
+
+	It("keeps the chain intact when entries are written with an error", func() {
+		defer lg.SetRenameFile(func(from, to string) error {
+			return errors.New("rename failed")
+		})()
+
+		dir, err := ioutil.TempDir("", "lg-chain")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "audit.log")
+
+		f, err := lg.FileOutput(path, lg.RotateSize(1))
+		Expect(err).NotTo(HaveOccurred())
+
+		lg.AddOutput(f, lg.JSON(), lg.HashChain(key))
+		Expect(lg.Emit(lg.LevelInfo, "a")).To(Succeed())
+		Expect(lg.Emit(lg.LevelInfo, "b")).To(MatchError(ContainSubstring("rename failed")))
+		Expect(lg.Emit(lg.LevelInfo, "c")).To(MatchError(ContainSubstring("rename failed")))
+		lg.RemoveOutput(f)
+		Expect(f.Close()).To(Succeed())
+
+		contents, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		link, err := lg.VerifyChain(bytes.NewReader(contents), key, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(link.Seq).To(Equal(uint64(3)))
+	})
+
+	It("only works with JSON formats", func() {
+		lg.AddOutput(tlo, lg.HashChain(key))
+		Expect(lg.Emit(lg.LevelInfo, "plain")).To(MatchError(
+			ContainSubstring("hash chains require JSON entries")))
+	})
+})
@@ -0,0 +1,219 @@
+// Command lg pretty-prints logs written by lg in the JSON or plain text
+// formats, e.g. from production, in the console format, colored when writing
+// to a terminal.
+//
+// Usage:
+//
+//   lg [flags] [file...]
+//
+// Files are read in the order given, or stdin if there are none. Lines which
+// aren't lg entries, such as panics, are passed through as they are, unless
+// -skip is given.
+//
+// Examples:
+//
+//   kubectl logs api | lg -tz Local
+//   lg -fields user,status -hide password /var/log/app.log
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/autopilothq/lg"
+)
+
+type options struct {
+	skip   bool
+	fields map[string]bool
+	hide   map[string]bool
+}
+
+func main() {
+	format := flag.String("format", "auto",
+		"format of the logs: json, text, or auto to decide line by line")
+	skip := flag.Bool("skip", false, "skip lines which aren't lg entries")
+	tz := flag.String("tz", "UTC",
+		"time zone that times are shown in, e.g. Local or America/New_York")
+	layout := flag.String("time-layout", lg.TimeFormat,
+		"layout that times are shown in, as for time.Format")
+	fields := flag.String("fields", "",
+		"comma separated keys of the only fields to show")
+	hide := flag.String("hide", "", "comma separated keys of fields to hide")
+	color := flag.String("color", "auto", "color output: always, never or auto")
+	pretty := flag.Bool("pretty", false,
+		"show multi-line and structured fields over multiple lines")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] [file...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	formatOpts := []func(*lg.Options){
+		lg.Console(), lg.TimeLayout(*layout), lg.TimeLocation(loc),
+	}
+
+	switch *color {
+	case "always":
+		formatOpts = append(formatOpts, lg.Color(true))
+	case "never":
+		formatOpts = append(formatOpts, lg.Color(false))
+	case "auto":
+	default:
+		fmt.Fprintf(os.Stderr, "invalid -color %q\n", *color)
+		os.Exit(2)
+	}
+
+	if *pretty {
+		formatOpts = append(formatOpts, lg.PrettyFields())
+	}
+
+	var parse func(io.Reader) *lg.Parser
+	switch *format {
+	case "json":
+		parse = lg.ParseJSON
+	case "text":
+		parse = lg.ParsePlainText
+	case "auto":
+		parse = lg.Parse
+	default:
+		fmt.Fprintf(os.Stderr, "invalid -format %q\n", *format)
+		os.Exit(2)
+	}
+
+	opts := &options{
+		skip:   *skip,
+		fields: keySet(*fields),
+		hide:   keySet(*hide),
+	}
+
+	render := lg.Formatter(os.Stdout, formatOpts...)
+	out := bufio.NewWriter(os.Stdout)
+
+	if flag.NArg() == 0 {
+		err = prettyPrint(parse(flushReader{os.Stdin, out}), render, opts, out)
+		if ferr := out.Flush(); err == nil {
+			err = ferr
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			out.Flush()
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		err = prettyPrint(parse(flushReader{f, out}), render, opts, out)
+		f.Close()
+
+		if err != nil {
+			out.Flush()
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			os.Exit(1)
+		}
+	}
+
+	if err = out.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// flushReader flushes out before each read from r, so that entries are shown
+// as soon as they've been read, even when following logs which arrive slowly
+type flushReader struct {
+	r   io.Reader
+	out *bufio.Writer
+}
+
+func (f flushReader) Read(p []byte) (int, error) {
+	if err := f.out.Flush(); err != nil {
+		return 0, err
+	}
+	return f.r.Read(p)
+}
+
+// keySet returns the keys in a comma separated list, or nil if there are none
+func keySet(list string) map[string]bool {
+	if list == "" {
+		return nil
+	}
+
+	keys := make(map[string]bool)
+	for _, key := range strings.Split(list, ",") {
+		keys[strings.TrimSpace(key)] = true
+	}
+	return keys
+}
+
+// prettyPrint writes the entries read by p to out, rendered by render, along
+// with the lines which aren't entries unless they're to be skipped
+func prettyPrint(
+	p *lg.Parser, render func(*lg.Entry) []byte, opts *options, out io.Writer,
+) error {
+	for {
+		entry, err := p.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if perr, ok := err.(*lg.ParseError); ok {
+			if !opts.skip {
+				if _, err = fmt.Fprintln(out, perr.Text); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if opts.fields != nil || opts.hide != nil {
+			entry.Fields = filterFields(entry.Fields, opts)
+		}
+
+		if _, err = out.Write(render(entry)); err != nil {
+			return err
+		}
+	}
+}
+
+// filterFields returns the fields which are to be shown
+func filterFields(fields lg.Fields, opts *options) lg.Fields {
+	var shown []interface{}
+	for _, fld := range fields.All() {
+		if opts.hide[fld.Key] {
+			continue
+		}
+
+		// the err field is part of the message, so is always shown
+		if opts.fields != nil && !opts.fields[fld.Key] && fld.Key != lg.ErrKey {
+			continue
+		}
+
+		shown = append(shown, fld)
+	}
+
+	filtered, _ := lg.ExtractAllFields(shown)
+	return filtered
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/autopilothq/lg"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+func fields(flds ...lg.F) lg.Fields {
+	args := make([]interface{}, len(flds))
+	for i, fld := range flds {
+		args[i] = fld
+	}
+
+	fields, _ := lg.ExtractAllFields(args)
+	return fields
+}
+
+// render renders entries with just their message and the keys of their fields
+func render(entry *lg.Entry) []byte {
+	var keys []string
+	for _, fld := range entry.Fields.All() {
+		keys = append(keys, fld.Key)
+	}
+	return []byte(entry.Message + " " + strings.Join(keys, ",") + "\n")
+}
+
+var _ = Describe("lg command", func() {
+
+	all := fields(
+		lg.F{"user", "bob"}, lg.Err(nil), lg.F{"status", 200}, lg.F{"password", "x"})
+
+	table.DescribeTable("filterFields",
+		func(opts *options, expected []string) {
+			filtered := filterFields(all, opts)
+
+			var keys []string
+			for _, fld := range filtered.All() {
+				keys = append(keys, fld.Key)
+			}
+			Expect(keys).To(Equal(expected))
+		},
+		table.Entry("shows only the fields given, and the error",
+			&options{fields: keySet("status,user")},
+			[]string{"user", "err", "status"}),
+		table.Entry("hides the fields given",
+			&options{hide: keySet("password")},
+			[]string{"user", "err", "status"}),
+		table.Entry("hides fields even when they're to be shown",
+			&options{fields: keySet("user, password"), hide: keySet("password")},
+			[]string{"user", "err"}),
+		table.Entry("can hide the error",
+			&options{hide: keySet("err")},
+			[]string{"user", "status", "password"}),
+		table.Entry("shows nothing else if none of the fields given are present",
+			&options{fields: keySet("missing")},
+			[]string{"err"}),
+	)
+
+	const (
+		jsonLine = `{"t":"2017-09-15T12:30:00.000","l":"info","f":{"n":1,"secret":"x"},"m":"from json"}`
+		textLine = "2017-09-15T12:30:01.000 warn  [n:2] from text"
+	)
+
+	table.DescribeTable("prettyPrint",
+		func(input string, opts *options, expected string) {
+			var out bytes.Buffer
+			err := prettyPrint(lg.Parse(strings.NewReader(input)), render, opts, &out)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out.String()).To(Equal(expected))
+		},
+		table.Entry("renders entries in either format",
+			jsonLine+"\n"+textLine+"\n", &options{},
+			"from json n,secret\nfrom text n\n"),
+		table.Entry("passes through lines which aren't entries",
+			"panic: oh no\n"+jsonLine+"\n\ngoroutine 1 [running]:\n", &options{},
+			"panic: oh no\nfrom json n,secret\n\ngoroutine 1 [running]:\n"),
+		table.Entry("skips lines which aren't entries",
+			"panic: oh no\n"+jsonLine+"\n{not json\n", &options{skip: true},
+			"from json n,secret\n"),
+		table.Entry("filters the fields of entries",
+			jsonLine+"\n"+textLine+"\n", &options{hide: keySet("secret")},
+			"from json n\nfrom text n\n"),
+		table.Entry("handles a last line without a new line",
+			"panic: oh no", &options{},
+			"panic: oh no\n"),
+	)
+})
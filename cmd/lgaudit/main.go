@@ -0,0 +1,80 @@
+// Command lgaudit verifies the hash chains of audit logs written by lg
+// outputs with the HashChain option.
+//
+// Usage:
+//
+//   lgaudit [-key-file path] [-from-seq n -from-hash hash] file...
+//
+// Files are verified in the order given, as one chain, so that rotated files
+// can be checked together, oldest first. The chain must start at sequence
+// number 1, unless the last link before the first file is given with
+// -from-seq and -from-hash, e.g. once older files have been removed. The
+// first entry which was edited, or which shows that entries are missing or
+// out of order, is reported and lgaudit exits with status 1.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/autopilothq/lg"
+)
+
+func main() {
+	keyFile := flag.String("key-file", "",
+		"file containing the HMAC key that the chain was signed with")
+	fromSeq := flag.Uint64("from-seq", 0,
+		"sequence number of the entry before the first file")
+	fromHash := flag.String("from-hash", "",
+		"hash of the entry before the first file")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr,
+			"usage: %s [-key-file path] [-from-seq n -from-hash hash] file...\n",
+			os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var key []byte
+	if *keyFile != "" {
+		raw, err := ioutil.ReadFile(*keyFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		key = []byte(strings.TrimRight(string(raw), "\r\n"))
+	}
+
+	var last *lg.ChainLink
+	if *fromSeq > 0 || *fromHash != "" {
+		last = &lg.ChainLink{Seq: *fromSeq, Hash: *fromHash}
+	}
+
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+
+		link, err := lg.VerifyChain(f, key, last)
+		f.Close()
+
+		if err != nil {
+			fmt.Printf("%s: %s\n", path, err)
+			os.Exit(1)
+		}
+
+		last = &link
+	}
+
+	fmt.Printf("ok: chain intact up to seq %d, hash %s\n", last.Seq, last.Hash)
+}
@@ -0,0 +1,243 @@
+package lg
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/autopilothq/lg/encoding"
+	fancy "github.com/autopilothq/lg/encoding/json"
+	text "github.com/autopilothq/lg/encoding/text"
+	"github.com/autopilothq/lg/encoding/types"
+)
+
+const (
+	// ConsoleTimeFormat is the layout to use when rendering time in the
+	// console format
+	ConsoleTimeFormat = "15:04:05.000"
+
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+	ansiCyan   = "\x1b[36m"
+	ansiGrey   = "\x1b[90m"
+)
+
+type colorMode uint
+
+const (
+	colorAuto colorMode = iota
+	colorAlways
+	colorNever
+)
+
+func levelColor(l Level) string {
+	switch l {
+	case LevelTrace:
+		return ansiGrey
+	case LevelDebug:
+		return ansiBlue
+	case LevelInfo:
+		return ansiGreen
+	case LevelWarn:
+		return ansiYellow
+	case LevelError:
+		return ansiRed
+	case LevelFatal:
+		return ansiBold + ansiRed
+	default:
+		return ""
+	}
+}
+
+// isTerminal reports whether w is a character device, such as a terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// useColor decides whether console output to w should be colored. Unless
+// colors have been explicitly enabled or disabled, they're used when w is a
+// terminal and the NO_COLOR environment variable isn't set.
+func useColor(w io.Writer, mode colorMode) bool {
+	switch mode {
+	case colorAlways:
+		return true
+	case colorNever:
+		return false
+	}
+
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	return isTerminal(w)
+}
+
+// consoleFormatter renders entries in a human friendly, optionally colored,
+// format for developer consoles. Prefixes are padded to the width of the
+// widest prefix seen so far, so that messages line up.
+type consoleFormatter struct {
+	color       bool
+	pretty      bool
+	timeFormat  *types.TimeFormat
+	prefixWidth int32
+}
+
+func newConsoleFormatter(output io.Writer, options *Options) *consoleFormatter {
+	return &consoleFormatter{
+		color:      useColor(output, options.color),
+		pretty:     options.pretty,
+		timeFormat: options.timeFormat,
+	}
+}
+
+func (c *consoleFormatter) colored(out *bytes.Buffer, color string, s string) {
+	if c.color && color != "" {
+		out.WriteString(color)
+		out.WriteString(s)
+		out.WriteString(ansiReset)
+		return
+	}
+
+	out.WriteString(s)
+}
+
+func (c *consoleFormatter) format(e *Entry) []byte {
+	var out bytes.Buffer
+
+	if c.timeFormat != nil {
+		c.colored(&out, ansiDim, formatTime(e.Timestamp, c.timeFormat))
+	} else {
+		c.colored(&out, ansiDim, e.Timestamp.Format(ConsoleTimeFormat))
+	}
+	c.colored(&out, levelColor(e.Level), e.Level.AlignedString())
+
+	c.formatPrefix(&out, e.Prefix)
+	out.WriteString(e.Message)
+
+	var errMsg string
+	var multiline []F
+	var multilineText []string
+	for _, fld := range e.Fields.contents {
+		if fld.Key == ErrKey {
+			errMsg = RenderMessage(fld.Val)
+			continue
+		}
+
+		if c.pretty {
+			if pretty, ok := prettyValue(fld.Val, c.timeFormat); ok {
+				multiline = append(multiline, fld)
+				multilineText = append(multilineText, pretty)
+				continue
+			}
+		}
+
+		out.WriteByte(' ')
+		c.colored(&out, ansiCyan, fld.Key)
+		c.colored(&out, ansiDim, "=")
+		out.WriteString(renderTextValue(fld.Val, c.timeFormat))
+	}
+
+	if errMsg != "" {
+		c.colored(&out, ansiDim, ":")
+		out.WriteByte(' ')
+		c.colored(&out, ansiRed, errMsg)
+	}
+
+	out.WriteByte('\n')
+
+	for i, fld := range multiline {
+		out.WriteString("    ")
+		c.colored(&out, ansiCyan, fld.Key)
+		c.colored(&out, ansiDim, ":")
+		out.WriteByte('\n')
+
+		for _, line := range strings.Split(multilineText[i], "\n") {
+			out.WriteString("      ")
+			out.WriteString(line)
+			out.WriteByte('\n')
+		}
+	}
+
+	return out.Bytes()
+}
+
+func (c *consoleFormatter) formatPrefix(out *bytes.Buffer, prefix string) {
+	width := int(atomic.LoadInt32(&c.prefixWidth))
+	for len(prefix) > width {
+		if atomic.CompareAndSwapInt32(
+			&c.prefixWidth, int32(width), int32(len(prefix)),
+		) {
+			width = len(prefix)
+			break
+		}
+		width = int(atomic.LoadInt32(&c.prefixWidth))
+	}
+
+	if width == 0 {
+		return
+	}
+
+	if prefix == "" {
+		out.WriteString(strings.Repeat(" ", width+2))
+		return
+	}
+
+	c.colored(out, ansiBold+ansiCyan, "@"+prefix)
+	out.WriteString(strings.Repeat(" ", width-len(prefix)+1))
+}
+
+// renderTextValue renders a single field value with the text encoder
+func renderTextValue(val interface{}, tf *types.TimeFormat) string {
+	enc := text.NewEncoder()
+	enc.SetTimeFormat(tf)
+	if err := encoding.EncodeValue(enc, val); err != nil {
+		return RenderMessage(val)
+	}
+
+	return enc.String()
+}
+
+// prettyValue renders field values that are better displayed over multiple
+// lines: strings containing new lines, and objects and arrays, which are
+// rendered as indented JSON. It returns false for any other value.
+func prettyValue(val interface{}, tf *types.TimeFormat) (string, bool) {
+	if s, ok := val.(string); ok {
+		return strings.TrimRight(s, "\n"), strings.Contains(s, "\n")
+	}
+
+	enc := fancy.NewEncoder()
+	enc.SetTimeFormat(tf)
+	if err := encoding.EncodeValue(enc, val); err != nil {
+		return "", false
+	}
+
+	raw := enc.Bytes()
+	if len(raw) <= 2 || (raw[0] != '{' && raw[0] != '[') {
+		return "", false
+	}
+
+	var indented bytes.Buffer
+	if err := stdjson.Indent(&indented, raw, "", "  "); err != nil {
+		return "", false
+	}
+
+	return indented.String(), true
+}
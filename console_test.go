@@ -0,0 +1,88 @@
+package lg_test
+
+import (
+	"errors"
+	"os"
+	"regexp"
+
+	"github.com/autopilothq/lg"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var consoleTimePattern = regexp.MustCompile(
+	"[0-9]{2}:[0-9]{2}:[0-9]{2}\\.[0-9]{3}")
+
+var _ = Describe("console output", func() {
+
+	var tlo *TestLogOutput
+
+	BeforeEach(func() {
+		tlo = &TestLogOutput{}
+		lg.RemoveOutput(os.Stdout)
+	})
+
+	AfterEach(func() {
+		lg.RemoveOutput(tlo)
+		lg.AddOutput(os.Stdout)
+	})
+
+	output := func() string {
+		return consoleTimePattern.ReplaceAllLiteralString(tlo.String(), "T")
+	}
+
+	It("isn't colored when the output isn't a terminal", func() {
+		lg.AddOutput(tlo, lg.Console())
+		lg.Info("hello", lg.F{"foo", "bar"}, lg.F{"n", 1})
+
+		Expect(output()).To(Equal("T info  hello foo=\"bar\" n=1\n"))
+	})
+
+	It("colors levels, timestamps, prefixes and keys when forced", func() {
+		lg.AddOutput(tlo, lg.Console(), lg.Color(true))
+		lg.ExtendWithPrefix("Srv").Warn("careful", lg.F{"foo", 1})
+
+		Expect(output()).To(Equal(
+			"\x1b[2mT\x1b[0m\x1b[33m warn  \x1b[0m\x1b[1m\x1b[36m@Srv\x1b[0m " +
+				"careful \x1b[36mfoo\x1b[0m\x1b[2m=\x1b[0m1\n"))
+	})
+
+	It("aligns messages after prefixes", func() {
+		lg.AddOutput(tlo, lg.Console())
+		lg.ExtendWithPrefix("Server").Info("one")
+		lg.ExtendWithPrefix("Db").Info("two")
+		lg.Info("three")
+
+		Expect(output()).To(Equal(
+			"T info  @Server one\n" +
+				"T info  @Db     two\n" +
+				"T info          three\n"))
+	})
+
+	It("appends errors to the message", func() {
+		lg.AddOutput(tlo, lg.Console())
+		lg.Error("failed", lg.Err(errors.New("it broke")))
+
+		Expect(output()).To(Equal("T error failed: it broke\n"))
+	})
+
+	It("pretty prints multi-line and structured values", func() {
+		lg.AddOutput(tlo, lg.Console(), lg.PrettyFields())
+		lg.Info("done",
+			lg.F{"n", 1},
+			lg.F{"trace", "a\nb\n"},
+			lg.F{"req", map[string]interface{}{"id": 1}},
+		)
+
+		Expect(output()).To(Equal(
+			"T info  done n=1\n" +
+				"    trace:\n" +
+				"      a\n" +
+				"      b\n" +
+				"    req:\n" +
+				"      {\n" +
+				"        \"id\": 1\n" +
+				"      }\n"))
+	})
+})
@@ -0,0 +1,187 @@
+package lg
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/autopilothq/lg/encoding"
+	fancy "github.com/autopilothq/lg/encoding/json"
+	"github.com/autopilothq/lg/encoding/types"
+)
+
+const (
+	// ECSVersion is the version of the Elastic Common Schema that the ECS
+	// format conforms to
+	ECSVersion = "1.6.0"
+
+	// DefaultECSNamespace is the key that fields are nested within in the ECS
+	// format, unless set with ECSNamespace. ECS only allows flat string values
+	// within labels, so field values are written as strings there.
+	DefaultECSNamespace = "labels"
+)
+
+// ecsFormatter renders entries in the Elastic Common Schema
+type ecsFormatter struct {
+	namespace  string
+	service    string
+	timeFormat *types.TimeFormat
+}
+
+func newECSFormatter(options *Options) *ecsFormatter {
+	f := &ecsFormatter{
+		namespace:  options.ecsNamespace,
+		service:    options.serviceName,
+		timeFormat: options.timeFormat,
+	}
+
+	if f.namespace == "" {
+		f.namespace = DefaultECSNamespace
+	}
+
+	if f.service == "" {
+		f.service = filepath.Base(os.Args[0])
+	}
+
+	if f.timeFormat == nil {
+		// ECS timestamps must have a zone designator
+		f.timeFormat = &types.TimeFormat{Layout: TimeRFC3339Milli}
+	}
+
+	return f
+}
+
+func (f *ecsFormatter) format(e *Entry) []byte {
+	enc := fancy.NewEncoder()
+	enc.SetTimeFormat(f.timeFormat)
+
+	if err := f.encode(enc, e); err != nil {
+		return makeJSONError(enc, err)
+	}
+
+	return append(enc.Bytes(), '\n')
+}
+
+func (f *ecsFormatter) encode(enc *fancy.Encoder, e *Entry) (err error) {
+	if err = enc.StartObject(); err != nil {
+		return err
+	}
+
+	if err = encoding.EncodeTimeKeyValue(enc, "@timestamp", e.Timestamp); err != nil {
+		return err
+	}
+
+	err = encoding.EncodeStringKeyValue(enc, "log.level", e.Level.String())
+	if err != nil {
+		return err
+	}
+
+	if err = encoding.EncodeStringKeyValue(enc, "message", e.Message); err != nil {
+		return err
+	}
+
+	if err = encoding.EncodeStringKeyValue(enc, "ecs.version", ECSVersion); err != nil {
+		return err
+	}
+
+	if e.Prefix != "" {
+		if err = encoding.EncodeStringKeyValue(enc, "log.logger", e.Prefix); err != nil {
+			return err
+		}
+	}
+
+	if err = encoding.EncodeStringKeyValue(enc, "service.name", f.service); err != nil {
+		return err
+	}
+
+	if e.Caller != nil {
+		err = encoding.EncodeStringKeyValue(enc, "log.origin.file.name", e.Caller.File)
+		if err != nil {
+			return err
+		}
+
+		err = encoding.EncodeKeyValue(enc, "log.origin.file.line", e.Caller.Line)
+		if err != nil {
+			return err
+		}
+
+		err = encoding.EncodeStringKeyValue(enc, "log.origin.function", e.Caller.Function)
+		if err != nil {
+			return err
+		}
+	}
+
+	var errVal interface{}
+	hasErr, hasFields := false, false
+	for _, fld := range e.Fields.contents {
+		if fld.Key == ErrKey {
+			errVal, hasErr = fld.Val, true
+		} else {
+			hasFields = true
+		}
+	}
+
+	if hasErr {
+		if err = f.encodeError(enc, errVal); err != nil {
+			return err
+		}
+	}
+
+	if hasFields {
+		if err = f.encodeFields(enc, &e.Fields); err != nil {
+			return err
+		}
+	}
+
+	return enc.EndObject()
+}
+
+// encodeError maps the err field onto error.message and, if the error has
+// one, error.stack_trace
+func (f *ecsFormatter) encodeError(enc *fancy.Encoder, val interface{}) error {
+	err := encoding.EncodeStringKeyValue(enc, "error.message", RenderMessage(val))
+	if err != nil {
+		return err
+	}
+
+	if stack := errorStack(val); stack != "" {
+		return encoding.EncodeStringKeyValue(enc, "error.stack_trace", stack)
+	}
+
+	return nil
+}
+
+// encodeFields writes all fields but the err field within the namespace
+func (f *ecsFormatter) encodeFields(enc *fancy.Encoder, fields *Fields) (err error) {
+	if err = enc.AddKey(f.namespace); err != nil {
+		return err
+	}
+
+	if err = enc.StartObject(); err != nil {
+		return err
+	}
+
+	for _, fld := range fields.contents {
+		if fld.Key == ErrKey {
+			continue
+		}
+
+		if f.namespace == DefaultECSNamespace {
+			text, err := fieldText(fld.Val, f.timeFormat)
+			if err != nil {
+				return err
+			}
+
+			err = encoding.EncodeStringKeyValue(enc, fld.Key, text)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err = encoding.EncodeKeyValue(enc, fld.Key, fld.Val); err != nil {
+			return err
+		}
+	}
+
+	return enc.EndObject()
+}
@@ -0,0 +1,135 @@
+package lg_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/autopilothq/lg"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// tracedError renders a stack trace with %+v, like github.com/pkg/errors
+type tracedError struct {
+	msg string
+}
+
+func (e tracedError) Error() string {
+	return e.msg
+}
+
+func (e tracedError) Format(s fmt.State, verb rune) {
+	fmt.Fprint(s, e.msg)
+	if s.Flag('+') {
+		fmt.Fprint(s, "\nmain.main\n\tmain.go:12")
+	}
+}
+
+var _ = Describe("ECS output", func() {
+
+	var tlo *TestLogOutput
+
+	BeforeEach(func() {
+		tlo = &TestLogOutput{}
+		lg.RemoveOutput(os.Stdout)
+	})
+
+	AfterEach(func() {
+		lg.RemoveOutput(tlo)
+		lg.AddOutput(os.Stdout)
+	})
+
+	decode := func() map[string]interface{} {
+		var result map[string]interface{}
+		Expect(json.Unmarshal(tlo.Bytes(), &result)).To(Succeed())
+		return result
+	}
+
+	It("maps entries onto ECS", func() {
+		lg.AddOutput(tlo, lg.ECS(), lg.ServiceName("api"))
+		lg.ExtendWithPrefix("Server").Warn("careful", lg.F{"id", 1})
+
+		Expect(tlo.String()).To(MatchRegexp(
+			`^\{"@timestamp":"[0-9-]{10}T[0-9:]{8}\.[0-9]{3}Z","log.level":"warn",` +
+				`"message":"careful","ecs.version":"1.6.0",`))
+
+		Expect(decode()).To(Equal(map[string]interface{}{
+			"@timestamp":   decode()["@timestamp"],
+			"log.level":    "warn",
+			"message":      "careful",
+			"ecs.version":  lg.ECSVersion,
+			"log.logger":   "Server",
+			"service.name": "api",
+			"labels":       map[string]interface{}{"id": "1"},
+		}))
+	})
+
+	It("writes labels as strings", func() {
+		lg.AddOutput(tlo, lg.ECS())
+		lg.Info("hello",
+			lg.F{"ok", true},
+			lg.F{"tags", []string{"a", "b"}},
+			lg.F{"when", time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)},
+		)
+
+		Expect(decode()).To(HaveKeyWithValue("labels", map[string]interface{}{
+			"ok":   "true",
+			"tags": `["a","b"]`,
+			"when": "2017-01-02T03:04:05.000Z",
+		}))
+	})
+
+	It("nests fields within the given namespace", func() {
+		lg.AddOutput(tlo, lg.ECS(), lg.ECSNamespace("app"))
+		lg.Info("hello", lg.F{"id", 1}, lg.F{"tags", []string{"a"}})
+
+		Expect(decode()).To(HaveKeyWithValue("app", map[string]interface{}{
+			"id": float64(1), "tags": []interface{}{"a"},
+		}))
+		Expect(decode()).NotTo(HaveKey("labels"))
+	})
+
+	It("maps errors onto error.*", func() {
+		lg.AddOutput(tlo, lg.ECS())
+		lg.Error("failed", lg.Err(errors.New("it broke")))
+
+		Expect(decode()).To(HaveKeyWithValue("error.message", "it broke"))
+		Expect(decode()).NotTo(HaveKey("error.stack_trace"))
+		Expect(decode()).NotTo(HaveKey("labels"))
+	})
+
+	It("includes the stack trace of errors that have one", func() {
+		lg.AddOutput(tlo, lg.ECS())
+		lg.Error("failed", lg.Err(tracedError{"it broke"}))
+
+		Expect(decode()).To(HaveKeyWithValue("error.message", "it broke"))
+		Expect(decode()).To(HaveKeyWithValue(
+			"error.stack_trace", "it broke\nmain.main\n\tmain.go:12"))
+	})
+
+	It("renders errors with stack traces normally in other formats", func() {
+		lg.AddOutput(tlo, lg.JSON())
+		lg.Error("failed", lg.Err(tracedError{"it broke"}))
+
+		Expect(decode()).To(HaveKeyWithValue(
+			"f", map[string]interface{}{"err": "it broke"}))
+	})
+
+	It("keeps the error itself as the value of the err field", func() {
+		var errVal interface{}
+		hookID := lg.AddHook(func(e *lg.Entry) error {
+			errVal, _ = e.Fields.Get(lg.ErrKey)
+			return nil
+		})
+		defer lg.RemoveHook(hookID)
+
+		traced := tracedError{"it broke"}
+		lg.Error("failed", lg.Err(traced))
+
+		Expect(errVal).To(Equal(traced))
+	})
+})
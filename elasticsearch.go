@@ -0,0 +1,248 @@
+package lg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultElasticsearchIndex is the layout of the names of the indices that
+// entries are written to, unless set with ElasticsearchIndex
+const DefaultElasticsearchIndex = "logs-2006.01.02"
+
+// elasticsearchTimeKeys are the keys that the timestamps of entries are
+// looked for at, unless set with ElasticsearchTimeKey: those of the ECS and
+// JSON formats
+var elasticsearchTimeKeys = []string{"@timestamp", "t"}
+
+// ElasticsearchIndex sets the layout, as for time.Format, of the names of the
+// indices that entries are written to, according to the day (in UTC) of
+// their timestamps
+func ElasticsearchIndex(layout string) func(*ElasticsearchOptions) {
+	return func(o *ElasticsearchOptions) {
+		o.index = layout
+		o.dataStream = false
+	}
+}
+
+// ElasticsearchDataStream writes entries to the named data stream, rather
+// than to an index for each day. Data streams require an @timestamp field,
+// so entries should be formatted with ECS.
+func ElasticsearchDataStream(name string) func(*ElasticsearchOptions) {
+	return func(o *ElasticsearchOptions) {
+		o.index = name
+		o.dataStream = true
+	}
+}
+
+// ElasticsearchTimeKey sets the key of the timestamp that entries are
+// indexed by, e.g. the TimeKey of a JSONSchema. It defaults to @timestamp,
+// or t if there's no @timestamp. Entries without a timestamp are indexed by
+// the time they're written.
+func ElasticsearchTimeKey(key string) func(*ElasticsearchOptions) {
+	return func(o *ElasticsearchOptions) {
+		o.timeKeys = []string{key}
+	}
+}
+
+// ElasticsearchOptions configures an ElasticsearchWriter
+type ElasticsearchOptions struct {
+	HTTPOptions
+	index      string
+	dataStream bool
+	timeKeys   []string
+}
+
+// ElasticsearchHTTP applies options for HTTP writers, e.g. HTTPHeader, to an
+// ElasticsearchWriter
+func ElasticsearchHTTP(opts ...func(*HTTPOptions)) func(*ElasticsearchOptions) {
+	return func(o *ElasticsearchOptions) {
+		for _, opt := range opts {
+			opt(&o.HTTPOptions)
+		}
+	}
+}
+
+// ElasticsearchWriter indexes entries, which must be formatted as JSON, e.g.
+// by the ECS format, in Elasticsearch or OpenSearch through the _bulk API.
+// Entries are written to an index for the day of their timestamp, named
+// according to the index layout, e.g. logs-2017.09.15, or to a data stream.
+// Batches are retried
+// when the cluster fails or applies backpressure with a 429 status, and when
+// only some of the entries in a batch fail, only those which might succeed
+// are retried. Batching, retries and headers, e.g. for authentication, are
+// configured as for an HTTPWriter, with ElasticsearchHTTP.
+//
+// Example:
+//
+//   w := lg.ElasticsearchOutput("https://elastic:9200",
+//     lg.ElasticsearchHTTP(lg.HTTPHeader("Authorization", "ApiKey "+key)),
+//     lg.ElasticsearchIndex("api-logs-2006.01.02"))
+//   defer w.Close()
+//   lg.AddOutput(w, lg.ECS(), lg.ServiceName("api"))
+type ElasticsearchWriter struct {
+	url     string
+	options ElasticsearchOptions
+	batcher *batcher
+}
+
+// ElasticsearchOutput returns a writer which indexes entries in the cluster
+// at url
+func ElasticsearchOutput(
+	url string, opts ...func(*ElasticsearchOptions),
+) *ElasticsearchWriter {
+	w := &ElasticsearchWriter{
+		url: strings.TrimSuffix(url, "/") + "/_bulk",
+		options: ElasticsearchOptions{
+			HTTPOptions: makeHTTPOptions(nil),
+			index:       DefaultElasticsearchIndex,
+			timeKeys:    elasticsearchTimeKeys,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(&w.options)
+	}
+
+	w.batcher = newBatcher(redactURL(url), w.options.batch, w.send)
+
+	return w
+}
+
+// Write queues p, which must be a JSON object, to be indexed. It fails with
+// ErrQueueFull if the queue is full.
+func (w *ElasticsearchWriter) Write(p []byte) (int, error) {
+	index := w.options.index
+	if !w.options.dataStream {
+		index = entryTime(p, w.options.timeKeys).UTC().Format(index)
+	}
+
+	// each entry is queued as the bulk action which creates it, followed by
+	// the document itself
+	action, err := json.Marshal(map[string]interface{}{
+		"create": map[string]string{"_index": index},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	item := make([]byte, 0, len(action)+1+len(p))
+	item = append(item, action...)
+	item = append(item, '\n')
+	item = append(item, p...)
+
+	if err := w.batcher.add(item); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// entryTime returns the timestamp at the first of keys in the JSON object p,
+// or the current time if it doesn't have one
+func entryTime(p []byte, keys []string) time.Time {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(p, &obj); err != nil {
+		return time.Now()
+	}
+
+	for _, key := range keys {
+		raw, ok := obj[key]
+		if !ok {
+			continue
+		}
+
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			s = string(raw)
+		}
+		if t, err := parseTime(s); err == nil {
+			return t
+		}
+	}
+
+	return time.Now()
+}
+
+// Dropped returns the number of entries that have been dropped, because the
+// queue was full or they couldn't be indexed
+func (w *ElasticsearchWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.batcher.dropped)
+}
+
+// Close indexes any queued entries, waiting at most the flush timeout
+func (w *ElasticsearchWriter) Close() error {
+	return w.batcher.close()
+}
+
+// bulkResponse is the part of a _bulk response that reports the outcome of
+// each item
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+func (w *ElasticsearchWriter) send(ctx context.Context, batch [][]byte) error {
+	var body bytes.Buffer
+	for _, item := range batch {
+		body.Write(item)
+		body.WriteByte('\n')
+	}
+
+	resp, err := doRequest(
+		ctx, w.url, &w.options.HTTPOptions, "application/x-ndjson", body.Bytes())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	var result bulkResponse
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+
+	if !result.Errors {
+		return nil
+	}
+
+	if len(result.Items) != len(batch) {
+		return fmt.Errorf("_bulk response has %d items for %d entries",
+			len(result.Items), len(batch))
+	}
+
+	partial := &partialError{}
+	for i, item := range result.Items {
+		for _, outcome := range item {
+			if outcome.Status < 300 {
+				continue
+			}
+
+			if outcome.Status == http.StatusTooManyRequests || outcome.Status >= 500 {
+				partial.retry = append(partial.retry, batch[i])
+			} else {
+				partial.rejected++
+			}
+
+			if partial.err == nil {
+				partial.err = fmt.Errorf(
+					"_bulk item failed with status %d: %s", outcome.Status, outcome.Error)
+			}
+		}
+	}
+
+	if partial.err == nil {
+		return nil
+	}
+	return partial
+}
@@ -0,0 +1,221 @@
+package lg_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/autopilothq/lg"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// bulkServer stands in for the _bulk API, recording the documents that it's
+// sent, and failing each item with the next of the statuses given for its
+// message, if there are any left
+type bulkServer struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	statuses map[string][]int
+	indices  []string
+	docs     []string
+}
+
+func newBulkServer(statuses map[string][]int) *bulkServer {
+	s := &bulkServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Path).To(Equal("/_bulk"))
+			Expect(r.Header.Get("Content-Type")).To(Equal("application/x-ndjson"))
+
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+
+			var items []interface{}
+			errors := false
+
+			scanner := bufio.NewScanner(r.Body)
+			for scanner.Scan() {
+				var action struct {
+					Create struct {
+						Index string `json:"_index"`
+					} `json:"create"`
+				}
+				Expect(json.Unmarshal(scanner.Bytes(), &action)).To(Succeed())
+				Expect(scanner.Scan()).To(BeTrue())
+
+				var doc struct {
+					M string `json:"m"`
+				}
+				Expect(json.Unmarshal(scanner.Bytes(), &doc)).To(Succeed())
+
+				status := http.StatusCreated
+				if pending := s.statuses[doc.M]; len(pending) > 0 {
+					status, s.statuses[doc.M] = pending[0], pending[1:]
+					errors = true
+				} else {
+					s.indices = append(s.indices, action.Create.Index)
+					s.docs = append(s.docs, doc.M)
+				}
+
+				items = append(items, map[string]interface{}{
+					"create": map[string]interface{}{"status": status},
+				})
+			}
+
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"errors": errors,
+				"items":  items,
+			})
+		}))
+	return s
+}
+
+func (s *bulkServer) Indices() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.indices...)
+}
+
+func (s *bulkServer) Docs() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.docs...)
+}
+
+var _ = Describe("Elasticsearch output", func() {
+
+	fastRetries := lg.HTTPRetries(3, time.Millisecond, 10*time.Millisecond)
+
+	BeforeEach(func() {
+		lg.RemoveOutput(os.Stdout)
+	})
+
+	AfterEach(func() {
+		lg.AddOutput(os.Stdout)
+	})
+
+	It("creates documents in an index for the day", func() {
+		server := newBulkServer(nil)
+		defer server.Close()
+
+		w := lg.ElasticsearchOutput(server.URL)
+		lg.AddOutput(w, lg.JSON())
+		lg.Info("one")
+		lg.Info("two")
+		lg.RemoveOutput(w)
+		Expect(w.Close()).To(Succeed())
+
+		Expect(server.Docs()).To(Equal([]string{"one", "two"}))
+		index := "logs-" + time.Now().UTC().Format("2006.01.02")
+		Expect(server.Indices()).To(Equal([]string{index, index}))
+	})
+
+	It("names indices with the given layout", func() {
+		server := newBulkServer(nil)
+		defer server.Close()
+
+		w := lg.ElasticsearchOutput(server.URL+"/", lg.ElasticsearchIndex("app-2006.01"))
+		w.Write([]byte(`{"m":"one"}`))
+		Expect(w.Close()).To(Succeed())
+
+		Expect(server.Indices()).To(Equal(
+			[]string{"app-" + time.Now().UTC().Format("2006.01")}))
+	})
+
+	It("indexes entries by their own timestamps", func() {
+		server := newBulkServer(nil)
+		defer server.Close()
+
+		w := lg.ElasticsearchOutput(server.URL)
+		w.Write([]byte(`{"@timestamp":"2017-09-15T23:59:59.999Z","m":"one"}`))
+		w.Write([]byte(`{"t":"2017-09-16T00:00:00.000","m":"two"}`))
+		Expect(w.Close()).To(Succeed())
+
+		Expect(server.Indices()).To(Equal(
+			[]string{"logs-2017.09.15", "logs-2017.09.16"}))
+	})
+
+	It("indexes entries by the timestamp at the given key", func() {
+		server := newBulkServer(nil)
+		defer server.Close()
+
+		w := lg.ElasticsearchOutput(server.URL, lg.ElasticsearchTimeKey("when"))
+		w.Write([]byte(`{"when":"2017-09-15T01:02:03+02:00","m":"one"}`))
+		Expect(w.Close()).To(Succeed())
+
+		Expect(server.Indices()).To(Equal([]string{"logs-2017.09.14"}))
+	})
+
+	It("writes to a data stream without formatting its name", func() {
+		server := newBulkServer(nil)
+		defer server.Close()
+
+		w := lg.ElasticsearchOutput(server.URL, lg.ElasticsearchDataStream("logs-app2-default"))
+		w.Write([]byte(`{"@timestamp":"2017-09-15T00:00:00.000Z","m":"one"}`))
+		Expect(w.Close()).To(Succeed())
+
+		Expect(server.Indices()).To(Equal([]string{"logs-app2-default"}))
+	})
+
+	It("retries only the entries which might succeed", func() {
+		server := newBulkServer(map[string][]int{
+			"busy":     {429, 503},
+			"rejected": {400},
+		})
+		defer server.Close()
+
+		w := lg.ElasticsearchOutput(server.URL, lg.ElasticsearchHTTP(fastRetries))
+		w.Write([]byte(`{"m":"one"}`))
+		w.Write([]byte(`{"m":"busy"}`))
+		w.Write([]byte(`{"m":"rejected"}`))
+		w.Write([]byte(`{"m":"two"}`))
+		Expect(w.Close()).To(Succeed())
+
+		Expect(server.Docs()).To(Equal([]string{"one", "two", "busy"}))
+		Expect(w.Dropped()).To(Equal(uint64(1)))
+	})
+
+	It("drops entries which are still failing once out of retries", func() {
+		server := newBulkServer(map[string][]int{
+			"busy": {429, 429, 429, 429},
+		})
+		defer server.Close()
+
+		w := lg.ElasticsearchOutput(server.URL, lg.ElasticsearchHTTP(fastRetries))
+		w.Write([]byte(`{"m":"one"}`))
+		w.Write([]byte(`{"m":"busy"}`))
+		Expect(w.Close()).To(Succeed())
+
+		Expect(server.Docs()).To(Equal([]string{"one"}))
+		Expect(w.Dropped()).To(Equal(uint64(1)))
+	})
+
+	It("retries the whole batch when the request fails", func() {
+		failed := false
+		server := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if !failed {
+					failed = true
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Write([]byte(`{"errors":false,"items":[{"create":{"status":201}}]}`))
+			}))
+		defer server.Close()
+
+		w := lg.ElasticsearchOutput(server.URL, lg.ElasticsearchHTTP(fastRetries))
+		w.Write([]byte(`{"m":"one"}`))
+		Expect(w.Close()).To(Succeed())
+
+		Expect(failed).To(BeTrue())
+		Expect(w.Dropped()).To(BeZero())
+	})
+})
+
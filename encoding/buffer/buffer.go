@@ -3,6 +3,8 @@ package buffer
 import (
 	"strconv"
 	"time"
+
+	"github.com/autopilothq/lg/encoding/types"
 )
 
 const bufferSize = 1024
@@ -159,6 +161,31 @@ func (b *Buffer) AppendTimestamp(t time.Time) {
 	b.AppendInt(t.UnixNano())
 }
 
+// AppendFormattedTime appends a Time to the buffer in the given format
+func (b *Buffer) AppendFormattedTime(t time.Time, f *types.TimeFormat) {
+	switch f.Unit {
+	case 0:
+		if f.Location != nil {
+			t = t.In(f.Location)
+		}
+
+		if f.Layout == "" {
+			b.AppendTime(t)
+		} else {
+			b.ba = t.AppendFormat(b.ba, f.Layout)
+		}
+
+	case time.Nanosecond:
+		b.AppendTimestamp(t)
+
+	case time.Second:
+		b.AppendInt(t.Unix())
+
+	default:
+		b.AppendInt(t.UnixNano() / int64(f.Unit))
+	}
+}
+
 // Write appends raw bytes to the buffer.
 func (b *Buffer) Write(bytes []byte) (int, error) {
 	b.ba = append(b.ba, bytes...)
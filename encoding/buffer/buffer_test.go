@@ -8,6 +8,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	. "github.com/autopilothq/lg/encoding/buffer"
+	"github.com/autopilothq/lg/encoding/types"
 )
 
 var _ = Describe("log encoding Buffer", func() {
@@ -170,6 +171,44 @@ var _ = Describe("log encoding Buffer", func() {
 		})
 	})
 
+	Describe("AppendFormattedTime()", func() {
+		t := time.Date(2017, 3, 4, 5, 6, 7, 123456789, time.UTC)
+
+		It("appends the time with a layout", func() {
+			buf.AppendFormattedTime(t, &types.TimeFormat{Layout: time.RFC3339Nano})
+			Expect(buf.String()).To(Equal("2017-03-04T05:06:07.123456789Z"))
+		})
+
+		It("appends the time in a location", func() {
+			loc := time.FixedZone("AEST", 10*60*60)
+			buf.AppendFormattedTime(t, &types.TimeFormat{
+				Layout:   time.RFC3339,
+				Location: loc,
+			})
+			Expect(buf.String()).To(Equal("2017-03-04T15:06:07+10:00"))
+		})
+
+		It("appends the time in the default layout", func() {
+			buf.AppendFormattedTime(t, &types.TimeFormat{})
+			Expect(buf.String()).To(Equal("2017-03-04T05:06:07.123"))
+		})
+
+		It("appends the time as Unix seconds", func() {
+			buf.AppendFormattedTime(t, &types.TimeFormat{Unit: time.Second})
+			Expect(buf.String()).To(Equal("1488603967"))
+		})
+
+		It("appends the time as Unix milliseconds", func() {
+			buf.AppendFormattedTime(t, &types.TimeFormat{Unit: time.Millisecond})
+			Expect(buf.String()).To(Equal("1488603967123"))
+		})
+
+		It("appends the time as Unix nanoseconds", func() {
+			buf.AppendFormattedTime(t, &types.TimeFormat{Unit: time.Nanosecond})
+			Expect(buf.String()).To(Equal("1488603967123456789"))
+		})
+	})
+
 	Describe("Reset()", func() {
 		It("resets the buffer to empty", func() {
 			buf.AppendString("Wut!")
@@ -0,0 +1,13 @@
+package cbor_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestCBOR(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "CBOR Suite")
+}
@@ -0,0 +1,449 @@
+package cbor
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	// maxDepth is the deepest that arrays and maps can be nested
+	maxDepth = 64
+
+	// maxLength is the longest that a string, array or map can be
+	maxLength = 1 << 26
+)
+
+var errBreak = errors.New("cbor: unexpected break")
+
+// Decoder reads CBOR items, e.g. a CBOR sequence (RFC 8742), from a reader
+type Decoder struct {
+	r     *bufio.Reader
+	depth int
+}
+
+// NewDecoder returns a new Decoder which reads from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r: bufio.NewReader(r),
+	}
+}
+
+// Decode reads the next item. Integers are decoded as int64, or as uint64 if
+// they don't fit, maps as map[string]interface{}, arrays as []interface{},
+// byte strings as []byte, times as time.Time in UTC and durations as
+// time.Duration. It returns io.EOF if there are no more items.
+func (d *Decoder) Decode() (interface{}, error) {
+	if _, err := d.r.Peek(1); err != nil {
+		return nil, err
+	}
+
+	val, err := d.decode()
+	return val, unexpectedEOF(err)
+}
+
+// ReadMapLen reads the head of a map of definite length, and returns its
+// number of pairs. It returns io.EOF if there are no more items.
+func (d *Decoder) ReadMapLen() (int, error) {
+	if _, err := d.r.Peek(1); err != nil {
+		return 0, err
+	}
+
+	major, arg, indef, err := d.readHead()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+
+	if major != majorMap || indef {
+		return 0, fmt.Errorf("cbor: expected a map of definite length")
+	}
+
+	return d.checkLength(arg)
+}
+
+// ReadString reads a text string, e.g. a key of a map
+func (d *Decoder) ReadString() (string, error) {
+	val, err := d.Decode()
+	if err != nil {
+		return "", unexpectedEOF(err)
+	}
+
+	s, ok := val.(string)
+	if !ok {
+		return "", fmt.Errorf("cbor: expected a string, not %T", val)
+	}
+	return s, nil
+}
+
+// unexpectedEOF reports the end of the input in the middle of an item as
+// io.ErrUnexpectedEOF
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readHead reads the initial bytes of an item, and returns its major type and
+// argument, or whether it's of indefinite length. The argument of a simple
+// value or float is its additional information.
+func (d *Decoder) readHead() (major byte, arg uint64, indef bool, err error) {
+	initial, err := d.r.ReadByte()
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	major = initial >> 5
+	info := initial & 0x1f
+
+	if major == majorSimple {
+		return major, uint64(info), false, nil
+	}
+
+	var size int
+	switch {
+	case info < 24:
+		return major, uint64(info), false, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	case info == indefinite && major != majorUint &&
+		major != majorNegInt && major != majorTag:
+		return major, 0, true, nil
+	default:
+		return 0, 0, false, fmt.Errorf("cbor: invalid initial byte 0x%02x", initial)
+	}
+
+	arg, err = d.readUint(size)
+	return major, arg, false, err
+}
+
+// readUint reads a big endian unsigned integer of size bytes
+func (d *Decoder) readUint(size int) (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(d.r, b[8-size:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b[:]), nil
+}
+
+func (d *Decoder) checkLength(n uint64) (int, error) {
+	if n > maxLength {
+		return 0, fmt.Errorf("cbor: length %d is too long", n)
+	}
+	return int(n), nil
+}
+
+func (d *Decoder) decode() (interface{}, error) {
+	major, arg, indef, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case majorUint:
+		if arg > math.MaxInt64 {
+			return arg, nil
+		}
+		return int64(arg), nil
+
+	case majorNegInt:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("cbor: integer -1-%d overflows int64", arg)
+		}
+		return -1 - int64(arg), nil
+
+	case majorBytes, majorText:
+		b, err := d.readString(major, arg, indef)
+		if err != nil {
+			return nil, err
+		}
+		if major == majorText {
+			return string(b), nil
+		}
+		return b, nil
+
+	case majorArray:
+		return d.decodeArray(arg, indef)
+
+	case majorMap:
+		return d.decodeMap(arg, indef)
+
+	case majorTag:
+		return d.decodeTagged(arg)
+
+	default:
+		return d.decodeSimple(arg)
+	}
+}
+
+// readString reads the contents of a byte or text string, which are
+// concatenated from chunks if it's of indefinite length
+func (d *Decoder) readString(major byte, arg uint64, indef bool) ([]byte, error) {
+	if !indef {
+		n, err := d.checkLength(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		b := make([]byte, n)
+		_, err = io.ReadFull(d.r, b)
+		return b, err
+	}
+
+	var b []byte
+	for {
+		chunkMajor, chunkArg, chunkIndef, err := d.readHead()
+		if err != nil {
+			return nil, err
+		}
+
+		if chunkMajor == majorSimple && chunkArg == breakByte&0x1f {
+			return b, nil
+		}
+
+		if chunkMajor != major || chunkIndef {
+			return nil, errors.New("cbor: invalid chunk of string")
+		}
+
+		chunk, err := d.readString(major, chunkArg, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(b)+len(chunk) > maxLength {
+			return nil, errors.New("cbor: string is too long")
+		}
+		b = append(b, chunk...)
+	}
+}
+
+func (d *Decoder) enter() error {
+	d.depth++
+	if d.depth > maxDepth {
+		return errors.New("cbor: items are nested too deeply")
+	}
+	return nil
+}
+
+func (d *Decoder) leave() {
+	d.depth--
+}
+
+func (d *Decoder) decodeArray(arg uint64, indef bool) (interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+
+	arr := []interface{}{}
+	for i := uint64(0); indef || i < arg; i++ {
+		if i >= maxLength {
+			return nil, errors.New("cbor: array is too long")
+		}
+
+		val, err := d.decode()
+		if err == errBreak && indef {
+			return arr, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		arr = append(arr, val)
+	}
+	return arr, nil
+}
+
+func (d *Decoder) decodeMap(arg uint64, indef bool) (interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+
+	obj := map[string]interface{}{}
+	for i := uint64(0); indef || i < arg; i++ {
+		if i >= maxLength {
+			return nil, errors.New("cbor: map is too long")
+		}
+
+		key, err := d.decode()
+		if err == errBreak && indef {
+			return obj, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		s, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("cbor: map keys must be strings, not %T", key)
+		}
+
+		if obj[s], err = d.decode(); err != nil {
+			return nil, unexpectedBreak(err)
+		}
+	}
+	return obj, nil
+}
+
+// unexpectedBreak reports a break where a value is expected as an error of
+// its own, so that it isn't mistaken for the end of a container
+func unexpectedBreak(err error) error {
+	if err == errBreak {
+		return errors.New("cbor: map key without a value")
+	}
+	return err
+}
+
+// decodeTagged decodes a tagged item. Times and durations are decoded as
+// such, and the tags of any other items are ignored.
+func (d *Decoder) decodeTagged(tag uint64) (interface{}, error) {
+	switch tag {
+	case TagTime, TagDuration:
+		secs, nanos, err := d.readSecondsAndNanos()
+		if err != nil {
+			return nil, err
+		}
+		if tag == TagDuration {
+			return time.Duration(secs)*time.Second + time.Duration(nanos), nil
+		}
+		return time.Unix(secs, nanos).UTC(), nil
+	}
+
+	val, err := d.decode()
+	if err != nil {
+		return nil, unexpectedBreak(err)
+	}
+
+	switch tag {
+	case 0:
+		if s, ok := val.(string); ok {
+			return time.Parse(time.RFC3339Nano, s)
+		}
+		return nil, errors.New("cbor: time string must be a string")
+
+	case 1:
+		switch v := val.(type) {
+		case int64:
+			return time.Unix(v, 0).UTC(), nil
+		case uint64:
+			return time.Unix(int64(v), 0).UTC(), nil
+		case float64:
+			secs, frac := math.Modf(v)
+			return time.Unix(int64(secs), int64(frac*1e9)).UTC(), nil
+		}
+		return nil, errors.New("cbor: epoch time must be a number")
+	}
+
+	return val, nil
+}
+
+// readSecondsAndNanos reads the map that times and durations are encoded as
+func (d *Decoder) readSecondsAndNanos() (secs, nanos int64, err error) {
+	major, n, indef, err := d.readHead()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if major != majorMap || indef || n > 4 {
+		return 0, 0, errors.New("cbor: invalid time or duration")
+	}
+
+	for i := uint64(0); i < n; i++ {
+		key, err := d.decode()
+		if err != nil {
+			return 0, 0, unexpectedBreak(err)
+		}
+
+		val, err := d.decode()
+		if err != nil {
+			return 0, 0, unexpectedBreak(err)
+		}
+
+		v, ok := val.(int64)
+		if !ok {
+			return 0, 0, errors.New("cbor: invalid time or duration")
+		}
+
+		switch key {
+		case int64(keySeconds):
+			secs = v
+		case int64(keyNanoseconds):
+			nanos = v
+		}
+	}
+
+	return secs, nanos, nil
+}
+
+func (d *Decoder) decodeSimple(info uint64) (interface{}, error) {
+	switch info {
+	case simpleFalse & 0x1f:
+		return false, nil
+	case simpleTrue & 0x1f:
+		return true, nil
+	case simpleNull & 0x1f, simpleUndefined & 0x1f:
+		return nil, nil
+
+	case float16Head & 0x1f:
+		bits, err := d.readUint(2)
+		if err != nil {
+			return nil, err
+		}
+		return float16ToFloat64(uint16(bits)), nil
+
+	case float32Head & 0x1f:
+		bits, err := d.readUint(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(uint32(bits))), nil
+
+	case float64Head & 0x1f:
+		bits, err := d.readUint(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(bits), nil
+
+	case breakByte & 0x1f:
+		return nil, errBreak
+	}
+
+	return nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+}
+
+// float16ToFloat64 converts an IEEE 754 half precision float
+func float16ToFloat64(bits uint16) float64 {
+	exp := int(bits>>10) & 0x1f
+	mant := float64(bits & 0x3ff)
+
+	var val float64
+	switch exp {
+	case 0:
+		val = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			val = math.Inf(1)
+		} else {
+			val = math.NaN()
+		}
+	default:
+		val = math.Ldexp(mant+1024, exp-25)
+	}
+
+	if bits&0x8000 != 0 {
+		return -val
+	}
+	return val
+}
@@ -0,0 +1,367 @@
+// Package cbor encodes and decodes values in the Concise Binary Object
+// Representation (RFC 8949).
+//
+// Objects and arrays are encoded with indefinite lengths, as their lengths
+// aren't known when they're started. Times are encoded with tag 1001, and
+// durations with tag 1002 (RFC 9581), so that they keep their precision.
+package cbor
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/autopilothq/lg/encoding/buffer"
+	"github.com/autopilothq/lg/encoding/types"
+)
+
+// Major types
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+const (
+	simpleFalse     = 0xf4
+	simpleTrue      = 0xf5
+	simpleNull      = 0xf6
+	simpleUndefined = 0xf7
+	float16Head     = 0xf9
+	float32Head     = 0xfa
+	float64Head     = 0xfb
+	breakByte       = 0xff
+
+	indefinite = 31
+
+	// TagTime is the tag of a time, encoded as a map of its seconds and
+	// nanoseconds since the Unix epoch
+	TagTime = 1001
+
+	// TagDuration is the tag of a duration, encoded as a map of its seconds
+	// and nanoseconds
+	TagDuration = 1002
+
+	// keys of the maps that times and durations are encoded as
+	keySeconds     = 1
+	keyNanoseconds = -9
+)
+
+// Encoder can encode Go types to CBOR
+type Encoder struct {
+	buf *buffer.Buffer
+}
+
+// NewEncoder returns a new Encoder
+func NewEncoder() *Encoder {
+	return &Encoder{
+		buf: buffer.GetBuffer(),
+	}
+}
+
+// String returns the encoded buffer as a string
+func (e *Encoder) String() string {
+	return e.buf.String()
+}
+
+// Bytes returns the raw encoded bytes
+func (e *Encoder) Bytes() []byte {
+	return e.buf.Bytes()
+}
+
+// appendHead appends the initial bytes of an item, with its major type and
+// argument
+func (e *Encoder) appendHead(major byte, arg uint64) {
+	var head [9]byte
+	head[0] = major << 5
+
+	switch {
+	case arg < 24:
+		head[0] |= byte(arg)
+		e.buf.Write(head[:1])
+	case arg <= math.MaxUint8:
+		head[0] |= 24
+		head[1] = byte(arg)
+		e.buf.Write(head[:2])
+	case arg <= math.MaxUint16:
+		head[0] |= 25
+		binary.BigEndian.PutUint16(head[1:], uint16(arg))
+		e.buf.Write(head[:3])
+	case arg <= math.MaxUint32:
+		head[0] |= 26
+		binary.BigEndian.PutUint32(head[1:], uint32(arg))
+		e.buf.Write(head[:5])
+	default:
+		head[0] |= 27
+		binary.BigEndian.PutUint64(head[1:], arg)
+		e.buf.Write(head[:9])
+	}
+}
+
+func (e *Encoder) appendInt(val int64) {
+	if val < 0 {
+		e.appendHead(majorNegInt, uint64(-1-val))
+		return
+	}
+	e.appendHead(majorUint, uint64(val))
+}
+
+// AddKey appends the desired key to the buffer
+func (e *Encoder) AddKey(key string) error {
+	return e.AddString(key)
+}
+
+// AddUint16 appends a uint16 to the buffer
+func (e *Encoder) AddUint16(val uint16) error {
+	e.appendHead(majorUint, uint64(val))
+	return nil
+}
+
+// AddUint32 appends a uint32 to the buffer
+func (e *Encoder) AddUint32(val uint32) error {
+	e.appendHead(majorUint, uint64(val))
+	return nil
+}
+
+// AddUint64 appends a uint64 to the buffer
+func (e *Encoder) AddUint64(val uint64) error {
+	e.appendHead(majorUint, val)
+	return nil
+}
+
+// AddInt16 appends a int16 to the buffer
+func (e *Encoder) AddInt16(val int16) error {
+	e.appendInt(int64(val))
+	return nil
+}
+
+// AddInt32 appends a int32 to the buffer
+func (e *Encoder) AddInt32(val int32) error {
+	e.appendInt(int64(val))
+	return nil
+}
+
+// AddInt64 appends a int64 to the buffer
+func (e *Encoder) AddInt64(val int64) error {
+	e.appendInt(val)
+	return nil
+}
+
+// AddFloat32 appends a float32 to the buffer
+func (e *Encoder) AddFloat32(val float32) error {
+	var b [5]byte
+	b[0] = float32Head
+	binary.BigEndian.PutUint32(b[1:], math.Float32bits(val))
+	_, err := e.buf.Write(b[:])
+	return err
+}
+
+// AddFloat64 appends a float64 to the buffer
+func (e *Encoder) AddFloat64(val float64) error {
+	var b [9]byte
+	b[0] = float64Head
+	binary.BigEndian.PutUint64(b[1:], math.Float64bits(val))
+	_, err := e.buf.Write(b[:])
+	return err
+}
+
+// AddFloat appends a float64 to the buffer
+func (e *Encoder) AddFloat(val float64, bitsize int) error {
+	if bitsize == 32 {
+		return e.AddFloat32(float32(val))
+	}
+	return e.AddFloat64(val)
+}
+
+// AddBool appends the boolean value to the buffer
+func (e *Encoder) AddBool(val bool) error {
+	if val {
+		e.buf.AppendByte(simpleTrue)
+	} else {
+		e.buf.AppendByte(simpleFalse)
+	}
+	return nil
+}
+
+// AddByteString appends the byte string value to the buffer, as a text
+// string
+func (e *Encoder) AddByteString(val string) error {
+	return e.AddString(val)
+}
+
+// AddBytes appends the byte array value to the buffer, as a byte string
+func (e *Encoder) AddBytes(val []byte) error {
+	e.appendHead(majorBytes, uint64(len(val)))
+	_, err := e.buf.Write(val)
+	return err
+}
+
+// appendSecondsAndNanos appends the map that times and durations are encoded
+// as, leaving out the nanoseconds if there aren't any
+func (e *Encoder) appendSecondsAndNanos(secs, nanos int64) {
+	if nanos == 0 {
+		e.appendHead(majorMap, 1)
+	} else {
+		e.appendHead(majorMap, 2)
+	}
+
+	e.appendInt(keySeconds)
+	e.appendInt(secs)
+
+	if nanos != 0 {
+		e.appendInt(keyNanoseconds)
+		e.appendInt(nanos)
+	}
+}
+
+// AddDuration appends a Duration to the buffer
+func (e *Encoder) AddDuration(val time.Duration) error {
+	e.appendHead(majorTag, TagDuration)
+	e.appendSecondsAndNanos(int64(val/time.Second), int64(val%time.Second))
+	return nil
+}
+
+// AddTime appends a Time to the buffer
+func (e *Encoder) AddTime(t time.Time) error {
+	e.appendHead(majorTag, TagTime)
+	e.appendSecondsAndNanos(t.Unix(), int64(t.Nanosecond()))
+	return nil
+}
+
+// AddTimestamp appends a Timestamp to the buffer, in the same way as AddTime
+func (e *Encoder) AddTimestamp(t time.Time) error {
+	return e.AddTime(t)
+}
+
+// AddNull appends a null value to the buffer
+func (e *Encoder) AddNull() error {
+	e.buf.AppendByte(simpleNull)
+	return nil
+}
+
+// AddArrayish appends an array value to the buffer
+func (e *Encoder) AddArrayish(arr types.Array) error {
+	e.StartArray()
+	if err := arr.MarshalArray(e); err != nil {
+		return err
+	}
+	return e.EndArray()
+}
+
+// AddObject appends an object value to the buffer
+func (e *Encoder) AddObject(obj types.Object) error {
+	e.StartObject()
+	if err := obj.MarshalObject(e); err != nil {
+		return err
+	}
+	return e.EndObject()
+}
+
+// StartArray appends the start of an array of indefinite length
+func (e *Encoder) StartArray() error {
+	e.buf.AppendByte(majorArray<<5 | indefinite)
+	return nil
+}
+
+// EndArray appends the end of an array of indefinite length
+func (e *Encoder) EndArray() error {
+	e.buf.AppendByte(breakByte)
+	return nil
+}
+
+// StartObject appends the start of a map of indefinite length
+func (e *Encoder) StartObject() error {
+	e.buf.AppendByte(majorMap<<5 | indefinite)
+	return nil
+}
+
+// EndObject appends the end of a map of indefinite length
+func (e *Encoder) EndObject() error {
+	e.buf.AppendByte(breakByte)
+	return nil
+}
+
+// StartMap appends the start of a map of n pairs, which doesn't need to be
+// ended
+func (e *Encoder) StartMap(n int) error {
+	e.appendHead(majorMap, uint64(n))
+	return nil
+}
+
+// AddReflected encodes the value via json.Marshal, and appends the result
+// to the buffer as CBOR
+func (e *Encoder) AddReflected(val interface{}) error {
+	marshaledVal, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+
+	var decoded interface{}
+	if err = json.Unmarshal(marshaledVal, &decoded); err != nil {
+		return err
+	}
+
+	return e.addJSONValue(decoded)
+}
+
+// addJSONValue appends a value decoded by encoding/json
+func (e *Encoder) addJSONValue(val interface{}) error {
+	switch v := val.(type) {
+	case nil:
+		return e.AddNull()
+
+	case bool:
+		return e.AddBool(v)
+
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return e.AddInt64(int64(v))
+		}
+		return e.AddFloat64(v)
+
+	case string:
+		return e.AddString(v)
+
+	case []interface{}:
+		e.StartArray()
+		for _, item := range v {
+			if err := e.addJSONValue(item); err != nil {
+				return err
+			}
+		}
+		return e.EndArray()
+
+	default:
+		// encoding/json only decodes objects as maps, whose keys are sorted
+		// as json.Marshal would sort them
+		obj := v.(map[string]interface{})
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		e.StartObject()
+		for _, key := range keys {
+			e.AddKey(key)
+			if err := e.addJSONValue(obj[key]); err != nil {
+				return err
+			}
+		}
+		return e.EndObject()
+	}
+}
+
+// AddString adds a string to the encoded buffer
+func (e *Encoder) AddString(s string) error {
+	e.appendHead(majorText, uint64(len(s)))
+	e.buf.AppendString(s)
+	return nil
+}
@@ -0,0 +1,234 @@
+package cbor_test
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/autopilothq/lg/encoding/cbor"
+)
+
+func decodeAll(b []byte) []interface{} {
+	dec := NewDecoder(bytes.NewReader(b))
+
+	var items []interface{}
+	for {
+		item, err := dec.Decode()
+		if err == io.EOF {
+			return items
+		}
+		Expect(err).NotTo(HaveOccurred())
+		items = append(items, item)
+	}
+}
+
+var _ = Describe("log encoding CBOR", func() {
+	var (
+		enc *Encoder
+	)
+
+	BeforeEach(func() {
+		enc = NewEncoder()
+	})
+
+	Describe("Add ints", func() {
+		It("adds small ints in the initial byte", func() {
+			Expect(enc.AddInt16(10)).To(Succeed())
+			Expect(enc.AddInt32(-10)).To(Succeed())
+			Expect(enc.Bytes()).To(Equal([]byte{0x0a, 0x29}))
+		})
+
+		It("adds larger ints in the smallest size that fits", func() {
+			Expect(enc.AddUint16(500)).To(Succeed())
+			Expect(enc.AddInt64(-100000)).To(Succeed())
+			Expect(enc.AddUint64(math.MaxUint64)).To(Succeed())
+			Expect(enc.Bytes()).To(Equal([]byte{
+				0x19, 0x01, 0xf4,
+				0x3a, 0x00, 0x01, 0x86, 0x9f,
+				0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+			}))
+		})
+
+		It("decodes them", func() {
+			enc.AddInt64(math.MinInt64)
+			enc.AddUint32(math.MaxUint32)
+			enc.AddUint64(math.MaxUint64)
+			Expect(decodeAll(enc.Bytes())).To(Equal([]interface{}{
+				int64(math.MinInt64), int64(math.MaxUint32), uint64(math.MaxUint64),
+			}))
+		})
+	})
+
+	Describe("Add floats", func() {
+		It("adds a float32", func() {
+			Expect(enc.AddFloat32(1.5)).To(Succeed())
+			Expect(enc.Bytes()).To(Equal([]byte{0xfa, 0x3f, 0xc0, 0x00, 0x00}))
+		})
+
+		It("adds a float64", func() {
+			Expect(enc.AddFloat(1.1, 64)).To(Succeed())
+			Expect(enc.Bytes()).To(Equal([]byte{
+				0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a,
+			}))
+		})
+
+		It("adds NaN and infinities", func() {
+			enc.AddFloat64(math.Inf(-1))
+			enc.AddFloat64(math.NaN())
+			items := decodeAll(enc.Bytes())
+			Expect(items[0]).To(Equal(math.Inf(-1)))
+			Expect(math.IsNaN(items[1].(float64))).To(BeTrue())
+		})
+
+		It("decodes half precision floats", func() {
+			Expect(decodeAll([]byte{0xf9, 0x3e, 0x00, 0xf9, 0xc4, 0x00})).To(
+				Equal([]interface{}{1.5, -4.0}))
+		})
+	})
+
+	Describe("Add other values", func() {
+		It("adds bools and null", func() {
+			enc.AddBool(true)
+			enc.AddBool(false)
+			enc.AddNull()
+			Expect(enc.Bytes()).To(Equal([]byte{0xf5, 0xf4, 0xf6}))
+			Expect(decodeAll(enc.Bytes())).To(Equal([]interface{}{true, false, nil}))
+		})
+
+		It("adds strings as text strings", func() {
+			Expect(enc.AddString("héllo")).To(Succeed())
+			Expect(enc.AddByteString("a")).To(Succeed())
+			Expect(enc.Bytes()).To(Equal([]byte{
+				0x66, 'h', 0xc3, 0xa9, 'l', 'l', 'o', 0x61, 'a',
+			}))
+		})
+
+		It("adds bytes as byte strings", func() {
+			Expect(enc.AddBytes([]byte{1, 2})).To(Succeed())
+			Expect(enc.Bytes()).To(Equal([]byte{0x42, 1, 2}))
+			Expect(decodeAll(enc.Bytes())).To(Equal([]interface{}{[]byte{1, 2}}))
+		})
+
+		It("adds times with nanosecond precision", func() {
+			t := time.Date(2017, 9, 15, 12, 30, 0, 123456789, time.UTC)
+			Expect(enc.AddTime(t)).To(Succeed())
+			Expect(enc.Bytes()[:3]).To(Equal([]byte{0xd9, 0x03, 0xe9}))
+
+			items := decodeAll(enc.Bytes())
+			Expect(items[0].(time.Time).Equal(t)).To(BeTrue())
+		})
+
+		It("adds durations", func() {
+			Expect(enc.AddDuration(90 * time.Second)).To(Succeed())
+			Expect(enc.AddDuration(-1500 * time.Millisecond)).To(Succeed())
+			Expect(enc.Bytes()[:6]).To(Equal([]byte{
+				0xd9, 0x03, 0xea, 0xa1, 0x01, 0x18,
+			}))
+			Expect(decodeAll(enc.Bytes())).To(Equal([]interface{}{
+				90 * time.Second, -1500 * time.Millisecond,
+			}))
+		})
+
+		It("adds reflected values", func() {
+			Expect(enc.AddReflected(struct {
+				B []int   `json:"b"`
+				A float64 `json:"a"`
+			}{[]int{1, 2}, 0.5})).To(Succeed())
+
+			Expect(decodeAll(enc.Bytes())).To(Equal([]interface{}{
+				map[string]interface{}{
+					"a": 0.5,
+					"b": []interface{}{int64(1), int64(2)},
+				},
+			}))
+		})
+	})
+
+	Describe("Add containers", func() {
+		It("adds arrays and objects of indefinite length", func() {
+			enc.StartObject()
+			enc.AddKey("a")
+			enc.StartArray()
+			enc.AddInt64(1)
+			enc.EndArray()
+			enc.EndObject()
+			Expect(enc.Bytes()).To(Equal([]byte{
+				0xbf, 0x61, 'a', 0x9f, 0x01, 0xff, 0xff,
+			}))
+		})
+
+		It("adds maps of definite length", func() {
+			enc.StartMap(1)
+			enc.AddKey("a")
+			enc.AddString("b")
+
+			dec := NewDecoder(bytes.NewReader(enc.Bytes()))
+			Expect(dec.ReadMapLen()).To(Equal(1))
+			Expect(dec.ReadString()).To(Equal("a"))
+			Expect(dec.Decode()).To(Equal("b"))
+
+			_, err := dec.Decode()
+			Expect(err).To(Equal(io.EOF))
+		})
+	})
+})
+
+var _ = Describe("decoding CBOR", func() {
+	It("decodes strings of indefinite length", func() {
+		Expect(decodeAll([]byte{0x7f, 0x61, 'a', 0x62, 'b', 'c', 0xff})).To(
+			Equal([]interface{}{"abc"}))
+	})
+
+	It("decodes strings whose length is the same as the indefinite marker", func() {
+		s := string(bytes.Repeat([]byte{'x'}, 31))
+		enc := NewEncoder()
+		enc.AddString(s)
+		Expect(decodeAll(enc.Bytes())).To(Equal([]interface{}{s}))
+	})
+
+	It("decodes epoch and string times", func() {
+		items := decodeAll([]byte{
+			0xc1, 0x1a, 0x59, 0xbb, 0xc7, 0x88,
+			0xc0, 0x74, '2', '0', '1', '7', '-', '0', '9', '-', '1', '5', 'T',
+			'1', '2', ':', '3', '0', ':', '0', '0', 'Z',
+		})
+		Expect(items[0].(time.Time).Unix()).To(Equal(int64(1505478536)))
+		Expect(items[1].(time.Time).Equal(
+			time.Date(2017, 9, 15, 12, 30, 0, 0, time.UTC))).To(BeTrue())
+	})
+
+	It("ignores unknown tags", func() {
+		Expect(decodeAll([]byte{0xd8, 0x20, 0x61, 'u'})).To(
+			Equal([]interface{}{"u"}))
+	})
+
+	It("fails on truncated items", func() {
+		_, err := NewDecoder(bytes.NewReader([]byte{0x82, 0x01})).Decode()
+		Expect(err).To(Equal(io.ErrUnexpectedEOF))
+	})
+
+	It("fails on unexpected breaks", func() {
+		_, err := NewDecoder(bytes.NewReader([]byte{0x82, 0x01, 0xff})).Decode()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("fails on maps with keys that aren't strings", func() {
+		_, err := NewDecoder(bytes.NewReader([]byte{0xa1, 0x01, 0x02})).Decode()
+		Expect(err).To(MatchError(ContainSubstring("keys must be strings")))
+	})
+
+	It("fails on items which are nested too deeply", func() {
+		_, err := NewDecoder(bytes.NewReader(bytes.Repeat([]byte{0x81}, 100))).Decode()
+		Expect(err).To(MatchError(ContainSubstring("nested too deeply")))
+	})
+
+	It("fails on lengths which are too long", func() {
+		_, err := NewDecoder(bytes.NewReader(
+			[]byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})).Decode()
+		Expect(err).To(MatchError(ContainSubstring("too long")))
+	})
+})
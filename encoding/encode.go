@@ -7,19 +7,10 @@ import (
 	"github.com/autopilothq/lg/encoding/types"
 )
 
-// Encode json encodes a map of key/value pairs
+// Encode json encodes a map of key/value pairs. Keys are encoded in sorted
+// order, unless disabled with SetSortMapKeys.
 func Encode(enc types.Encoder, kv map[string]interface{}) (err error) {
-	for k, v := range kv {
-		if err = enc.AddKey(k); err != nil {
-			return err
-		}
-
-		if err = EncodeValue(enc, v); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return encodeKeyValues(enc, kv)
 }
 
 // EncodeKeyValue json encodes a single key/value pair
@@ -84,10 +75,8 @@ func encodeObject(enc types.Encoder, obj map[string]interface{}) (err error) {
 		return err
 	}
 
-	for key, val := range obj {
-		if err = EncodeKeyValue(enc, key, val); err != nil {
-			return err
-		}
+	if err = encodeKeyValues(enc, obj); err != nil {
+		return err
 	}
 
 	return enc.EndObject()
@@ -199,6 +188,12 @@ func EncodeValue(enc types.Encoder, value interface{}) (err error) {
 	case []string:
 		return enc.AddArrayish(types.Strings(val))
 
+	case types.Object:
+		return enc.AddObject(val)
+
+	case types.Array:
+		return enc.AddArrayish(val)
+
 	case error:
 		return enc.AddString(val.Error())
 
@@ -223,7 +218,18 @@ func EncodeValue(enc types.Encoder, value interface{}) (err error) {
 	case map[string]interface{}:
 		return encodeObject(enc, val)
 
+	case map[string]string:
+		return encodeStringMap(enc, val)
+
 	default:
+		if fn, v, ok := lookupEncoder(val); ok {
+			return fn(enc, v)
+		}
+
+		if rv := reflect.ValueOf(val); isStringKeyedMap(rv) {
+			return encodeReflectedMap(enc, rv)
+		}
+
 		return enc.AddReflected(val)
 	}
 }
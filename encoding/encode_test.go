@@ -2,6 +2,7 @@ package encoding_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	. "github.com/onsi/ginkgo"
@@ -9,8 +10,34 @@ import (
 
 	. "github.com/autopilothq/lg/encoding"
 	fancy "github.com/autopilothq/lg/encoding/json"
+	text "github.com/autopilothq/lg/encoding/text"
+	"github.com/autopilothq/lg/encoding/types"
 )
 
+type user struct {
+	ID    int64
+	Name  string
+	Roles []string
+}
+
+func (u user) MarshalObject(enc types.Encoder) error {
+	if err := EncodeKeyValue(enc, "id", u.ID); err != nil {
+		return err
+	}
+
+	if err := EncodeKeyValue(enc, "name", u.Name); err != nil {
+		return err
+	}
+
+	return EncodeKeyValue(enc, "roles", u.Roles)
+}
+
+type brokenObject struct{}
+
+func (b brokenObject) MarshalObject(enc types.Encoder) error {
+	return errors.New("broken")
+}
+
 var _ = Describe("log encoding Encoder", func() {
 	var (
 		enc *fancy.Encoder
@@ -62,6 +89,116 @@ var _ = Describe("log encoding Encoder", func() {
 		})
 	})
 
+	Describe("map ordering", func() {
+		It("encodes map keys in sorted order", func() {
+			for i := 0; i < 10; i++ {
+				enc := fancy.NewEncoder()
+				Expect(EncodeValue(enc, map[string]interface{}{
+					"b": 2, "c": 3, "a": 1, "e": 5, "d": 4,
+				})).To(Succeed())
+				Expect(enc.String()).To(Equal(`{"a":1,"b":2,"c":3,"d":4,"e":5}`))
+			}
+		})
+
+		It("encodes top level key/values in sorted order", func() {
+			Expect(Encode(enc, map[string]interface{}{
+				"z": "last", "a": "first",
+			})).To(Succeed())
+			Expect(enc.String()).To(Equal(`"a":"first","z":"last"`))
+		})
+
+		It("encodes map[string]string", func() {
+			Expect(EncodeValue(enc, map[string]string{
+				"foo": "bar", "baz": "qux",
+			})).To(Succeed())
+			Expect(enc.String()).To(Equal(`{"baz":"qux","foo":"bar"}`))
+		})
+
+		It("encodes other maps with string keys", func() {
+			Expect(EncodeValue(enc, map[string]int{
+				"two": 2, "one": 1,
+			})).To(Succeed())
+			Expect(enc.String()).To(Equal(`{"one":1,"two":2}`))
+		})
+
+		It("encodes maps with named string key types", func() {
+			type key string
+			Expect(EncodeValue(enc, map[key][]string{
+				"b": {"x"}, "a": {"y", "z"},
+			})).To(Succeed())
+			Expect(enc.String()).To(Equal(`{"a":["y","z"],"b":["x"]}`))
+		})
+
+		It("encodes nil maps as empty maps", func() {
+			var m map[string]bool
+			var i map[string]interface{}
+			var s map[string]string
+			Expect(EncodeValue(enc, []interface{}{m, i, s})).To(Succeed())
+			Expect(enc.String()).To(Equal(`[{},{},{}]`))
+		})
+
+		It("encodes nil maps as empty maps into the text encoder", func() {
+			tenc := text.NewEncoder()
+			var m map[string]bool
+			Expect(EncodeKeyValue(tenc, "m", m)).To(Succeed())
+			Expect(EncodeKeyValue(tenc, "s", struct{ M map[int]int }{})).To(Succeed())
+			Expect(tenc.String()).To(Equal(`m:{} s:{M:{}}`))
+		})
+
+		It("can be changed while encoding", func() {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; i < 100; i++ {
+					SetSortMapKeys(i%2 == 0)
+				}
+			}()
+
+			for i := 0; i < 100; i++ {
+				Expect(EncodeValue(fancy.NewEncoder(), map[string]int{"a": 1})).To(Succeed())
+			}
+			<-done
+			SetSortMapKeys(true)
+		})
+
+		It("encodes maps into the text encoder", func() {
+			tenc := text.NewEncoder()
+			Expect(EncodeKeyValue(tenc, "m", map[string]int{
+				"b": 2, "a": 1,
+			})).To(Succeed())
+			Expect(tenc.String()).To(Equal(`m:{a:1 b:2}`))
+		})
+	})
+
+	Describe("Object marshalers", func() {
+		It("encodes the object via MarshalObject", func() {
+			u := user{ID: 42, Name: "bob", Roles: []string{"admin"}}
+			Expect(EncodeValue(enc, u)).To(Succeed())
+			Expect(enc.String()).To(Equal(
+				`{"id":42,"name":"bob","roles":["admin"]}`))
+		})
+
+		It("encodes objects nested in other values", func() {
+			Expect(EncodeValue(enc, []interface{}{
+				user{ID: 1, Name: "a"}, user{ID: 2, Name: "b"},
+			})).To(Succeed())
+			Expect(enc.String()).To(Equal(
+				`[{"id":1,"name":"a","roles":[]},{"id":2,"name":"b","roles":[]}]`))
+		})
+
+		It("returns errors from MarshalObject", func() {
+			Expect(EncodeValue(enc, brokenObject{})).To(MatchError("broken"))
+		})
+
+		It("encodes the object into the text encoder", func() {
+			tenc := text.NewEncoder()
+			u := user{ID: 42, Name: "bob", Roles: []string{"admin"}}
+			Expect(EncodeKeyValue(tenc, "user", u)).To(Succeed())
+			Expect(tenc.String()).To(Equal(
+				`user:{id:42 name:"bob" roles:["admin"]}`))
+		})
+	})
+
 	Describe("AddArray", func() {
 		It("Adds a single element array", func() {
 			Expect(EncodeValue(enc, []interface{}{1234})).To(Succeed())
@@ -12,7 +12,8 @@ import (
 
 // Encoder can encode Go types to JSON
 type Encoder struct {
-	buf *buffer.Buffer
+	buf        *buffer.Buffer
+	timeFormat *types.TimeFormat
 }
 
 // NewEncoder returns a new Encoder
@@ -32,6 +33,12 @@ func (e *Encoder) Bytes() []byte {
 	return e.buf.Bytes()
 }
 
+// SetTimeFormat sets the format that times are encoded with. A nil format
+// restores the default.
+func (e *Encoder) SetTimeFormat(f *types.TimeFormat) {
+	e.timeFormat = f
+}
+
 // AddKey appends the desired key to the buffer
 func (e *Encoder) AddKey(key string) error {
 	e.addSeparator()
@@ -140,12 +147,25 @@ func (e *Encoder) AddDuration(val time.Duration) error {
 }
 
 // AddTime appends a Time to the buffer.
-// 	This is hardcoded to use the format: 2006-01-02T15:04:05.000
+// 	Unless set with SetTimeFormat, the format is: 2006-01-02T15:04:05.000
 //
 func (e *Encoder) AddTime(t time.Time) error {
 	e.addSeparator()
+
+	if e.timeFormat == nil {
+		e.buf.AppendByte('"')
+		e.buf.AppendTime(t)
+		e.buf.AppendByte('"')
+		return nil
+	}
+
+	if e.timeFormat.IsNumeric() {
+		e.buf.AppendFormattedTime(t, e.timeFormat)
+		return nil
+	}
+
 	e.buf.AppendByte('"')
-	e.buf.AppendTime(t)
+	e.buf.AppendFormattedTime(t, e.timeFormat)
 	e.buf.AppendByte('"')
 	return nil
 }
@@ -173,6 +193,17 @@ func (e *Encoder) AddArrayish(arr types.Array) error {
 	return nil
 }
 
+// AddObject appends an object value to the buffer
+func (e *Encoder) AddObject(obj types.Object) error {
+	e.addSeparator()
+	e.buf.AppendByte('{')
+	if err := obj.MarshalObject(e); err != nil {
+		return err
+	}
+	e.buf.AppendByte('}')
+	return nil
+}
+
 // StartArray appends the necessary bytes to begin a json array
 func (e *Encoder) StartArray() error {
 	e.addSeparator()
@@ -0,0 +1,425 @@
+package logfmt
+
+import (
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/autopilothq/lg/encoding/buffer"
+	"github.com/autopilothq/lg/encoding/text"
+	"github.com/autopilothq/lg/encoding/types"
+)
+
+// Encoder can encode Go types to logfmt, i.e. space separated key=value
+// pairs.
+//
+// As logfmt has no notion of nesting, the keys of nested objects are joined
+// to the key of the object they are in with a '.', and arrays are rendered
+// into a single value using the text encoder.
+type Encoder struct {
+	buf        *buffer.Buffer
+	timeFormat *types.TimeFormat
+
+	// key is the key that the next value will be written with
+	key string
+
+	// groups holds the keys of the objects currently being encoded
+	groups []string
+
+	// sub is the encoder that values nested within an array are rendered by,
+	// and depth is the number of arrays and objects open within it
+	sub   *text.Encoder
+	depth int
+}
+
+// NewEncoder returns a new Encoder
+func NewEncoder() *Encoder {
+	return &Encoder{
+		buf: buffer.GetBuffer(),
+	}
+}
+
+// String returns the encoded buffer as a string
+func (e *Encoder) String() string {
+	return e.buf.String()
+}
+
+// Bytes returns the raw encoded bytes
+func (e *Encoder) Bytes() []byte {
+	return e.buf.Bytes()
+}
+
+// SetTimeFormat sets the format that times are encoded with. A nil format
+// restores the default.
+func (e *Encoder) SetTimeFormat(f *types.TimeFormat) {
+	e.timeFormat = f
+}
+
+// AddKey sets the key that the next value will be appended with
+func (e *Encoder) AddKey(key string) error {
+	if e.sub != nil {
+		return e.sub.AddKey(key)
+	}
+
+	e.key = key
+	return nil
+}
+
+// AddUint16 appends a uint16 to the buffer
+func (e *Encoder) AddUint16(val uint16) error {
+	return e.AddUint64(uint64(val))
+}
+
+// AddUint32 appends a uint32 to the buffer
+func (e *Encoder) AddUint32(val uint32) error {
+	return e.AddUint64(uint64(val))
+}
+
+// AddUint64 appends a uint64 to the buffer
+func (e *Encoder) AddUint64(val uint64) error {
+	if e.sub != nil {
+		return e.sub.AddUint64(val)
+	}
+
+	e.addKey()
+	e.buf.AppendUint(val)
+	return nil
+}
+
+// AddInt16 appends a int16 to the buffer
+func (e *Encoder) AddInt16(val int16) error {
+	return e.AddInt64(int64(val))
+}
+
+// AddInt32 appends a int32 to the buffer
+func (e *Encoder) AddInt32(val int32) error {
+	return e.AddInt64(int64(val))
+}
+
+// AddInt64 appends a int64 to the buffer
+func (e *Encoder) AddInt64(val int64) error {
+	if e.sub != nil {
+		return e.sub.AddInt64(val)
+	}
+
+	e.addKey()
+	e.buf.AppendInt(val)
+	return nil
+}
+
+// AddFloat32 appends a float32 to the buffer
+func (e *Encoder) AddFloat32(val float32) error {
+	return e.AddFloat(float64(val), 32)
+}
+
+// AddFloat64 appends a float64 to the buffer
+func (e *Encoder) AddFloat64(val float64) error {
+	return e.AddFloat(val, 64)
+}
+
+// AddFloat appends a float64 to the buffer
+func (e *Encoder) AddFloat(val float64, bitsize int) error {
+	if e.sub != nil {
+		return e.sub.AddFloat(val, bitsize)
+	}
+
+	e.addKey()
+
+	switch {
+	case math.IsNaN(val):
+		e.buf.AppendString(`NaN`)
+	case math.IsInf(val, 1):
+		e.buf.AppendString(`+Inf`)
+	case math.IsInf(val, -1):
+		e.buf.AppendString(`-Inf`)
+	default:
+		e.buf.AppendFloat(val, bitsize)
+	}
+	return nil
+}
+
+// AddBool appends the boolean value to the buffer
+func (e *Encoder) AddBool(val bool) error {
+	if e.sub != nil {
+		return e.sub.AddBool(val)
+	}
+
+	e.addKey()
+	e.buf.AppendBool(val)
+	return nil
+}
+
+// AddByteString appends the byte string value to the buffer
+func (e *Encoder) AddByteString(val string) error {
+	if e.sub != nil {
+		return e.sub.AddByteString(val)
+	}
+
+	e.addKey()
+	e.buf.AppendString(val)
+	return nil
+}
+
+// AddBytes appends the byte array value to the buffer, quoting it if required
+func (e *Encoder) AddBytes(val []byte) error {
+	if e.sub != nil {
+		return e.sub.AddBytes(val)
+	}
+
+	e.addKey()
+	e.appendValue(string(val))
+	return nil
+}
+
+// AddDuration appends a Duration to the buffer
+func (e *Encoder) AddDuration(val time.Duration) error {
+	if e.sub != nil {
+		return e.sub.AddDuration(val)
+	}
+
+	e.addKey()
+	e.buf.AppendDuration(val)
+	return nil
+}
+
+// AddTime appends a Time to the buffer, quoting it if required. Unless set
+// with SetTimeFormat, the format is: 2006-01-02T15:04:05.000
+func (e *Encoder) AddTime(t time.Time) error {
+	if e.sub != nil {
+		return e.sub.AddTime(t)
+	}
+
+	e.addKey()
+
+	if e.timeFormat == nil {
+		e.buf.AppendTime(t)
+		return nil
+	}
+
+	formatted := buffer.NewBuffer()
+	formatted.AppendFormattedTime(t, e.timeFormat)
+	e.appendValue(formatted.String())
+	return nil
+}
+
+// AddTimestamp appends a Timestamp to the buffer
+func (e *Encoder) AddTimestamp(t time.Time) error {
+	if e.sub != nil {
+		return e.sub.AddTimestamp(t)
+	}
+
+	e.addKey()
+	e.buf.AppendTimestamp(t)
+	return nil
+}
+
+// AddNull appends a null value to the buffer
+func (e *Encoder) AddNull() error {
+	if e.sub != nil {
+		return e.sub.AddNull()
+	}
+
+	e.addKey()
+	e.buf.AppendString("null")
+	return nil
+}
+
+// AddArrayish appends an array value to the buffer
+func (e *Encoder) AddArrayish(arr types.Array) error {
+	if e.sub != nil {
+		return e.sub.AddArrayish(arr)
+	}
+
+	e.startSub()
+	if err := e.sub.AddArrayish(arr); err != nil {
+		return err
+	}
+	e.endSub()
+	return nil
+}
+
+// StartArray begins an array value. The array and everything within it is
+// rendered as a single value.
+func (e *Encoder) StartArray() error {
+	if e.sub == nil {
+		e.startSub()
+	}
+
+	e.depth++
+	return e.sub.StartArray()
+}
+
+// EndArray ends an array value
+func (e *Encoder) EndArray() error {
+	if e.sub == nil {
+		return nil
+	}
+
+	if err := e.sub.EndArray(); err != nil {
+		return err
+	}
+
+	e.depth--
+	if e.depth == 0 {
+		e.endSub()
+	}
+	return nil
+}
+
+// StartObject begins a group of key/values. The keys of all the values within
+// it will be prefixed with the key of the object.
+func (e *Encoder) StartObject() error {
+	if e.sub != nil {
+		e.depth++
+		return e.sub.StartObject()
+	}
+
+	e.groups = append(e.groups, e.key)
+	e.key = ""
+	return nil
+}
+
+// EndObject ends a group of key/values
+func (e *Encoder) EndObject() error {
+	if e.sub != nil {
+		e.depth--
+		return e.sub.EndObject()
+	}
+
+	if len(e.groups) > 0 {
+		e.groups = e.groups[:len(e.groups)-1]
+	}
+	return nil
+}
+
+// AddObject appends an object value to the buffer, as a group of key/values
+func (e *Encoder) AddObject(obj types.Object) error {
+	if e.sub != nil {
+		return e.sub.AddObject(obj)
+	}
+
+	if err := e.StartObject(); err != nil {
+		return err
+	}
+
+	if err := obj.MarshalObject(e); err != nil {
+		return err
+	}
+
+	return e.EndObject()
+}
+
+// AddReflected renders the value via reflection using the text encoder, and
+// appends it to the buffer as a single value
+func (e *Encoder) AddReflected(val interface{}) error {
+	if e.sub != nil {
+		return e.sub.AddReflected(val)
+	}
+
+	e.startSub()
+	if err := e.sub.AddReflected(val); err != nil {
+		return err
+	}
+	e.endSub()
+	return nil
+}
+
+// AddString adds a string to the encoded buffer, quoting it if required
+func (e *Encoder) AddString(s string) error {
+	if e.sub != nil {
+		return e.sub.AddString(s)
+	}
+
+	e.addKey()
+	e.appendValue(s)
+	return nil
+}
+
+func (e *Encoder) startSub() {
+	e.sub = text.NewEncoder()
+	e.sub.SetTimeFormat(e.timeFormat)
+	e.depth = 0
+}
+
+func (e *Encoder) endSub() {
+	sub := e.sub
+	e.sub = nil
+
+	e.addKey()
+	e.appendValue(sub.String())
+}
+
+// addKey appends the separator and the full, dotted key for the next value
+func (e *Encoder) addKey() {
+	if e.buf.Len() > 0 {
+		e.buf.AppendByte(' ')
+	}
+
+	for _, group := range e.groups {
+		if group != "" {
+			e.appendKey(group)
+			e.buf.AppendByte('.')
+		}
+	}
+
+	if e.key == "" && len(e.groups) == 0 {
+		e.buf.AppendString("_")
+	} else {
+		e.appendKey(e.key)
+	}
+	e.buf.AppendByte('=')
+	e.key = ""
+}
+
+// appendKey appends a key, replacing any characters that aren't allowed in
+// keys with underscores
+func (e *Encoder) appendKey(key string) {
+	start := 0
+	for i := 0; i < len(key); i++ {
+		if b := key[i]; b <= ' ' || b == '=' || b == '"' || b == '.' ||
+			b == 0x7f {
+			e.buf.AppendString(key[start:i])
+			e.buf.AppendByte('_')
+			start = i + 1
+		}
+	}
+	e.buf.AppendString(key[start:])
+}
+
+// appendValue appends a string value, quoting it only if it's empty or
+// contains characters that would otherwise make the line ambiguous
+func (e *Encoder) appendValue(s string) {
+	if needsQuoting(s) {
+		e.buf.AppendString(strconv.Quote(s))
+		return
+	}
+
+	e.buf.AppendString(s)
+}
+
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+
+	for i := 0; i < len(s); {
+		b := s[i]
+		if b < utf8.RuneSelf {
+			if b <= ' ' || b == '=' || b == '"' || b == '\\' ||
+				b == 0x7f {
+				return true
+			}
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			return true
+		}
+		i += size
+	}
+
+	return false
+}
@@ -0,0 +1,131 @@
+package logfmt_test
+
+import (
+	"math"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/autopilothq/lg/encoding"
+	. "github.com/autopilothq/lg/encoding/logfmt"
+	"github.com/autopilothq/lg/encoding/types"
+)
+
+type point struct {
+	X, Y int
+}
+
+func (p point) MarshalObject(enc types.Encoder) error {
+	if err := encoding.EncodeKeyValue(enc, "x", p.X); err != nil {
+		return err
+	}
+	return encoding.EncodeKeyValue(enc, "y", p.Y)
+}
+
+var _ = Describe("log encoding logfmt", func() {
+	var (
+		enc *Encoder
+	)
+
+	BeforeEach(func() {
+		enc = NewEncoder()
+	})
+
+	Describe("key/values", func() {
+		It("adds a key/value pair", func() {
+			Expect(encoding.EncodeKeyValue(enc, "key", 1234)).To(Succeed())
+			Expect(enc.String()).To(Equal(`key=1234`))
+		})
+
+		It("separates pairs with spaces", func() {
+			Expect(encoding.EncodeKeyValue(enc, "a", 1)).To(Succeed())
+			Expect(encoding.EncodeKeyValue(enc, "b", true)).To(Succeed())
+			Expect(encoding.EncodeKeyValue(enc, "c", 3.5)).To(Succeed())
+			Expect(enc.String()).To(Equal(`a=1 b=true c=3.5`))
+		})
+
+		It("replaces invalid characters in keys", func() {
+			Expect(encoding.EncodeKeyValue(enc, "a key=\"x\"", 1)).To(Succeed())
+			Expect(enc.String()).To(Equal(`a_key__x_=1`))
+		})
+
+		It("adds special float values", func() {
+			Expect(encoding.EncodeKeyValue(enc, "f", math.NaN())).To(Succeed())
+			Expect(enc.String()).To(Equal(`f=NaN`))
+		})
+
+		It("adds nulls", func() {
+			Expect(encoding.EncodeKeyValue(enc, "n", nil)).To(Succeed())
+			Expect(enc.String()).To(Equal(`n=null`))
+		})
+	})
+
+	Describe("AddString()", func() {
+		It("doesn't quote simple strings", func() {
+			Expect(encoding.EncodeKeyValue(enc, "s", "wut!?")).To(Succeed())
+			Expect(enc.String()).To(Equal(`s=wut!?`))
+		})
+
+		It("quotes strings containing spaces", func() {
+			Expect(encoding.EncodeKeyValue(enc, "s", "a b")).To(Succeed())
+			Expect(enc.String()).To(Equal(`s="a b"`))
+		})
+
+		It("quotes and escapes strings containing quotes and equals", func() {
+			Expect(encoding.EncodeKeyValue(enc, "s", `a="b"`)).To(Succeed())
+			Expect(enc.String()).To(Equal(`s="a=\"b\""`))
+		})
+
+		It("quotes and escapes strings containing newlines", func() {
+			Expect(encoding.EncodeKeyValue(enc, "s", "a\nb")).To(Succeed())
+			Expect(enc.String()).To(Equal(`s="a\nb"`))
+		})
+
+		It("quotes empty strings", func() {
+			Expect(encoding.EncodeKeyValue(enc, "s", "")).To(Succeed())
+			Expect(enc.String()).To(Equal(`s=""`))
+		})
+	})
+
+	Describe("objects", func() {
+		It("prefixes nested keys with the object's key", func() {
+			Expect(encoding.EncodeKeyValue(enc, "req", map[string]interface{}{
+				"id":     1,
+				"method": "GET",
+				"user":   map[string]interface{}{"name": "bob"},
+			})).To(Succeed())
+			Expect(encoding.EncodeKeyValue(enc, "after", 2)).To(Succeed())
+			Expect(enc.String()).To(Equal(
+				`req.id=1 req.method=GET req.user.name=bob after=2`))
+		})
+
+		It("encodes object marshalers", func() {
+			Expect(encoding.EncodeKeyValue(enc, "p", point{1, 2})).To(Succeed())
+			Expect(enc.String()).To(Equal(`p.x=1 p.y=2`))
+		})
+	})
+
+	Describe("arrays", func() {
+		It("renders arrays as a single value", func() {
+			Expect(encoding.EncodeKeyValue(enc, "ids", []int64{1, 2})).To(Succeed())
+			Expect(enc.String()).To(Equal(`ids="[1 2]"`))
+		})
+
+		It("renders nested arrays and objects as a single value", func() {
+			Expect(encoding.EncodeKeyValue(enc, "list", []interface{}{
+				1, []interface{}{"a"}, map[string]interface{}{"b": 2},
+			})).To(Succeed())
+			Expect(encoding.EncodeKeyValue(enc, "after", 2)).To(Succeed())
+			Expect(enc.String()).To(Equal(
+				`list="[1 [\"a\"] {b:2}]" after=2`))
+		})
+	})
+
+	Describe("AddReflected()", func() {
+		It("renders values as a single value", func() {
+			Expect(encoding.EncodeKeyValue(enc, "p", struct{ A int }{1})).
+				To(Succeed())
+			Expect(enc.String()).To(Equal(`p={A:1}`))
+		})
+	})
+})
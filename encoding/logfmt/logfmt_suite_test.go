@@ -0,0 +1,13 @@
+package logfmt_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestLogfmt(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Logfmt Suite")
+}
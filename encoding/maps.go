@@ -0,0 +1,111 @@
+package encoding
+
+import (
+	"reflect"
+	"sort"
+	"sync/atomic"
+
+	"github.com/autopilothq/lg/encoding/types"
+)
+
+// unsortedMapKeys is 1 when map keys aren't to be sorted. It's read on every
+// encode, so is accessed atomically.
+var unsortedMapKeys int32
+
+// SetSortMapKeys controls whether map values are encoded with their keys in
+// sorted order. It defaults to true, so that the same map always produces
+// the same output. Disabling it saves the cost of sorting, but keys will be
+// encoded in Go's randomised map iteration order.
+func SetSortMapKeys(sorted bool) {
+	if sorted {
+		atomic.StoreInt32(&unsortedMapKeys, 0)
+	} else {
+		atomic.StoreInt32(&unsortedMapKeys, 1)
+	}
+}
+
+func sortMapKeys() bool {
+	return atomic.LoadInt32(&unsortedMapKeys) == 0
+}
+
+func sortKeys(keys []string) []string {
+	if sortMapKeys() {
+		sort.Strings(keys)
+	}
+
+	return keys
+}
+
+func mapKeys(kv map[string]interface{}) []string {
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+
+	return sortKeys(keys)
+}
+
+func encodeKeyValues(enc types.Encoder, kv map[string]interface{}) (err error) {
+	for _, k := range mapKeys(kv) {
+		if err = enc.AddKey(k); err != nil {
+			return err
+		}
+
+		if err = EncodeValue(enc, kv[k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func encodeStringMap(enc types.Encoder, kv map[string]string) (err error) {
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+
+	if err = enc.StartObject(); err != nil {
+		return err
+	}
+
+	for _, k := range sortKeys(keys) {
+		if err = EncodeStringKeyValue(enc, k, kv[k]); err != nil {
+			return err
+		}
+	}
+
+	return enc.EndObject()
+}
+
+// isStringKeyedMap reports whether v is a map whose keys have an underlying
+// string type, and can therefore be encoded without encoding/json
+func isStringKeyedMap(v reflect.Value) bool {
+	return v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String
+}
+
+// encodeReflectedMap encodes any map with string-like keys, such as
+// map[string]int or map[MyKey][]string, by encoding each of its values in
+// turn with EncodeValue. Nil maps are encoded as empty objects, like any other
+// map without keys.
+func encodeReflectedMap(enc types.Encoder, v reflect.Value) (err error) {
+	keys := v.MapKeys()
+	if sortMapKeys() {
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+	}
+
+	if err = enc.StartObject(); err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		err = EncodeKeyValue(enc, k.String(), v.MapIndex(k).Interface())
+		if err != nil {
+			return err
+		}
+	}
+
+	return enc.EndObject()
+}
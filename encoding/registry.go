@@ -0,0 +1,114 @@
+package encoding
+
+import (
+	"math"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"sync"
+
+	"github.com/autopilothq/lg/encoding/types"
+)
+
+// EncoderFunc encodes a single value of a registered type
+type EncoderFunc func(enc types.Encoder, value interface{}) error
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[reflect.Type]EncoderFunc)
+)
+
+// RegisterEncoder registers fn as the encoder for values of type t. It's
+// consulted by EncodeValue for any value that it doesn't natively support,
+// before falling back to reflection. Registering a nil fn removes any
+// existing encoder for t.
+//
+// Values that are pointers to a registered type are dereferenced and encoded
+// with the encoder for the pointed to type, unless the pointer type has been
+// registered itself.
+func RegisterEncoder(t reflect.Type, fn EncoderFunc) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if fn == nil {
+		delete(registry, t)
+		return
+	}
+
+	registry[t] = fn
+}
+
+// lookupEncoder returns the registered encoder for value, along with the
+// value it should be invoked with.
+func lookupEncoder(value interface{}) (EncoderFunc, interface{}, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	if len(registry) == 0 {
+		return nil, nil, false
+	}
+
+	t := reflect.TypeOf(value)
+	if fn, ok := registry[t]; ok {
+		return fn, value, true
+	}
+
+	if t.Kind() == reflect.Ptr {
+		if fn, ok := registry[t.Elem()]; ok {
+			v := reflect.ValueOf(value)
+			if v.IsNil() {
+				return encodeNull, nil, true
+			}
+			return fn, v.Elem().Interface(), true
+		}
+	}
+
+	return nil, nil, false
+}
+
+func encodeNull(enc types.Encoder, value interface{}) error {
+	return enc.AddNull()
+}
+
+func encodeIP(enc types.Encoder, value interface{}) error {
+	return enc.AddString(value.(net.IP).String())
+}
+
+func encodeIPNet(enc types.Encoder, value interface{}) error {
+	ipNet := value.(net.IPNet)
+	return enc.AddString(ipNet.String())
+}
+
+func encodeHardwareAddr(enc types.Encoder, value interface{}) error {
+	return enc.AddString(value.(net.HardwareAddr).String())
+}
+
+func encodeURL(enc types.Encoder, value interface{}) error {
+	u := value.(url.URL)
+	return enc.AddString(u.String())
+}
+
+// big numbers are written as raw number literals, so that consumers which
+// can handle arbitrary precision don't lose any of it
+func encodeBigInt(enc types.Encoder, value interface{}) error {
+	i := value.(big.Int)
+	return enc.AddByteString(i.String())
+}
+
+func encodeBigFloat(enc types.Encoder, value interface{}) error {
+	f := value.(big.Float)
+	if f.IsInf() {
+		return enc.AddFloat64(math.Inf(f.Sign()))
+	}
+	return enc.AddByteString(f.Text('g', -1))
+}
+
+func init() {
+	RegisterEncoder(reflect.TypeOf(net.IP{}), encodeIP)
+	RegisterEncoder(reflect.TypeOf(net.IPNet{}), encodeIPNet)
+	RegisterEncoder(reflect.TypeOf(net.HardwareAddr{}), encodeHardwareAddr)
+	RegisterEncoder(reflect.TypeOf(url.URL{}), encodeURL)
+	RegisterEncoder(reflect.TypeOf(big.Int{}), encodeBigInt)
+	RegisterEncoder(reflect.TypeOf(big.Float{}), encodeBigFloat)
+}
@@ -0,0 +1,98 @@
+package encoding_test
+
+import (
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/autopilothq/lg/encoding"
+	fancy "github.com/autopilothq/lg/encoding/json"
+	text "github.com/autopilothq/lg/encoding/text"
+	"github.com/autopilothq/lg/encoding/types"
+)
+
+type celsius struct {
+	degrees float64
+}
+
+func encodeCelsius(enc types.Encoder, value interface{}) error {
+	return enc.AddString(
+		big.NewFloat(value.(celsius).degrees).Text('f', 1) + "C")
+}
+
+var _ = Describe("encoder registry", func() {
+	var (
+		enc *fancy.Encoder
+	)
+
+	BeforeEach(func() {
+		enc = fancy.NewEncoder()
+	})
+
+	Describe("RegisterEncoder()", func() {
+		BeforeEach(func() {
+			RegisterEncoder(reflect.TypeOf(celsius{}), encodeCelsius)
+		})
+
+		AfterEach(func() {
+			RegisterEncoder(reflect.TypeOf(celsius{}), nil)
+		})
+
+		It("uses the registered encoder", func() {
+			Expect(EncodeValue(enc, celsius{21.5})).To(Succeed())
+			Expect(enc.String()).To(Equal(`"21.5C"`))
+		})
+
+		It("uses the registered encoder for pointers to the type", func() {
+			Expect(EncodeValue(enc, &celsius{-4})).To(Succeed())
+			Expect(enc.String()).To(Equal(`"-4.0C"`))
+		})
+
+		It("encodes nil pointers to the type as null", func() {
+			var c *celsius
+			Expect(EncodeValue(enc, c)).To(Succeed())
+			Expect(enc.String()).To(Equal(`null`))
+		})
+
+		It("falls back to reflection once unregistered", func() {
+			RegisterEncoder(reflect.TypeOf(celsius{}), nil)
+			Expect(EncodeValue(enc, celsius{21.5})).To(Succeed())
+			Expect(enc.String()).To(Equal(`{}`))
+		})
+	})
+
+	Describe("built-in encoders", func() {
+		It("encodes net.IP", func() {
+			Expect(EncodeValue(enc, net.ParseIP("10.0.0.1"))).To(Succeed())
+			Expect(enc.String()).To(Equal(`"10.0.0.1"`))
+		})
+
+		It("encodes *url.URL", func() {
+			u, err := url.Parse("https://example.com/a?b=c")
+			Expect(err).To(Succeed())
+			Expect(EncodeValue(enc, u)).To(Succeed())
+			Expect(enc.String()).To(Equal(`"https://example.com/a?b=c"`))
+		})
+
+		It("encodes *big.Int as a number", func() {
+			i, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+			Expect(EncodeValue(enc, i)).To(Succeed())
+			Expect(enc.String()).To(Equal(`123456789012345678901234567890`))
+		})
+
+		It("encodes *big.Float as a number", func() {
+			Expect(EncodeValue(enc, big.NewFloat(1.5))).To(Succeed())
+			Expect(enc.String()).To(Equal(`1.5`))
+		})
+
+		It("encodes into the text encoder", func() {
+			tenc := text.NewEncoder()
+			Expect(EncodeKeyValue(tenc, "ip", net.ParseIP("::1"))).To(Succeed())
+			Expect(tenc.String()).To(Equal(`ip:"::1"`))
+		})
+	})
+})
@@ -1,8 +1,8 @@
 package text
 
 import (
-	"encoding/json"
 	"math"
+	"reflect"
 	"strconv"
 	"time"
 
@@ -12,7 +12,8 @@ import (
 
 // Encoder can encode Go types to JSON
 type Encoder struct {
-	buf *buffer.Buffer
+	buf        *buffer.Buffer
+	timeFormat *types.TimeFormat
 }
 
 // NewEncoder returns a new Encoder
@@ -32,6 +33,12 @@ func (e *Encoder) Bytes() []byte {
 	return e.buf.Bytes()
 }
 
+// SetTimeFormat sets the format that times are encoded with. A nil format
+// restores the default.
+func (e *Encoder) SetTimeFormat(f *types.TimeFormat) {
+	e.timeFormat = f
+}
+
 // AddKey appends the desired key to the buffer
 func (e *Encoder) AddKey(key string) error {
 	e.addSeparator()
@@ -138,11 +145,17 @@ func (e *Encoder) AddDuration(val time.Duration) error {
 }
 
 // AddTime appends a Time to the buffer.
-// 	This is hardcoded to use the format: 2006-01-02T15:04:05.000
+// 	Unless set with SetTimeFormat, the format is: 2006-01-02T15:04:05.000
 //
 func (e *Encoder) AddTime(t time.Time) error {
 	e.addSeparator()
-	e.buf.AppendTime(t)
+
+	if e.timeFormat == nil {
+		e.buf.AppendTime(t)
+		return nil
+	}
+
+	e.buf.AppendFormattedTime(t, e.timeFormat)
 	return nil
 }
 
@@ -169,6 +182,17 @@ func (e *Encoder) AddArrayish(arr types.Array) error {
 	return nil
 }
 
+// AddObject appends an object value to the buffer
+func (e *Encoder) AddObject(obj types.Object) error {
+	e.addSeparator()
+	e.buf.AppendByte('{')
+	if err := obj.MarshalObject(e); err != nil {
+		return err
+	}
+	e.buf.AppendByte('}')
+	return nil
+}
+
 // StartArray appends the necessary bytes to begin a json array
 func (e *Encoder) StartArray() error {
 	e.addSeparator()
@@ -195,16 +219,15 @@ func (e *Encoder) EndObject() error {
 	return nil
 }
 
-// AddReflected encodes the value via json.Marshal and appends it to the buffer
+// AddReflected encodes the value via reflection and appends it to the buffer.
+// Structs are rendered as {key:value ...} using the same field names as
+// encoding/json, pointers are followed and cycles, depth and the number of
+// elements rendered are all limited.
+//
+// See MaxReflectedDepth and MaxReflectedLength.
 func (e *Encoder) AddReflected(val interface{}) error {
-	marshaledVal, err := json.Marshal(val)
-	if err != nil {
-		return err
-	}
-
-	e.addSeparator()
-	_, err = e.buf.Write(marshaledVal)
-	return err
+	r := reflector{enc: e, visited: make(map[uintptr]struct{})}
+	return r.addValue(reflect.ValueOf(val), 0)
 }
 
 func (e *Encoder) addSeparator() {
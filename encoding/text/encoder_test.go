@@ -1,7 +1,9 @@
 package text_test
 
 import (
+	"errors"
 	"math"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -119,4 +121,79 @@ var _ = Describe("log encoding Text", func() {
 			Expect(enc.String()).To(Equal(`"wut!?"`))
 		})
 	})
+
+	Describe("AddReflected()", func() {
+		type inner struct {
+			B string `json:"b"`
+		}
+
+		type outer struct {
+			A       int             `json:"a"`
+			Inner   inner           `json:"inner"`
+			Ptr     *inner          `json:"ptr"`
+			List    []int           `json:"list"`
+			Map     map[string]bool `json:"map,omitempty"`
+			Skipped string          `json:"-"`
+			Err     error           `json:"err"`
+			When    time.Time       `json:"when"`
+			hidden  string
+		}
+
+		type node struct {
+			Name string
+			Next *node
+		}
+
+		It("renders structs as key/value pairs", func() {
+			Expect(enc.AddReflected(outer{
+				A:     1,
+				Inner: inner{"x"},
+				List:  []int{1, 2},
+				Err:   errors.New("boom"),
+				When:  time.Date(2017, time.December, 20, 11, 20, 1, 0, time.UTC),
+			})).To(Succeed())
+			Expect(enc.String()).To(Equal(
+				`{a:1 inner:{b:"x"} ptr:null list:[1 2] err:"boom" ` +
+					`when:2017-12-20T11:20:01.000}`))
+		})
+
+		It("follows pointers", func() {
+			Expect(enc.AddReflected(&outer{Ptr: &inner{"y"}, Map: map[string]bool{
+				"t": true, "f": false,
+			}})).To(Succeed())
+			Expect(enc.String()).To(Equal(
+				`{a:0 inner:{b:""} ptr:{b:"y"} list:null map:{f:false t:true} ` +
+					`err:null when:0001-01-01T00:00:00.000}`))
+		})
+
+		It("guards against cycles", func() {
+			n := &node{Name: "a"}
+			n.Next = &node{Name: "b", Next: n}
+			Expect(enc.AddReflected(n)).To(Succeed())
+			Expect(enc.String()).To(Equal(
+				`{Name:"a" Next:{Name:"b" Next:<cycle>}}`))
+		})
+
+		It("limits the depth rendered", func() {
+			depth := MaxReflectedDepth
+			MaxReflectedDepth = 1
+			defer func() { MaxReflectedDepth = depth }()
+
+			Expect(enc.AddReflected([][]int{{1}, {2}})).To(Succeed())
+			Expect(enc.String()).To(Equal(`[[1] [2]]`))
+
+			enc = NewEncoder()
+			Expect(enc.AddReflected([][][]int{{{1}}})).To(Succeed())
+			Expect(enc.String()).To(Equal(`[[...]]`))
+		})
+
+		It("limits the number of elements rendered", func() {
+			length := MaxReflectedLength
+			MaxReflectedLength = 3
+			defer func() { MaxReflectedLength = length }()
+
+			Expect(enc.AddReflected([]int{1, 2, 3, 4, 5})).To(Succeed())
+			Expect(enc.String()).To(Equal(`[1 2 3 ...]`))
+		})
+	})
 })
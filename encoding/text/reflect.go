@@ -0,0 +1,308 @@
+package text
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/autopilothq/lg/encoding/types"
+)
+
+var (
+	// MaxReflectedDepth is the maximum depth of nested structs, maps, slices
+	// and pointers that AddReflected will render before eliding the rest
+	MaxReflectedDepth = 8
+
+	// MaxReflectedLength is the maximum number of elements of a single map,
+	// slice or array that AddReflected will render before eliding the rest
+	MaxReflectedLength = 64
+)
+
+const elided = "..."
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	objectType        = reflect.TypeOf((*types.Object)(nil)).Elem()
+	arrayType         = reflect.TypeOf((*types.Array)(nil)).Elem()
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// reflector renders arbitrary values into the text encoder, tracking the
+// pointers it's currently inside of so that it can detect cycles.
+type reflector struct {
+	enc     *Encoder
+	visited map[uintptr]struct{}
+}
+
+func (r *reflector) addValue(v reflect.Value, depth int) error {
+	if !v.IsValid() {
+		return r.enc.AddNull()
+	}
+
+	// nil maps are rendered as empty maps, as they are by EncodeValue
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice:
+		if v.IsNil() {
+			return r.enc.AddNull()
+		}
+	}
+
+	if handled, err := r.addMarshaler(v); handled {
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		if depth > MaxReflectedDepth {
+			return r.enc.AddByteString(elided)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return r.enc.AddBool(v.Bool())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return r.enc.AddInt64(v.Int())
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return r.enc.AddUint64(v.Uint())
+
+	case reflect.Float32:
+		return r.enc.AddFloat(v.Float(), 32)
+
+	case reflect.Float64:
+		return r.enc.AddFloat(v.Float(), 64)
+
+	case reflect.Complex64, reflect.Complex128:
+		return r.enc.AddByteString(fmt.Sprint(v.Complex()))
+
+	case reflect.String:
+		return r.enc.AddString(v.String())
+
+	case reflect.Interface:
+		return r.addValue(v.Elem(), depth)
+
+	case reflect.Ptr:
+		return r.addPointer(v, depth)
+
+	case reflect.Struct:
+		return r.addStruct(v, depth)
+
+	case reflect.Map:
+		return r.addMap(v, depth)
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return r.enc.AddString(string(v.Bytes()))
+		}
+		return r.addList(v, depth)
+
+	case reflect.Array:
+		return r.addList(v, depth)
+
+	default:
+		return r.enc.AddByteString("<" + v.Type().String() + ">")
+	}
+}
+
+// addMarshaler renders values which know how to render themselves. It
+// returns false if v isn't one of them.
+func (r *reflector) addMarshaler(v reflect.Value) (bool, error) {
+	t := v.Type()
+	if t == timeType {
+		return true, r.enc.AddTime(v.Interface().(time.Time))
+	}
+
+	if !v.CanInterface() {
+		return false, nil
+	}
+
+	switch {
+	case t.Implements(objectType):
+		return true, r.enc.AddObject(v.Interface().(types.Object))
+
+	case t.Implements(arrayType):
+		return true, r.enc.AddArrayish(v.Interface().(types.Array))
+
+	case t.Implements(errorType):
+		return true, r.enc.AddString(v.Interface().(error).Error())
+
+	case t.Implements(textMarshalerType):
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return true, err
+		}
+		return true, r.enc.AddString(string(b))
+
+	case t.Implements(stringerType):
+		return true, r.enc.AddString(v.Interface().(fmt.Stringer).String())
+	}
+
+	return false, nil
+}
+
+func (r *reflector) addPointer(v reflect.Value, depth int) error {
+	ptr := v.Pointer()
+	if _, seen := r.visited[ptr]; seen {
+		return r.enc.AddByteString("<cycle>")
+	}
+
+	r.visited[ptr] = struct{}{}
+	defer delete(r.visited, ptr)
+
+	return r.addValue(v.Elem(), depth+1)
+}
+
+func (r *reflector) addStruct(v reflect.Value, depth int) (err error) {
+	if err = r.enc.StartObject(); err != nil {
+		return err
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+
+		name, omitEmpty, skip := parseTag(field)
+		if skip {
+			continue
+		}
+
+		fv := v.Field(i)
+		if omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		if err = r.enc.AddKey(name); err != nil {
+			return err
+		}
+
+		if err = r.addValue(fv, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return r.enc.EndObject()
+}
+
+type mapEntry struct {
+	name string
+	key  reflect.Value
+}
+
+func (r *reflector) addMap(v reflect.Value, depth int) (err error) {
+	ptr := v.Pointer()
+	if _, seen := r.visited[ptr]; seen {
+		return r.enc.AddByteString("<cycle>")
+	}
+
+	r.visited[ptr] = struct{}{}
+	defer delete(r.visited, ptr)
+
+	keys := v.MapKeys()
+	entries := make([]mapEntry, len(keys))
+	for i, k := range keys {
+		entries[i] = mapEntry{name: fmt.Sprint(k.Interface()), key: k}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+
+	if err = r.enc.StartObject(); err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		if i == MaxReflectedLength {
+			if err = r.enc.AddByteString(elided); err != nil {
+				return err
+			}
+			break
+		}
+
+		if err = r.enc.AddKey(entry.name); err != nil {
+			return err
+		}
+
+		if err = r.addValue(v.MapIndex(entry.key), depth+1); err != nil {
+			return err
+		}
+	}
+
+	return r.enc.EndObject()
+}
+
+func (r *reflector) addList(v reflect.Value, depth int) (err error) {
+	if err = r.enc.StartArray(); err != nil {
+		return err
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		if i == MaxReflectedLength {
+			if err = r.enc.AddByteString(elided); err != nil {
+				return err
+			}
+			break
+		}
+
+		if err = r.addValue(v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+
+	return r.enc.EndArray()
+}
+
+// parseTag returns the name a struct field should be rendered with, honouring
+// the same json struct tags that encoding/json would.
+func parseTag(field reflect.StructField) (name string, omitEmpty, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+
+	name = field.Name
+	parts := strings.Split(tag, ",")
+	if parts[0] != "" {
+		name = parts[0]
+	}
+
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+
+	return name, omitEmpty, false
+}
+
+// Cribs from https://golang.org/src/encoding/json/encode.go
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -69,6 +69,11 @@ type ArrayEncoder interface {
 	EndObject() error
 }
 
+type ObjectEncoder interface {
+	// AddObject appends an object value to the buffer
+	AddObject(obj Object) error
+}
+
 type NullEncoder interface {
 	// AddNull appends a null value to the buffer
 	AddNull() error
@@ -99,6 +104,7 @@ type Encoder interface {
 	ByteEncoder
 	TimeEncoder
 	ArrayEncoder
+	ObjectEncoder
 	NullEncoder
 	ReflectionEncoder
 	StringEncoder
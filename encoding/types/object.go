@@ -0,0 +1,6 @@
+package types
+
+// Object is a JSON marshalable object
+type Object interface {
+	MarshalObject(enc Encoder) error
+}
@@ -0,0 +1,26 @@
+package types
+
+import (
+	"time"
+)
+
+// TimeFormat describes how time values are encoded
+type TimeFormat struct {
+	// Layout is the time.Format layout to encode times with. If empty, the
+	// default layout of 2006-01-02T15:04:05.000 is used.
+	Layout string
+
+	// Unit, if non-zero, causes times to be encoded as an integer count of
+	// Unit since the Unix epoch, instead of using Layout. It must be one of
+	// time.Second, time.Millisecond, time.Microsecond or time.Nanosecond.
+	Unit time.Duration
+
+	// Location, if not nil, is the location that times are converted to
+	// before being encoded with Layout
+	Location *time.Location
+}
+
+// IsNumeric returns true if times are encoded as numbers
+func (f *TimeFormat) IsNumeric() bool {
+	return f.Unit != 0
+}
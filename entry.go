@@ -7,7 +7,9 @@ import (
 
 	json "github.com/autopilothq/lg/encoding"
 	fancy "github.com/autopilothq/lg/encoding/json"
+	"github.com/autopilothq/lg/encoding/logfmt"
 	text "github.com/autopilothq/lg/encoding/text"
+	"github.com/autopilothq/lg/encoding/types"
 )
 
 // Entry represents a log entry
@@ -17,6 +19,7 @@ type Entry struct {
 	Message   string    `json:"m"`
 	Level     Level     `json:"l,string"`
 	Fields    Fields    `json:"f"`
+	Caller    *Caller   `json:"c,omitempty"`
 }
 
 const (
@@ -24,8 +27,8 @@ const (
 	TimeFormat = "2006-01-02T15:04:05.000"
 )
 
-func (e *Entry) toPlainText() []byte {
-	timeBytes := bytes.NewBufferString(e.Timestamp.Format(TimeFormat))
+func (e *Entry) toPlainText(tf *types.TimeFormat) []byte {
+	timeBytes := bytes.NewBufferString(formatTime(e.Timestamp, tf))
 
 	_, err := timeBytes.WriteString(e.Level.AlignedString())
 	if err != nil {
@@ -41,6 +44,7 @@ func (e *Entry) toPlainText() []byte {
 	var errMsg string
 	if e.Fields.Len() > 0 {
 		enc := text.NewEncoder()
+		enc.SetTimeFormat(tf)
 
 		if err = enc.StartArray(); err != nil {
 			return append([]byte(err.Error()), '\n')
@@ -81,43 +85,45 @@ func makeJSONError(enc *fancy.Encoder, err error) []byte {
 	return append([]byte(b), '\n')
 }
 
-func (e *Entry) toJSON() []byte {
+func (e *Entry) toJSON(tf *types.TimeFormat, schema *JSONSchema) []byte {
+	if schema == nil {
+		schema = &defaultJSONSchema
+	}
+
 	enc := fancy.NewEncoder()
+	enc.SetTimeFormat(tf)
 
 	err := enc.StartObject()
 	if err != nil {
 		return makeJSONError(enc, err)
 	}
 
-	err = json.EncodeTimeKeyValue(enc, "t", e.Timestamp)
+	err = json.EncodeTimeKeyValue(enc, schema.TimeKey, e.Timestamp)
 	if err != nil {
 		return makeJSONError(enc, err)
 	}
 
-	err = json.EncodeStringKeyValue(enc, "l", e.Level.String())
+	err = json.EncodeStringKeyValue(enc, schema.LevelKey, e.Level.String())
 	if err != nil {
 		return makeJSONError(enc, err)
 	}
 
 	if e.Prefix != "" {
-		err = json.EncodeStringKeyValue(enc, "p", e.Prefix)
+		err = json.EncodeStringKeyValue(enc, schema.PrefixKey, e.Prefix)
 		if err != nil {
 			return makeJSONError(enc, err)
 		}
 	}
 
-	if e.Fields.Len() > 0 {
-		if err = enc.AddKey("f"); err != nil {
-			return makeJSONError(enc, err)
-		}
+	if err = schema.encodeStatic(enc); err != nil {
+		return makeJSONError(enc, err)
+	}
 
-		err = e.Fields.encodeJSON(enc)
-		if err != nil {
-			return makeJSONError(enc, err)
-		}
+	if err = schema.encodeFields(enc, &e.Fields); err != nil {
+		return makeJSONError(enc, err)
 	}
 
-	err = json.EncodeStringKeyValue(enc, "m", e.Message)
+	err = json.EncodeStringKeyValue(enc, schema.MessageKey, e.Message)
 	if err != nil {
 		return makeJSONError(enc, err)
 	}
@@ -130,6 +136,45 @@ func (e *Entry) toJSON() []byte {
 	return append(enc.Bytes(), '\n')
 }
 
+func makeLogfmtError(err error) []byte {
+	enc := logfmt.NewEncoder()
+	json.EncodeStringKeyValue(enc, "error", "encoding error: "+err.Error())
+	return append(enc.Bytes(), '\n')
+}
+
+func (e *Entry) toLogfmt(tf *types.TimeFormat) []byte {
+	enc := logfmt.NewEncoder()
+	enc.SetTimeFormat(tf)
+
+	err := json.EncodeTimeKeyValue(enc, "ts", e.Timestamp)
+	if err != nil {
+		return makeLogfmtError(err)
+	}
+
+	err = json.EncodeStringKeyValue(enc, "level", e.Level.String())
+	if err != nil {
+		return makeLogfmtError(err)
+	}
+
+	if e.Prefix != "" {
+		err = json.EncodeStringKeyValue(enc, "prefix", e.Prefix)
+		if err != nil {
+			return makeLogfmtError(err)
+		}
+	}
+
+	err = json.EncodeStringKeyValue(enc, "msg", e.Message)
+	if err != nil {
+		return makeLogfmtError(err)
+	}
+
+	if err = e.Fields.encodeLogfmt(enc); err != nil {
+		return makeLogfmtError(err)
+	}
+
+	return append(enc.Bytes(), '\n')
+}
+
 func makeEntry(level Level, prefix string, args []interface{}) *Entry {
 	fields, remaining := ExtractAllFields(args)
 
@@ -141,6 +186,7 @@ func makeEntry(level Level, prefix string, args []interface{}) *Entry {
 		Message:   message,
 		Level:     level,
 		Fields:    fields,
+		Caller:    getCaller(),
 	}
 }
 
@@ -157,5 +203,6 @@ func makeFormattedEntry(
 		Message:   message,
 		Level:     level,
 		Fields:    fields,
+		Caller:    getCaller(),
 	}
 }
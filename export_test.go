@@ -0,0 +1,51 @@
+package lg
+
+import (
+	"io"
+	"os"
+)
+
+// SetSyslogSockets replaces the paths that a SyslogWriter looks for the local
+// syslog daemon at, and returns a function which restores them
+func SetSyslogSockets(paths ...string) (restore func()) {
+	saved := syslogSockets
+	syslogSockets = paths
+	return func() {
+		syslogSockets = saved
+	}
+}
+
+// SetRenameFile replaces the function that a FileWriter renames files with as
+// it rotates them, and returns a function which restores it
+func SetRenameFile(rename func(from, to string) error) (restore func()) {
+	saved := renameFile
+	renameFile = rename
+	return func() {
+		renameFile = saved
+	}
+}
+
+// SetCompressFile replaces the function that a FileWriter compresses rotated
+// files with, and returns a function which restores it
+func SetCompressFile(compress func(path string) error) (restore func()) {
+	saved := compressRotated
+	compressRotated = compress
+	return func() {
+		compressRotated = saved
+	}
+}
+
+// SetSpoolSegmentWrapper wraps the files that a SpoolWriter spools entries to,
+// and returns a function which restores the default
+func SetSpoolSegmentWrapper(wrap func(f *os.File) io.WriteCloser) (restore func()) {
+	saved := wrapSegment
+	wrapSegment = wrap
+	return func() {
+		wrapSegment = saved
+	}
+}
+
+// RedactURL returns rawurl with any password replaced
+func RedactURL(rawurl string) string {
+	return redactURL(rawurl)
+}
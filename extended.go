@@ -219,3 +219,19 @@ func (e ExtendedLog) Panicf(pattern string, args ...interface{}) {
 	entry, _ := e.addFormattedEntry(LevelFatal, pattern, args)
 	panic(entry.Message)
 }
+
+// Emit logs a message at the given level, and returns an error if any output
+// failed to write it
+func (e ExtendedLog) Emit(level Level, args ...interface{}) error {
+	_, err := e.addEntry(level, args)
+	return err
+}
+
+// Emitf logs a formatted message at the given level, and returns an error if
+// any output failed to write it
+func (e ExtendedLog) Emitf(
+	level Level, pattern string, args ...interface{},
+) error {
+	_, err := e.addFormattedEntry(level, pattern, args)
+	return err
+}
@@ -2,10 +2,14 @@ package lg
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 
 	"github.com/autopilothq/lg/encoding"
 	fancy "github.com/autopilothq/lg/encoding/json"
+	"github.com/autopilothq/lg/encoding/logfmt"
 	text "github.com/autopilothq/lg/encoding/text"
+	"github.com/autopilothq/lg/encoding/types"
 )
 
 // F represents a single pair of log 'fields'
@@ -24,15 +28,73 @@ func (f *Fields) Len() int {
 	return len(f.contents)
 }
 
+// Get returns the value of the field with key, if there is one
+func (f *Fields) Get(key string) (interface{}, bool) {
+	for _, fld := range f.contents {
+		if fld.Key == key {
+			return fld.Val, true
+		}
+	}
+	return nil, false
+}
+
+// All returns a copy of the fields, in order
+func (f *Fields) All() []F {
+	result := make([]F, len(f.contents))
+	copy(result, f.contents)
+	return result
+}
+
 // ErrKey is a reserved for error messages Key
 const ErrKey = "err"
 
-// Err returns error field
+// Err returns error field. The error itself is kept as the value, so that
+// formats which can include a stack trace can render one.
 func Err(err error) F {
 	if err == nil {
 		return F{ErrKey, ""}
 	}
-	return F{ErrKey, err.Error()}
+	return F{ErrKey, err}
+}
+
+// errorStack returns the stack trace of the value of an err field, if it's an
+// error which carries one, i.e. which renders differently with %+v
+func errorStack(val interface{}) string {
+	err, ok := val.(error)
+	if !ok {
+		return ""
+	}
+
+	if detailed := fmt.Sprintf("%+v", err); detailed != err.Error() {
+		return detailed
+	}
+	return ""
+}
+
+// fieldText renders a field value as a single string, for formats whose
+// fields can only hold strings. Values which encode as strings, such as
+// times, are rendered as the string itself rather than as quoted JSON, and
+// anything else, e.g. numbers, arrays and objects, as its JSON encoding.
+func fieldText(val interface{}, tf *types.TimeFormat) (string, error) {
+	if s, ok := val.(string); ok {
+		return s, nil
+	}
+
+	enc := fancy.NewEncoder()
+	enc.SetTimeFormat(tf)
+	if err := encoding.EncodeValue(enc, val); err != nil {
+		return "", err
+	}
+
+	b := enc.Bytes()
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err == nil {
+			return s, nil
+		}
+	}
+
+	return string(b), nil
 }
 
 // ErrMsg returns error field
@@ -115,3 +177,27 @@ func (f *Fields) encodeText(enc *text.Encoder) (errMsg string, err error) {
 	}
 	return
 }
+
+// encodeLogfmt allows Fields to be marshaled to logfmt via the encoder. The
+// error field, if there is one, is always encoded last.
+func (f *Fields) encodeLogfmt(enc *logfmt.Encoder) (err error) {
+	var errVal interface{}
+	hasErr := false
+
+	for _, fld := range f.contents {
+		if fld.Key == ErrKey {
+			errVal, hasErr = fld.Val, true
+			continue
+		}
+
+		if err = encoding.EncodeKeyValue(enc, fld.Key, fld.Val); err != nil {
+			return err
+		}
+	}
+
+	if hasErr {
+		return encoding.EncodeStringKeyValue(enc, ErrKey, RenderMessage(errVal))
+	}
+
+	return nil
+}
@@ -0,0 +1,511 @@
+package lg
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedTimeFormat is the layout of the timestamps that rotated files are
+// named with
+const rotatedTimeFormat = "2006-01-02T15-04-05.000"
+
+// renameFile renames files as they're rotated, and compressRotated
+// compresses them
+var (
+	renameFile      = os.Rename
+	compressRotated = compressFile
+)
+
+// FileOptions configures a FileWriter
+type FileOptions struct {
+	maxSize  int64
+	interval time.Duration
+	maxFiles int
+	maxAge   time.Duration
+	compress bool
+
+	syncEvery    int
+	syncInterval time.Duration
+}
+
+// RotateSize rotates the file once writing to it would take it beyond size
+// bytes
+func RotateSize(size int64) func(*FileOptions) {
+	return func(o *FileOptions) {
+		o.maxSize = size
+	}
+}
+
+// RotateEvery rotates the file at every multiple of interval since the zero
+// time, e.g. on the hour with time.Hour, or at midnight UTC with 24 hours
+func RotateEvery(interval time.Duration) func(*FileOptions) {
+	return func(o *FileOptions) {
+		o.interval = interval
+	}
+}
+
+// MaxFiles removes the oldest rotated files once there are more than n
+func MaxFiles(n int) func(*FileOptions) {
+	return func(o *FileOptions) {
+		o.maxFiles = n
+	}
+}
+
+// MaxAge removes rotated files once they are older than age
+func MaxAge(age time.Duration) func(*FileOptions) {
+	return func(o *FileOptions) {
+		o.maxAge = age
+	}
+}
+
+// Compress gzips rotated files in the background
+func Compress() func(*FileOptions) {
+	return func(o *FileOptions) {
+		o.compress = true
+	}
+}
+
+// SyncEvery flushes the file to disk with fsync after every n writes, before
+// the nth Write returns. With n = 1 every entry is on disk once it has been
+// logged; use Emit to find out if it wasn't.
+func SyncEvery(n int) func(*FileOptions) {
+	return func(o *FileOptions) {
+		o.syncEvery = n
+	}
+}
+
+// SyncInterval flushes the file to disk with fsync every interval, in the
+// background, if it has been written to
+func SyncInterval(interval time.Duration) func(*FileOptions) {
+	return func(o *FileOptions) {
+		o.syncInterval = interval
+	}
+}
+
+// FileWriter writes to a file, rotating it according to its size and/or
+// age. Rotated files are renamed with the time they were rotated, e.g.
+// app.log becomes app-2017-09-15T00-16-43.848.log, and are optionally
+// compressed and removed after a time, in the background. It is safe for
+// concurrent use.
+//
+// Example:
+//
+//   // rotate daily, or when the file reaches 100MB, and keep a week of logs
+//   f, err := lg.FileOutput("/var/log/app.log",
+//     lg.RotateEvery(24*time.Hour), lg.RotateSize(100<<20),
+//     lg.MaxAge(7*24*time.Hour), lg.Compress())
+//   if err != nil {
+//     panic(err)
+//   }
+//   lg.AddOutput(f, lg.JSON())
+type FileWriter struct {
+	path    string
+	options FileOptions
+
+	mutex        sync.Mutex
+	file         *os.File
+	size         int64
+	nextRotation time.Time
+
+	// unsynced is the number of writes since the file was last synced
+	unsynced int
+
+	// rotated holds the files rotated since the cleanup loop last ran, which
+	// is signalled through cleanup
+	rotated []string
+	cleanup chan struct{}
+
+	done     chan struct{}
+	stopSync chan struct{}
+}
+
+// FileOutput opens the file at path for appending, creating it and its
+// directory if needed, and returns a writer which rotates it according to
+// opts
+func FileOutput(path string, opts ...func(*FileOptions)) (*FileWriter, error) {
+	w := &FileWriter{
+		path:    path,
+		cleanup: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(&w.options)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	go w.cleanupLoop()
+
+	if w.options.syncInterval > 0 {
+		w.stopSync = make(chan struct{})
+		go w.syncLoop()
+	}
+
+	return w, nil
+}
+
+func (w *FileWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	w.file = f
+	w.size = info.Size()
+	w.unsynced = 0
+
+	if w.options.interval > 0 {
+		w.nextRotation = time.Now().Truncate(w.options.interval).
+			Add(w.options.interval)
+	}
+
+	return nil
+}
+
+// Write appends p to the file, rotating it first if needed
+func (w *FileWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	// if the file couldn't be rotated but is still open, p is written to it
+	// anyway, and the failure returned afterwards
+	var rotateErr error
+	if w.shouldRotate(len(p)) {
+		if rotateErr = w.rotate(); rotateErr != nil && w.file == nil {
+			return 0, rotateErr
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	w.unsynced++
+	if err != nil {
+		return n, err
+	}
+
+	if w.options.syncEvery > 0 && w.unsynced >= w.options.syncEvery {
+		if err = w.sync(); err != nil {
+			return n, err
+		}
+	}
+
+	return n, rotateErr
+}
+
+// Sync flushes anything written to the file to disk
+func (w *FileWriter) Sync() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return os.ErrClosed
+	}
+
+	return w.sync()
+}
+
+func (w *FileWriter) sync() error {
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+
+	w.unsynced = 0
+	return nil
+}
+
+// syncLoop syncs the file every sync interval, in the background
+func (w *FileWriter) syncLoop() {
+	ticker := time.NewTicker(w.options.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.mutex.Lock()
+			if w.file != nil && w.unsynced > 0 {
+				if err := w.sync(); err != nil {
+					fmt.Fprintf(os.Stderr, "lg: unable to sync %s: %s\n", w.path, err)
+				}
+			}
+			w.mutex.Unlock()
+
+		case <-w.stopSync:
+			return
+		}
+	}
+}
+
+func (w *FileWriter) shouldRotate(n int) bool {
+	if w.options.maxSize > 0 && w.size > 0 &&
+		w.size+int64(n) > w.options.maxSize {
+		return true
+	}
+
+	return w.options.interval > 0 && !time.Now().Before(w.nextRotation)
+}
+
+// Rotate rotates the file immediately
+func (w *FileWriter) Rotate() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return os.ErrClosed
+	}
+
+	return w.rotate()
+}
+
+func (w *FileWriter) rotate() error {
+	if w.unsynced > 0 && (w.options.syncEvery > 0 || w.options.syncInterval > 0) {
+		if err := w.sync(); err != nil {
+			return err
+		}
+	}
+
+	if err := w.file.Close(); err != nil {
+		// the file can't be written to any more, so reopen it rather than
+		// losing entries
+		w.file = nil
+		if openErr := w.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	w.file = nil
+
+	rotated := w.rotatedName(time.Now())
+	if err := renameFile(w.path, rotated); err != nil && !os.IsNotExist(err) {
+		// carry on writing to the current file rather than losing entries
+		if openErr := w.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	// the cleanup loop is only signalled, so that writing never waits for
+	// earlier files to be compressed
+	w.rotated = append(w.rotated, rotated)
+	select {
+	case w.cleanup <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// rotatedName returns the name that the file is renamed to when rotated at t
+func (w *FileWriter) rotatedName(t time.Time) string {
+	ext := filepath.Ext(w.path)
+	base := strings.TrimSuffix(w.path, ext)
+	name := base + "-" + t.UTC().Format(rotatedTimeFormat) + ext
+
+	// rotating more than once a millisecond is unlikely, but possible
+	for i := 1; fileExists(name) || fileExists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s-%s.%d%s",
+			base, t.UTC().Format(rotatedTimeFormat), i, ext)
+	}
+
+	return name
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Close closes the file, and waits for any compression and removal of
+// rotated files to finish
+func (w *FileWriter) Close() error {
+	w.mutex.Lock()
+	if w.file == nil {
+		w.mutex.Unlock()
+		return nil
+	}
+
+	var err error
+	if w.unsynced > 0 && (w.options.syncEvery > 0 || w.options.syncInterval > 0) {
+		err = w.sync()
+	}
+
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+	close(w.cleanup)
+	if w.stopSync != nil {
+		close(w.stopSync)
+	}
+	w.mutex.Unlock()
+
+	<-w.done
+	return err
+}
+
+// cleanupLoop compresses rotated files and removes old ones, in the
+// background
+func (w *FileWriter) cleanupLoop() {
+	defer close(w.done)
+
+	for range w.cleanup {
+		w.mutex.Lock()
+		rotated := w.rotated
+		w.rotated = nil
+		w.mutex.Unlock()
+
+		if w.options.compress {
+			for _, path := range rotated {
+				if err := compressRotated(path); err != nil {
+					fmt.Fprintf(os.Stderr, "lg: unable to compress %s: %s\n", path, err)
+				}
+			}
+		}
+
+		if err := w.removeOld(); err != nil {
+			fmt.Fprintf(os.Stderr, "lg: unable to remove old logs: %s\n", err)
+		}
+	}
+}
+
+func compressFile(path string) (err error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(path + ".gz")
+		}
+	}()
+
+	zw := gzip.NewWriter(out)
+	if _, err = io.Copy(zw, in); err != nil {
+		return err
+	}
+
+	if err = zw.Close(); err != nil {
+		return err
+	}
+
+	if err = out.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+type rotatedFile struct {
+	path    string
+	rotated time.Time
+	seq     int
+}
+
+// rotatedFiles returns the rotated files of the writer, newest first
+func (w *FileWriter) rotatedFiles() ([]rotatedFile, error) {
+	dir := filepath.Dir(w.path)
+	ext := filepath.Ext(w.path)
+	prefix := strings.TrimSuffix(filepath.Base(w.path), ext) + "-"
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []rotatedFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		stamp := strings.TrimPrefix(name, prefix)
+		if len(stamp) < len(rotatedTimeFormat) {
+			continue
+		}
+
+		t, err := time.Parse(rotatedTimeFormat, stamp[:len(rotatedTimeFormat)])
+		if err != nil {
+			continue
+		}
+
+		// files rotated within the same millisecond have a sequence number
+		// after the timestamp
+		seq := 0
+		rest := stamp[len(rotatedTimeFormat):]
+		if strings.HasPrefix(rest, ".") {
+			fmt.Sscanf(rest[1:], "%d", &seq)
+		}
+
+		files = append(files, rotatedFile{filepath.Join(dir, name), t, seq})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].rotated.Equal(files[j].rotated) {
+			return files[i].seq > files[j].seq
+		}
+		return files[i].rotated.After(files[j].rotated)
+	})
+
+	return files, nil
+}
+
+func (w *FileWriter) removeOld() error {
+	if w.options.maxFiles <= 0 && w.options.maxAge <= 0 {
+		return nil
+	}
+
+	files, err := w.rotatedFiles()
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-w.options.maxAge)
+	for i, file := range files {
+		tooMany := w.options.maxFiles > 0 && i >= w.options.maxFiles
+		tooOld := w.options.maxAge > 0 && file.rotated.Before(cutoff)
+
+		if tooMany || tooOld {
+			if err := os.Remove(file.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
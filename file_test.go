@@ -0,0 +1,292 @@
+package lg_test
+
+import (
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/autopilothq/lg"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FileOutput", func() {
+
+	var dir, path string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "lg-file")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "logs", "app.log")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	files := func() []string {
+		entries, err := ioutil.ReadDir(filepath.Dir(path))
+		Expect(err).NotTo(HaveOccurred())
+
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	read := func(name string) string {
+		b, err := ioutil.ReadFile(filepath.Join(filepath.Dir(path), name))
+		Expect(err).NotTo(HaveOccurred())
+		return string(b)
+	}
+
+	It("creates the file and appends to it", func() {
+		w, err := lg.FileOutput(path)
+		Expect(err).NotTo(HaveOccurred())
+		w.Write([]byte("one\n"))
+		Expect(w.Close()).To(Succeed())
+
+		w, err = lg.FileOutput(path)
+		Expect(err).NotTo(HaveOccurred())
+		w.Write([]byte("two\n"))
+		Expect(w.Close()).To(Succeed())
+
+		Expect(files()).To(Equal([]string{"app.log"}))
+		Expect(read("app.log")).To(Equal("one\ntwo\n"))
+	})
+
+	It("rotates when the file would grow beyond the maximum size", func() {
+		w, err := lg.FileOutput(path, lg.RotateSize(10))
+		Expect(err).NotTo(HaveOccurred())
+
+		w.Write([]byte("12345\n"))
+		w.Write([]byte("678\n"))
+		w.Write([]byte("abcdef\n"))
+		Expect(w.Close()).To(Succeed())
+
+		names := files()
+		Expect(names).To(HaveLen(2))
+		Expect(names[0]).To(MatchRegexp(
+			`^app-[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}-[0-9]{2}-[0-9]{2}\.[0-9]{3}\.log$`))
+		Expect(names[1]).To(Equal("app.log"))
+
+		Expect(read(names[0])).To(Equal("12345\n678\n"))
+		Expect(read("app.log")).To(Equal("abcdef\n"))
+	})
+
+	It("keeps writing to the file if it can't be rotated", func() {
+		defer lg.SetRenameFile(func(from, to string) error {
+			return errors.New("rename failed")
+		})()
+
+		w, err := lg.FileOutput(path, lg.RotateSize(10))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = w.Write([]byte("12345\n"))
+		Expect(err).NotTo(HaveOccurred())
+
+		n, err := w.Write([]byte("abcdef\n"))
+		Expect(err).To(MatchError("rename failed"))
+		Expect(n).To(Equal(7))
+		Expect(w.Close()).To(Succeed())
+
+		Expect(files()).To(Equal([]string{"app.log"}))
+		Expect(read("app.log")).To(Equal("12345\nabcdef\n"))
+	})
+
+	It("rotates on time boundaries", func() {
+		w, err := lg.FileOutput(path, lg.RotateEvery(50*time.Millisecond))
+		Expect(err).NotTo(HaveOccurred())
+
+		w.Write([]byte("before\n"))
+		time.Sleep(60 * time.Millisecond)
+		w.Write([]byte("after\n"))
+		Expect(w.Close()).To(Succeed())
+
+		names := files()
+		Expect(len(names)).To(BeNumerically(">=", 2))
+		Expect(read(names[len(names)-2])).To(Equal("before\n"))
+		Expect(read("app.log")).To(Equal("after\n"))
+	})
+
+	It("keeps at most the given number of rotated files", func() {
+		w, err := lg.FileOutput(path, lg.MaxFiles(2))
+		Expect(err).NotTo(HaveOccurred())
+
+		for i := 0; i < 5; i++ {
+			w.Write([]byte{byte('a' + i), '\n'})
+			Expect(w.Rotate()).To(Succeed())
+		}
+		Expect(w.Close()).To(Succeed())
+
+		names := files()
+		Expect(names).To(HaveLen(3))
+		Expect([]string{read(names[0]), read(names[1])}).To(
+			ConsistOf("d\n", "e\n"))
+	})
+
+	It("removes rotated files older than the maximum age", func() {
+		old := filepath.Join(filepath.Dir(path), "app-2001-01-01T00-00-00.000.log.gz")
+		other := filepath.Join(filepath.Dir(path), "other-2001-01-01T00-00-00.000.log")
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(old, nil, 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(other, nil, 0644)).To(Succeed())
+
+		w, err := lg.FileOutput(path, lg.MaxAge(time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		w.Write([]byte("a\n"))
+		Expect(w.Rotate()).To(Succeed())
+		Expect(w.Close()).To(Succeed())
+
+		names := files()
+		Expect(names).To(HaveLen(3))
+		Expect(names).NotTo(ContainElement(filepath.Base(old)))
+		Expect(names).To(ContainElement(filepath.Base(other)))
+	})
+
+	It("compresses rotated files", func() {
+		w, err := lg.FileOutput(path, lg.Compress())
+		Expect(err).NotTo(HaveOccurred())
+		w.Write([]byte("compress me\n"))
+		Expect(w.Rotate()).To(Succeed())
+		Expect(w.Close()).To(Succeed())
+
+		names := files()
+		Expect(names).To(HaveLen(2))
+		Expect(names[0]).To(HaveSuffix(".log.gz"))
+
+		f, err := os.Open(filepath.Join(filepath.Dir(path), names[0]))
+		Expect(err).NotTo(HaveOccurred())
+		defer f.Close()
+
+		zr, err := gzip.NewReader(f)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.ReadAll(zr)).To(Equal([]byte("compress me\n")))
+	})
+
+	It("doesn't wait for rotated files to be compressed", func() {
+		release := make(chan struct{})
+		var compressed int32
+		defer lg.SetCompressFile(func(string) error {
+			<-release
+			atomic.AddInt32(&compressed, 1)
+			return nil
+		})()
+
+		w, err := lg.FileOutput(path, lg.Compress())
+		Expect(err).NotTo(HaveOccurred())
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 20; i++ {
+				w.Write([]byte("entry\n"))
+				w.Rotate()
+			}
+		}()
+
+		Eventually(done).Should(BeClosed())
+		close(release)
+		Expect(w.Close()).To(Succeed())
+		Expect(atomic.LoadInt32(&compressed)).To(Equal(int32(20)))
+	})
+
+	It("can be written to concurrently", func() {
+		w, err := lg.FileOutput(path, lg.RotateSize(100))
+		Expect(err).NotTo(HaveOccurred())
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					w.Write([]byte("0123456789\n"))
+				}
+			}()
+		}
+		wg.Wait()
+		Expect(w.Close()).To(Succeed())
+
+		var all string
+		for _, name := range files() {
+			contents := read(name)
+			Expect(len(contents)).To(BeNumerically("<=", 100))
+			all += contents
+		}
+		Expect(strings.Count(all, "0123456789\n")).To(Equal(400))
+		Expect(all).To(HaveLen(400 * 11))
+	})
+
+	It("fails to write once closed", func() {
+		w, err := lg.FileOutput(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+
+		_, err = w.Write([]byte("a\n"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("durability", func() {
+
+		BeforeEach(func() {
+			lg.RemoveOutput(os.Stdout)
+		})
+
+		AfterEach(func() {
+			lg.AddOutput(os.Stdout)
+		})
+
+		It("syncs after every n writes", func() {
+			w, err := lg.FileOutput(path, lg.SyncEvery(2))
+			Expect(err).NotTo(HaveOccurred())
+
+			lg.AddOutput(w)
+			defer lg.RemoveOutput(w)
+
+			Expect(lg.Emit(lg.LevelInfo, "one")).To(Succeed())
+			Expect(lg.Emit(lg.LevelInfo, "two")).To(Succeed())
+			Expect(w.Close()).To(Succeed())
+
+			Expect(read("app.log")).To(MatchRegexp(`one\n.*two\n$`))
+		})
+
+		It("syncs in the background at an interval", func() {
+			w, err := lg.FileOutput(path, lg.SyncInterval(time.Millisecond))
+			Expect(err).NotTo(HaveOccurred())
+
+			w.Write([]byte("a\n"))
+			time.Sleep(5 * time.Millisecond)
+			Expect(w.Close()).To(Succeed())
+			Expect(read("app.log")).To(Equal("a\n"))
+		})
+
+		It("returns write failures to Emit", func() {
+			w, err := lg.FileOutput(path, lg.SyncEvery(1))
+			Expect(err).NotTo(HaveOccurred())
+
+			lg.AddOutput(w)
+			defer lg.RemoveOutput(w)
+
+			Expect(w.Close()).To(Succeed())
+
+			err = lg.Emit(lg.LevelInfo, "lost")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(os.ErrClosed.Error()))
+
+			err = lg.ExtendWithPrefix("Audit").(lg.Emitter).Emitf(lg.LevelWarn, "lost %d", 2)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,160 @@
+package lg
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/autopilothq/lg/encoding"
+	fancy "github.com/autopilothq/lg/encoding/json"
+)
+
+// GELFVersion is the version of the Graylog Extended Log Format that the GELF
+// format conforms to
+const GELFVersion = "1.1"
+
+var gelfInvalidKeyChars = regexp.MustCompile(`[^\w.\-]`)
+
+// gelfFormatter renders entries as GELF messages
+type gelfFormatter struct {
+	host string
+}
+
+func newGELFFormatter(options *Options) *gelfFormatter {
+	host := options.hostname
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+
+	if host == "" {
+		host = "localhost"
+	}
+
+	return &gelfFormatter{host: host}
+}
+
+// gelfKey returns the key of an additional field. Characters that aren't
+// allowed are replaced with underscores, and "_id", which is reserved, and
+// the keys written for the prefix and caller are renamed.
+func gelfKey(key string) string {
+	key = "_" + gelfInvalidKeyChars.ReplaceAllLiteralString(key, "_")
+	switch key {
+	case "_id", "_prefix", "_file", "_line":
+		return key + "_"
+	}
+	return key
+}
+
+func (g *gelfFormatter) format(e *Entry) []byte {
+	enc := fancy.NewEncoder()
+
+	if err := g.encode(enc, e); err != nil {
+		return makeJSONError(enc, err)
+	}
+
+	return append(enc.Bytes(), '\n')
+}
+
+func (g *gelfFormatter) encode(enc *fancy.Encoder, e *Entry) (err error) {
+	if err = enc.StartObject(); err != nil {
+		return err
+	}
+
+	if err = encoding.EncodeStringKeyValue(enc, "version", GELFVersion); err != nil {
+		return err
+	}
+
+	if err = encoding.EncodeStringKeyValue(enc, "host", g.host); err != nil {
+		return err
+	}
+
+	short, full := e.Message, ""
+	if idx := strings.IndexByte(e.Message, '\n'); idx >= 0 {
+		short, full = e.Message[:idx], e.Message
+	}
+
+	for _, fld := range e.Fields.contents {
+		if fld.Key != ErrKey {
+			continue
+		}
+
+		if stack := errorStack(fld.Val); stack != "" {
+			if full == "" {
+				full = e.Message
+			}
+			full += "\n" + stack
+		}
+	}
+
+	if err = encoding.EncodeStringKeyValue(enc, "short_message", short); err != nil {
+		return err
+	}
+
+	if full != "" {
+		if err = encoding.EncodeStringKeyValue(enc, "full_message", full); err != nil {
+			return err
+		}
+	}
+
+	// timestamps are seconds since the epoch, with milliseconds
+	if err = enc.AddKey("timestamp"); err != nil {
+		return err
+	}
+
+	millis := e.Timestamp.UnixNano() / 1e6
+	ts := strconv.FormatInt(millis/1000, 10) + "." +
+		strconv.FormatInt(1000+millis%1000, 10)[1:]
+	if err = enc.AddByteString(ts); err != nil {
+		return err
+	}
+
+	if err = encoding.EncodeKeyValue(enc, "level", syslogSeverity(e.Level)); err != nil {
+		return err
+	}
+
+	if e.Prefix != "" {
+		if err = encoding.EncodeStringKeyValue(enc, "_prefix", e.Prefix); err != nil {
+			return err
+		}
+	}
+
+	if e.Caller != nil {
+		if err = encoding.EncodeStringKeyValue(enc, "_file", e.Caller.File); err != nil {
+			return err
+		}
+
+		if err = encoding.EncodeKeyValue(enc, "_line", e.Caller.Line); err != nil {
+			return err
+		}
+	}
+
+	for _, fld := range e.Fields.contents {
+		if err = encodeGELFField(enc, gelfKey(fld.Key), fld.Val); err != nil {
+			return err
+		}
+	}
+
+	return enc.EndObject()
+}
+
+// encodeGELFField writes an additional field. GELF only allows strings and
+// numbers, so any other values are rendered as strings, e.g. times as
+// 2017-01-02T03:04:05.000, and arrays and objects as JSON.
+func encodeGELFField(enc *fancy.Encoder, key string, val interface{}) error {
+	switch v := val.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+		float32, float64, string:
+		return encoding.EncodeKeyValue(enc, key, v)
+
+	case error:
+		return encoding.EncodeStringKeyValue(enc, key, v.Error())
+	}
+
+	text, err := fieldText(val, nil)
+	if err != nil {
+		return err
+	}
+
+	return encoding.EncodeStringKeyValue(enc, key, text)
+}
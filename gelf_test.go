@@ -0,0 +1,238 @@
+package lg_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/autopilothq/lg"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GELF output", func() {
+
+	var tlo *TestLogOutput
+
+	BeforeEach(func() {
+		tlo = &TestLogOutput{}
+		lg.RemoveOutput(os.Stdout)
+	})
+
+	AfterEach(func() {
+		lg.RemoveOutput(tlo)
+		lg.AddOutput(os.Stdout)
+	})
+
+	decode := func(b []byte) map[string]interface{} {
+		var result map[string]interface{}
+		Expect(json.Unmarshal(b, &result)).To(Succeed())
+		return result
+	}
+
+	It("renders entries as GELF messages", func() {
+		lg.AddOutput(tlo, lg.GELF(), lg.Hostname("web1"))
+		lg.ExtendWithPrefix("Server").Warn("careful",
+			lg.F{"id", 1},
+			lg.F{"user name", "bob"},
+			lg.F{"tags", []string{"a", "b"}},
+			lg.Err(errors.New("it broke")),
+		)
+
+		Expect(tlo.String()).To(MatchRegexp(
+			`^\{"version":"1.1","host":"web1","short_message":"careful",` +
+				`"timestamp":[0-9]{10}\.[0-9]{3},"level":4,`))
+
+		msg := decode(tlo.Bytes())
+		Expect(msg).To(HaveKeyWithValue("_prefix", "Server"))
+		Expect(msg).To(HaveKeyWithValue("_id_", float64(1)))
+		Expect(msg).To(HaveKeyWithValue("_user_name", "bob"))
+		Expect(msg).To(HaveKeyWithValue("_tags", `["a","b"]`))
+		Expect(msg).To(HaveKeyWithValue("_err", "it broke"))
+		Expect(msg).NotTo(HaveKey("full_message"))
+	})
+
+	It("writes values which aren't strings or numbers as plain strings", func() {
+		lg.AddOutput(tlo, lg.GELF())
+		lg.Info("hi",
+			lg.F{"when", time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)},
+			lg.F{"ok", true},
+		)
+
+		Expect(tlo.String()).To(ContainSubstring(`"_when":"2017-01-02T03:04:05.000"`))
+		Expect(decode(tlo.Bytes())).To(HaveKeyWithValue("_ok", "true"))
+	})
+
+	It("renames fields which collide with the prefix", func() {
+		lg.AddOutput(tlo, lg.GELF())
+		lg.ExtendWithPrefix("Server").Info("hi", lg.F{"prefix", "mine"})
+
+		msg := decode(tlo.Bytes())
+		Expect(msg).To(HaveKeyWithValue("_prefix", "Server"))
+		Expect(msg).To(HaveKeyWithValue("_prefix_", "mine"))
+	})
+
+	It("writes multi-line messages and stack traces as full_message", func() {
+		lg.AddOutput(tlo, lg.GELF())
+		lg.Error("failed\nbadly", lg.Err(tracedError{"it broke"}))
+
+		msg := decode(tlo.Bytes())
+		Expect(msg).To(HaveKeyWithValue("short_message", "failed"))
+		Expect(msg).To(HaveKeyWithValue("full_message",
+			"failed\nbadly\nit broke\nmain.main\n\tmain.go:12"))
+		Expect(msg).To(HaveKeyWithValue("level", float64(3)))
+	})
+
+	Describe("GELFWriter", func() {
+
+		listenUDP := func() *net.UDPConn {
+			conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			Expect(err).NotTo(HaveOccurred())
+			return conn
+		}
+
+		readUDP := func(conn *net.UDPConn) []byte {
+			buf := make([]byte, 65536)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			n, err := conn.Read(buf)
+			Expect(err).NotTo(HaveOccurred())
+			return buf[:n]
+		}
+
+		It("sends messages over UDP", func() {
+			conn := listenUDP()
+			defer conn.Close()
+
+			w, err := lg.NewGELFWriter("udp", conn.LocalAddr().String())
+			Expect(err).NotTo(HaveOccurred())
+			defer w.Close()
+
+			_, err = w.Write([]byte("{\"short_message\":\"hi\"}\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(readUDP(conn))).To(Equal(`{"short_message":"hi"}`))
+		})
+
+		It("compresses messages sent over UDP", func() {
+			conn := listenUDP()
+			defer conn.Close()
+
+			w, err := lg.NewGELFWriter("udp", conn.LocalAddr().String(),
+				lg.GELFCompress(lg.GELFCompressGzip))
+			Expect(err).NotTo(HaveOccurred())
+			defer w.Close()
+
+			_, err = w.Write([]byte(`{"short_message":"gzip"}`))
+			Expect(err).NotTo(HaveOccurred())
+
+			zr, err := gzip.NewReader(bytes.NewReader(readUDP(conn)))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.ReadAll(zr)).To(Equal([]byte(`{"short_message":"gzip"}`)))
+
+			w2, err := lg.NewGELFWriter("udp", conn.LocalAddr().String(),
+				lg.GELFCompress(lg.GELFCompressZlib))
+			Expect(err).NotTo(HaveOccurred())
+			defer w2.Close()
+
+			_, err = w2.Write([]byte(`{"short_message":"zlib"}`))
+			Expect(err).NotTo(HaveOccurred())
+
+			zr2, err := zlib.NewReader(bytes.NewReader(readUDP(conn)))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.ReadAll(zr2)).To(Equal([]byte(`{"short_message":"zlib"}`)))
+		})
+
+		It("splits large messages into chunks", func() {
+			conn := listenUDP()
+			defer conn.Close()
+
+			w, err := lg.NewGELFWriter("udp", conn.LocalAddr().String(),
+				lg.GELFChunkSize(112))
+			Expect(err).NotTo(HaveOccurred())
+			defer w.Close()
+
+			msg := []byte(strings.Repeat("x", 250))
+			_, err = w.Write(msg)
+			Expect(err).NotTo(HaveOccurred())
+
+			var reassembled []byte
+			var id []byte
+			for seq := 0; seq < 3; seq++ {
+				chunk := readUDP(conn)
+				Expect(len(chunk)).To(BeNumerically("<=", 112))
+				Expect(chunk[:2]).To(Equal([]byte{0x1e, 0x0f}))
+				if id == nil {
+					id = chunk[2:10]
+				}
+				Expect(chunk[2:10]).To(Equal(id))
+				Expect(chunk[10]).To(Equal(byte(seq)))
+				Expect(chunk[11]).To(Equal(byte(3)))
+				reassembled = append(reassembled, chunk[12:]...)
+			}
+
+			Expect(reassembled).To(Equal(msg))
+		})
+
+		It("refuses messages that need too many chunks", func() {
+			conn := listenUDP()
+			defer conn.Close()
+
+			w, err := lg.NewGELFWriter("udp", conn.LocalAddr().String(),
+				lg.GELFChunkSize(13))
+			Expect(err).NotTo(HaveOccurred())
+			defer w.Close()
+
+			_, err = w.Write([]byte(strings.Repeat("x", 129)))
+			Expect(err).To(Equal(lg.ErrGELFMessageTooLarge))
+		})
+
+		It("sends null terminated messages over TCP", func() {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer ln.Close()
+
+			received := make(chan []byte, 1)
+			go func() {
+				defer GinkgoRecover()
+				conn, err := ln.Accept()
+				Expect(err).NotTo(HaveOccurred())
+				defer conn.Close()
+
+				var buf []byte
+				chunk := make([]byte, 1024)
+				for bytes.Count(buf, []byte{0}) < 2 {
+					n, err := conn.Read(chunk)
+					if err != nil {
+						break
+					}
+					buf = append(buf, chunk[:n]...)
+				}
+				received <- buf
+			}()
+
+			w, err := lg.NewGELFWriter("tcp", ln.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			defer w.Close()
+
+			_, err = w.Write([]byte("{\"a\":1}\n"))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = w.Write([]byte("{\"b\":2}\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(received).Should(Receive(Equal([]byte("{\"a\":1}\x00{\"b\":2}\x00"))))
+		})
+
+		It("rejects unsupported networks", func() {
+			_, err := lg.NewGELFWriter("unix", "/tmp/gelf")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,267 @@
+package lg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// GELFCompression is a compression method for GELF messages sent over UDP
+type GELFCompression uint
+
+// GELF compression methods
+const (
+	GELFCompressNone GELFCompression = iota
+	GELFCompressGzip
+	GELFCompressZlib
+)
+
+const (
+	// DefaultGELFChunkSize is the maximum size of the UDP datagrams that
+	// GELF messages are split into, unless set with GELFChunkSize
+	DefaultGELFChunkSize = 1420
+
+	gelfChunkHeaderSize = 12
+	gelfMaxChunks       = 128
+)
+
+var (
+	gelfChunkMagic = []byte{0x1e, 0x0f}
+
+	// ErrGELFMessageTooLarge is returned when a GELF message needs more than
+	// 128 chunks to be sent over UDP
+	ErrGELFMessageTooLarge = errors.New("GELF message is too large to send")
+)
+
+// GELFWriterOptions configures a GELFWriter
+type GELFWriterOptions struct {
+	compression GELFCompression
+	chunkSize   int
+}
+
+// GELFCompress compresses messages sent over UDP with the given method.
+// Messages sent over TCP are never compressed.
+func GELFCompress(compression GELFCompression) func(*GELFWriterOptions) {
+	return func(o *GELFWriterOptions) {
+		o.compression = compression
+	}
+}
+
+// GELFChunkSize sets the maximum size of the UDP datagrams that messages are
+// split into
+func GELFChunkSize(size int) func(*GELFWriterOptions) {
+	return func(o *GELFWriterOptions) {
+		o.chunkSize = size
+	}
+}
+
+// GELFWriter sends GELF messages to a Graylog server. Each Write is one
+// message. Over UDP, messages are optionally compressed, and split into
+// chunks if they're larger than the chunk size. Over TCP, messages are
+// terminated by a null byte.
+//
+// Example:
+//
+//   w, err := lg.NewGELFWriter("udp", "graylog:12201",
+//     lg.GELFCompress(lg.GELFCompressGzip))
+//   if err != nil {
+//     panic(err)
+//   }
+//   lg.AddOutput(w, lg.GELF())
+type GELFWriter struct {
+	network string
+	address string
+	options GELFWriterOptions
+
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+// NewGELFWriter returns a writer which sends GELF messages to address, over
+// network, which must be "udp" or "tcp" (or one of their variants, such as
+// "udp4")
+func NewGELFWriter(
+	network, address string, opts ...func(*GELFWriterOptions),
+) (*GELFWriter, error) {
+	w := &GELFWriter{
+		network: network,
+		address: address,
+		options: GELFWriterOptions{chunkSize: DefaultGELFChunkSize},
+	}
+
+	for _, opt := range opts {
+		opt(&w.options)
+	}
+
+	if w.options.chunkSize <= gelfChunkHeaderSize {
+		return nil, fmt.Errorf("invalid GELF chunk size %d", w.options.chunkSize)
+	}
+
+	if !w.isUDP() && !w.isTCP() {
+		return nil, fmt.Errorf("unsupported GELF network '%s'", network)
+	}
+
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *GELFWriter) isUDP() bool {
+	switch w.network {
+	case "udp", "udp4", "udp6":
+		return true
+	}
+	return false
+}
+
+func (w *GELFWriter) isTCP() bool {
+	switch w.network {
+	case "tcp", "tcp4", "tcp6":
+		return true
+	}
+	return false
+}
+
+func (w *GELFWriter) connect() (err error) {
+	w.conn, err = net.Dial(w.network, w.address)
+	return err
+}
+
+// Write sends p as a single message. A trailing new line is removed.
+func (w *GELFWriter) Write(p []byte) (int, error) {
+	msg := bytes.TrimRight(p, "\n")
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	var err error
+	if w.isUDP() {
+		err = w.writeUDP(msg)
+	} else {
+		err = w.writeTCP(msg)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the connection to the server
+func (w *GELFWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+func (w *GELFWriter) writeTCP(msg []byte) error {
+	framed := make([]byte, len(msg)+1)
+	copy(framed, msg)
+
+	if w.conn == nil {
+		if err := w.connect(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.conn.Write(framed); err == nil {
+		return nil
+	}
+
+	// the connection may have been dropped, so reconnect and try once more
+	w.conn.Close()
+	if err := w.connect(); err != nil {
+		w.conn = nil
+		return err
+	}
+
+	_, err := w.conn.Write(framed)
+	return err
+}
+
+func (w *GELFWriter) writeUDP(msg []byte) error {
+	msg, err := w.compress(msg)
+	if err != nil {
+		return err
+	}
+
+	if w.conn == nil {
+		if err = w.connect(); err != nil {
+			return err
+		}
+	}
+
+	if len(msg) <= w.options.chunkSize {
+		_, err = w.conn.Write(msg)
+		return err
+	}
+
+	dataSize := w.options.chunkSize - gelfChunkHeaderSize
+	count := (len(msg) + dataSize - 1) / dataSize
+	if count > gelfMaxChunks {
+		return ErrGELFMessageTooLarge
+	}
+
+	id := make([]byte, 8)
+	if _, err = rand.Read(id); err != nil {
+		return err
+	}
+
+	chunk := make([]byte, 0, w.options.chunkSize)
+	for seq := 0; seq < count; seq++ {
+		end := (seq + 1) * dataSize
+		if end > len(msg) {
+			end = len(msg)
+		}
+
+		chunk = append(chunk[:0], gelfChunkMagic...)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(seq), byte(count))
+		chunk = append(chunk, msg[seq*dataSize:end]...)
+
+		if _, err = w.conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *GELFWriter) compress(msg []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var zw io.WriteCloser
+
+	switch w.options.compression {
+	case GELFCompressGzip:
+		zw = gzip.NewWriter(&buf)
+	case GELFCompressZlib:
+		zw = zlib.NewWriter(&buf)
+	default:
+		return msg, nil
+	}
+
+	if _, err := zw.Write(msg); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,280 @@
+package lg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPOptions configures an HTTPWriter
+type HTTPOptions struct {
+	batch     batchOptions
+	client    *http.Client
+	header    http.Header
+	gzip      bool
+	jsonArray bool
+}
+
+// HTTPHeader adds a header to every request, e.g. for authentication
+func HTTPHeader(key, value string) func(*HTTPOptions) {
+	return func(o *HTTPOptions) {
+		o.header.Add(key, value)
+	}
+}
+
+// HTTPClient sets the client that requests are made with. Defaults to a
+// client with a 30 second timeout.
+func HTTPClient(client *http.Client) func(*HTTPOptions) {
+	return func(o *HTTPOptions) {
+		o.client = client
+	}
+}
+
+// HTTPGzip compresses request bodies with gzip
+func HTTPGzip() func(*HTTPOptions) {
+	return func(o *HTTPOptions) {
+		o.gzip = true
+	}
+}
+
+// HTTPJSONArray sends each batch as a JSON array of entries, rather than as
+// newline delimited JSON. Entries must be formatted as JSON.
+func HTTPJSONArray() func(*HTTPOptions) {
+	return func(o *HTTPOptions) {
+		o.jsonArray = true
+	}
+}
+
+// HTTPBatch sets the most entries, and the most bytes of entries, that are
+// sent in one request, and how long an entry waits for its batch to fill up
+// before it is sent anyway. Defaults to 1000 entries, 1MB and 1s.
+func HTTPBatch(count, size int, latency time.Duration) func(*HTTPOptions) {
+	return func(o *HTTPOptions) {
+		o.batch.maxCount = count
+		o.batch.maxBytes = size
+		o.batch.maxLatency = latency
+	}
+}
+
+// HTTPQueueSize sets how many entries are queued while waiting to be sent.
+// Entries that don't fit are dropped. Defaults to 10000.
+func HTTPQueueSize(size int) func(*HTTPOptions) {
+	return func(o *HTTPOptions) {
+		o.batch.queueSize = size
+	}
+}
+
+// HTTPRetries sets how many times a batch is retried after the server fails
+// with a 5xx or 429 status, or can't be reached, with a delay that starts at
+// min and doubles with each retry up to max, unless the server gives one with
+// Retry-After. Defaults to 5 retries, 100ms and 30s.
+func HTTPRetries(retries int, min, max time.Duration) func(*HTTPOptions) {
+	return func(o *HTTPOptions) {
+		o.batch.retries = retries
+		o.batch.minBackoff = min
+		o.batch.maxBackoff = max
+	}
+}
+
+// HTTPFlushTimeout sets how long Close waits for queued entries to be sent.
+// Defaults to 5s.
+func HTTPFlushTimeout(timeout time.Duration) func(*HTTPOptions) {
+	return func(o *HTTPOptions) {
+		o.batch.flushTimeout = timeout
+	}
+}
+
+func makeHTTPOptions(opts []func(*HTTPOptions)) HTTPOptions {
+	options := HTTPOptions{
+		batch:  defaultBatchOptions,
+		client: &http.Client{Timeout: 30 * time.Second},
+		header: make(http.Header),
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return options
+}
+
+// redactURL returns rawurl with any password replaced, as for the names of
+// writers in error messages
+func redactURL(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "invalid URL"
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
+
+// HTTPWriter ships entries to an HTTP endpoint in batches, POSTed as newline
+// delimited JSON or as a JSON array. Writes don't block: entries are queued
+// and sent in the background, and batches which fail with a 5xx or 429
+// status, or because the server can't be reached, are retried with
+// exponential backoff. Entries which are dropped, because the queue is full or
+// a batch can't be sent, are counted and reported to stderr.
+//
+// Example:
+//
+//   w := lg.HTTPOutput("https://logs.example.com/ingest",
+//     lg.HTTPHeader("Authorization", "Bearer "+token), lg.HTTPGzip())
+//   defer w.Close()
+//   lg.AddOutput(w, lg.JSON())
+type HTTPWriter struct {
+	url     string
+	options HTTPOptions
+	batcher *batcher
+}
+
+// HTTPOutput returns a writer which ships entries to url
+func HTTPOutput(url string, opts ...func(*HTTPOptions)) *HTTPWriter {
+	w := &HTTPWriter{
+		url:     url,
+		options: makeHTTPOptions(opts),
+	}
+
+	w.batcher = newBatcher(redactURL(url), w.options.batch, w.send)
+
+	return w
+}
+
+// Write queues p to be sent as a single entry. It fails with ErrQueueFull if
+// the queue is full.
+func (w *HTTPWriter) Write(p []byte) (int, error) {
+	if err := w.batcher.add(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of entries that have been dropped, because the
+// queue was full or they couldn't be sent
+func (w *HTTPWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.batcher.dropped)
+}
+
+// Close sends any queued entries, waiting at most the flush timeout
+func (w *HTTPWriter) Close() error {
+	return w.batcher.close()
+}
+
+func (w *HTTPWriter) send(ctx context.Context, batch [][]byte) error {
+	var body bytes.Buffer
+	if w.options.jsonArray {
+		body.WriteByte('[')
+		for i, entry := range batch {
+			if i > 0 {
+				body.WriteByte(',')
+			}
+			body.Write(entry)
+		}
+		body.WriteByte(']')
+	} else {
+		for _, entry := range batch {
+			body.Write(entry)
+			body.WriteByte('\n')
+		}
+	}
+
+	contentType := "application/x-ndjson"
+	if w.options.jsonArray {
+		contentType = "application/json"
+	}
+
+	return postBatch(ctx, w.url, &w.options, contentType, body.Bytes())
+}
+
+// postBatch POSTs body to url, compressed if the options ask for it, and
+// returns a retryableError if the request might succeed if it's made again
+func postBatch(
+	ctx context.Context,
+	url string,
+	options *HTTPOptions,
+	contentType string,
+	body []byte,
+) error {
+	resp, err := doRequest(ctx, url, options, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+
+	return responseError(resp)
+}
+
+// doRequest POSTs body to url, compressed if the options ask for it. Only
+// errors making the request, not building it, are retryableErrors.
+func doRequest(
+	ctx context.Context,
+	url string,
+	options *HTTPOptions,
+	contentType string,
+	body []byte,
+) (*http.Response, error) {
+	if options.gzip {
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		if _, err := gz.Write(body); err != nil {
+			return nil, err
+		}
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+		body = compressed.Bytes()
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	req.Header.Set("Content-Type", contentType)
+	if options.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for key, values := range options.header {
+		req.Header[key] = values
+	}
+
+	resp, err := options.client.Do(req)
+	if err != nil {
+		return nil, &retryableError{err: err}
+	}
+	return resp, nil
+}
+
+// responseError returns the error for a failed response, which is a
+// retryableError for 5xx and 429 statuses
+func responseError(resp *http.Response) error {
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	err := fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		retryable := &retryableError{err: err}
+		if secs, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil {
+			retryable.after = time.Duration(secs) * time.Second
+		}
+		return retryable
+	}
+
+	return err
+}
@@ -0,0 +1,116 @@
+package lg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// journaldFields are the journal fields that lg sets itself, and so can't be
+// used by user fields
+var journaldFields = map[string]bool{
+	"MESSAGE":           true,
+	"PRIORITY":          true,
+	"SYSLOG_IDENTIFIER": true,
+	"LG_PREFIX":         true,
+	"CODE_FILE":         true,
+	"CODE_LINE":         true,
+	"CODE_FUNC":         true,
+}
+
+// journaldFormatter renders entries in the native journald protocol
+type journaldFormatter struct {
+	identifier string
+}
+
+func newJournaldFormatter(options *Options) *journaldFormatter {
+	j := &journaldFormatter{identifier: options.serviceName}
+
+	if j.identifier == "" {
+		j.identifier = filepath.Base(os.Args[0])
+	}
+
+	return j
+}
+
+// journaldKey returns key as a valid journal field name: upper case letters,
+// digits and underscores, not starting with an underscore or digit. Keys
+// which collide with the fields lg sets are prefixed with F_. It returns ""
+// if nothing is left of the key.
+func journaldKey(key string) string {
+	b := make([]byte, 0, len(key))
+	for i := 0; i < len(key) && len(b) < 64; i++ {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			b = append(b, c-'a'+'A')
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			b = append(b, c)
+		case len(b) > 0:
+			b = append(b, '_')
+		}
+	}
+
+	name := strings.TrimRight(string(b), "_")
+	if name == "" {
+		return ""
+	}
+
+	if journaldFields[name] || (name[0] >= '0' && name[0] <= '9') {
+		name = "F_" + name
+		if len(name) > 64 {
+			name = name[:64]
+		}
+	}
+
+	return name
+}
+
+// writeJournaldField writes a single field. Values containing new lines are
+// written with their length, as the protocol requires.
+func writeJournaldField(out *bytes.Buffer, key, val string) {
+	out.WriteString(key)
+
+	if strings.IndexByte(val, '\n') < 0 {
+		out.WriteByte('=')
+		out.WriteString(val)
+		out.WriteByte('\n')
+		return
+	}
+
+	out.WriteByte('\n')
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(val)))
+	out.Write(size[:])
+	out.WriteString(val)
+	out.WriteByte('\n')
+}
+
+func (j *journaldFormatter) format(e *Entry) []byte {
+	var out bytes.Buffer
+
+	writeJournaldField(&out, "MESSAGE", e.Message)
+	writeJournaldField(&out, "PRIORITY", strconv.Itoa(syslogSeverity(e.Level)))
+	writeJournaldField(&out, "SYSLOG_IDENTIFIER", j.identifier)
+
+	if e.Prefix != "" {
+		writeJournaldField(&out, "LG_PREFIX", e.Prefix)
+	}
+
+	if e.Caller != nil {
+		writeJournaldField(&out, "CODE_FILE", e.Caller.File)
+		writeJournaldField(&out, "CODE_LINE", strconv.Itoa(e.Caller.Line))
+		writeJournaldField(&out, "CODE_FUNC", e.Caller.Function)
+	}
+
+	for _, fld := range e.Fields.contents {
+		if key := journaldKey(fld.Key); key != "" {
+			writeJournaldField(&out, key, renderRawValue(fld.Val))
+		}
+	}
+
+	return out.Bytes()
+}
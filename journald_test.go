@@ -0,0 +1,54 @@
+package lg_test
+
+import (
+	"os"
+
+	"github.com/autopilothq/lg"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("journald output", func() {
+
+	var tlo *TestLogOutput
+
+	BeforeEach(func() {
+		tlo = &TestLogOutput{}
+		lg.RemoveOutput(os.Stdout)
+	})
+
+	AfterEach(func() {
+		lg.RemoveOutput(tlo)
+		lg.AddOutput(os.Stdout)
+	})
+
+	It("renders entries in the native protocol", func() {
+		lg.AddOutput(tlo, lg.Journald(), lg.ServiceName("api"))
+		lg.ExtendWithPrefix("Server").Warn("careful",
+			lg.F{"user id", 1},
+			lg.F{"message", "clash"},
+			lg.F{"_hidden", true},
+			lg.F{"2fa", "yes"},
+			lg.F{"-", "dropped"},
+		)
+
+		Expect(tlo.String()).To(Equal(
+			"MESSAGE=careful\n" +
+				"PRIORITY=4\n" +
+				"SYSLOG_IDENTIFIER=api\n" +
+				"LG_PREFIX=Server\n" +
+				"USER_ID=1\n" +
+				"F_MESSAGE=clash\n" +
+				"HIDDEN=true\n" +
+				"F_2FA=yes\n"))
+	})
+
+	It("writes multi-line values with their length", func() {
+		lg.AddOutput(tlo, lg.Journald(), lg.ServiceName("api"))
+		lg.Info("two\nlines")
+
+		Expect(tlo.String()).To(HavePrefix(
+			"MESSAGE\n\x09\x00\x00\x00\x00\x00\x00\x00two\nlines\n"))
+	})
+})
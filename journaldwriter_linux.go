@@ -0,0 +1,128 @@
+//go:build linux
+// +build linux
+
+package lg
+
+import (
+	"net"
+	"os"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// JournaldSocket is the path of journald's native protocol socket
+const JournaldSocket = "/run/systemd/journal/socket"
+
+// JournaldWriter sends entries to systemd-journald using its native protocol.
+// Each Write is one entry. Entries too large to be sent as a single datagram
+// are written to a sealed memfd, which is passed to journald instead.
+//
+// Example:
+//
+//   w, err := lg.NewJournaldWriter("")
+//   if err != nil {
+//     panic(err)
+//   }
+//   lg.AddOutput(w, lg.Journald())
+type JournaldWriter struct {
+	mutex sync.Mutex
+	conn  *net.UnixConn
+}
+
+// NewJournaldWriter returns a writer which sends entries to the journald
+// socket at path, or JournaldSocket if path is empty
+func NewJournaldWriter(path string) (*JournaldWriter, error) {
+	if path == "" {
+		path = JournaldSocket
+	}
+
+	conn, err := net.DialUnix("unixgram", nil,
+		&net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &JournaldWriter{conn: conn}, nil
+}
+
+// Write sends p, which must be encoded in the native journald protocol (as
+// the Journald format does), as a single entry
+func (w *JournaldWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	_, err := w.conn.Write(p)
+	if err == nil {
+		return len(p), nil
+	}
+
+	if !isMessageTooLarge(err) {
+		return 0, err
+	}
+
+	if err = w.writeMemfd(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the connection to journald
+func (w *JournaldWriter) Close() error {
+	return w.conn.Close()
+}
+
+// isMessageTooLarge returns whether a write failed because the entry was too
+// large to send as a datagram
+func isMessageTooLarge(err error) bool {
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
+	}
+	if sysErr, ok := err.(*os.SyscallError); ok {
+		err = sysErr.Err
+	}
+
+	return err == syscall.EMSGSIZE || err == syscall.ENOBUFS
+}
+
+// writeMemfd writes p to a sealed memfd, and sends its file descriptor to
+// journald
+func (w *JournaldWriter) writeMemfd(p []byte) error {
+	fd, err := unix.MemfdCreate("lg-journald", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	for written := 0; written < len(p); {
+		n, err := unix.Write(fd, p[written:])
+		if err != nil {
+			return err
+		}
+		written += n
+	}
+
+	_, err = unix.FcntlInt(uintptr(fd), unix.F_ADD_SEALS,
+		unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_WRITE|unix.F_SEAL_SEAL)
+	if err != nil {
+		return err
+	}
+
+	// the connection is connected, so the message is sent on the raw socket
+	// as WriteMsgUnix refuses to
+	raw, err := w.conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sendErr error
+	err = raw.Write(func(sock uintptr) bool {
+		sendErr = unix.Sendmsg(int(sock), nil, unix.UnixRights(fd), nil, 0)
+		return sendErr != unix.EAGAIN
+	})
+	if err != nil {
+		return err
+	}
+	return sendErr
+}
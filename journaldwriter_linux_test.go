@@ -0,0 +1,95 @@
+//go:build linux
+// +build linux
+
+package lg_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/autopilothq/lg"
+	"golang.org/x/sys/unix"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JournaldWriter", func() {
+
+	var (
+		dir  string
+		conn *net.UnixConn
+		w    *lg.JournaldWriter
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "lg-journald")
+		Expect(err).NotTo(HaveOccurred())
+
+		path := filepath.Join(dir, "socket")
+		conn, err = net.ListenUnixgram("unixgram",
+			&net.UnixAddr{Name: path, Net: "unixgram"})
+		Expect(err).NotTo(HaveOccurred())
+
+		w, err = lg.NewJournaldWriter(path)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		w.Close()
+		conn.Close()
+		os.RemoveAll(dir)
+	})
+
+	read := func() ([]byte, []byte) {
+		buf := make([]byte, 65536)
+		oob := make([]byte, 1024)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+		Expect(err).NotTo(HaveOccurred())
+		return buf[:n], oob[:oobn]
+	}
+
+	It("sends entries as datagrams", func() {
+		_, err := w.Write([]byte("MESSAGE=hi\nPRIORITY=6\n"))
+		Expect(err).NotTo(HaveOccurred())
+
+		data, oob := read()
+		Expect(string(data)).To(Equal("MESSAGE=hi\nPRIORITY=6\n"))
+		Expect(oob).To(BeEmpty())
+	})
+
+	It("passes entries too large for a datagram via a memfd", func() {
+		entry := "MESSAGE=" + strings.Repeat("x", 4<<20) + "\n"
+		_, err := w.Write([]byte(entry))
+		Expect(err).NotTo(HaveOccurred())
+
+		data, oob := read()
+		Expect(data).To(BeEmpty())
+
+		msgs, err := unix.ParseSocketControlMessage(oob)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(msgs).To(HaveLen(1))
+
+		fds, err := unix.ParseUnixRights(&msgs[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fds).To(HaveLen(1))
+
+		f := os.NewFile(uintptr(fds[0]), "memfd")
+		defer f.Close()
+
+		seals, err := unix.FcntlInt(f.Fd(), unix.F_GET_SEALS, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(seals & unix.F_SEAL_WRITE).NotTo(BeZero())
+
+		f.Seek(0, 0)
+		contents, err := ioutil.ReadAll(f)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal(entry))
+	})
+})
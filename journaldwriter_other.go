@@ -0,0 +1,28 @@
+//go:build !linux
+// +build !linux
+
+package lg
+
+import "errors"
+
+// JournaldSocket is the path of journald's native protocol socket
+const JournaldSocket = "/run/systemd/journal/socket"
+
+// JournaldWriter sends entries to systemd-journald. It's only supported on
+// Linux.
+type JournaldWriter struct{}
+
+// NewJournaldWriter always fails, as journald is only available on Linux
+func NewJournaldWriter(path string) (*JournaldWriter, error) {
+	return nil, errors.New("journald is only supported on linux")
+}
+
+// Write always fails
+func (w *JournaldWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("journald is only supported on linux")
+}
+
+// Close does nothing
+func (w *JournaldWriter) Close() error {
+	return nil
+}
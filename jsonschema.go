@@ -0,0 +1,178 @@
+package lg
+
+import (
+	"fmt"
+
+	"github.com/autopilothq/lg/encoding"
+	fancy "github.com/autopilothq/lg/encoding/json"
+)
+
+// JSONSchema describes the layout of the entries written by the JSON format.
+// Any keys that are left empty take their default values.
+type JSONSchema struct {
+	// TimeKey is the key of the timestamp. Defaults to "t".
+	TimeKey string
+
+	// LevelKey is the key of the level. Defaults to "l".
+	LevelKey string
+
+	// PrefixKey is the key of the prefix, which is omitted when empty.
+	// Defaults to "p".
+	PrefixKey string
+
+	// MessageKey is the key of the message. Defaults to "m".
+	MessageKey string
+
+	// FieldsKey is the key of the object that fields are nested within.
+	// Defaults to "f".
+	FieldsKey string
+
+	// FlattenFields writes fields at the top level of the entry rather than
+	// nesting them within FieldsKey
+	FlattenFields bool
+
+	// CollisionPrefix is prepended to the keys of flattened fields which
+	// collide with any of the other top level keys. Defaults to "fields.".
+	CollisionPrefix string
+
+	// Static holds fields which are written at the top level of every entry.
+	// Their keys can't be any of the other top level keys.
+	Static []F
+}
+
+// defaultJSONSchema is the layout of the JSON format unless another is given
+var defaultJSONSchema = JSONSchema{
+	TimeKey:         "t",
+	LevelKey:        "l",
+	PrefixKey:       "p",
+	MessageKey:      "m",
+	FieldsKey:       "f",
+	CollisionPrefix: "fields.",
+}
+
+// withDefaults returns a copy of the schema with any empty keys replaced by
+// their defaults
+func (s JSONSchema) withDefaults() *JSONSchema {
+	if s.TimeKey == "" {
+		s.TimeKey = defaultJSONSchema.TimeKey
+	}
+	if s.LevelKey == "" {
+		s.LevelKey = defaultJSONSchema.LevelKey
+	}
+	if s.PrefixKey == "" {
+		s.PrefixKey = defaultJSONSchema.PrefixKey
+	}
+	if s.MessageKey == "" {
+		s.MessageKey = defaultJSONSchema.MessageKey
+	}
+	if s.FieldsKey == "" {
+		s.FieldsKey = defaultJSONSchema.FieldsKey
+	}
+	if s.CollisionPrefix == "" {
+		s.CollisionPrefix = defaultJSONSchema.CollisionPrefix
+	}
+
+	s.Static = append([]F(nil), s.Static...)
+	return &s
+}
+
+// reserved reports whether key is used by the schema at the top level of
+// entries, and therefore can't be used by a flattened field
+func (s *JSONSchema) reserved(key string) bool {
+	switch key {
+	case s.TimeKey, s.LevelKey, s.PrefixKey, s.MessageKey:
+		return true
+	}
+
+	for _, fld := range s.Static {
+		if fld.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validate returns an error if a static key would be written twice, i.e. is
+// the same as one of the other top level keys
+func (s *JSONSchema) validate() error {
+	for _, fld := range s.Static {
+		switch fld.Key {
+		case s.TimeKey, s.LevelKey, s.PrefixKey, s.MessageKey:
+			return fmt.Errorf("static key %q is already used by the schema", fld.Key)
+		case s.FieldsKey:
+			if !s.FlattenFields {
+				return fmt.Errorf("static key %q is already used by the schema", fld.Key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// encodeStatic writes the static fields of the schema
+func (s *JSONSchema) encodeStatic(enc *fancy.Encoder) error {
+	for _, fld := range s.Static {
+		if err := encoding.EncodeKeyValue(enc, fld.Key, fld.Val); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeFields writes the fields of an entry, either nested within the
+// fields key or flattened into the top level
+func (s *JSONSchema) encodeFields(enc *fancy.Encoder, fields *Fields) error {
+	if fields.Len() == 0 {
+		return nil
+	}
+
+	if !s.FlattenFields {
+		if err := enc.AddKey(s.FieldsKey); err != nil {
+			return err
+		}
+
+		return fields.encodeJSON(enc)
+	}
+
+	for _, fld := range fields.contents {
+		key := fld.Key
+		for s.reserved(key) {
+			key = s.CollisionPrefix + key
+		}
+
+		if err := encoding.EncodeKeyValue(enc, key, fld.Val); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Schema outputs entries in JSON format, laid out according to the given
+// schema. It panics if a static key is the same as another top level key.
+//
+// Example:
+//
+//   // write entries with descriptive keys, fields at the top level, and
+//   // the name of the service in every entry
+//   lg.AddOutput(os.Stdout, lg.Schema(lg.JSONSchema{
+//     TimeKey:       "timestamp",
+//     LevelKey:      "severity",
+//     PrefixKey:     "logger",
+//     MessageKey:    "message",
+//     FlattenFields: true,
+//     Static:        []lg.F{{"service", "api"}},
+//   }))
+func Schema(schema JSONSchema) func(*Options) {
+	s := schema.withDefaults()
+	if err := s.validate(); err != nil {
+		panic(err)
+	}
+
+	return func(o *Options) {
+		o.format = FormatJSON
+		o.schema = s
+	}
+}
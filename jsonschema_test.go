@@ -0,0 +1,94 @@
+package lg_test
+
+import (
+	"os"
+
+	"github.com/autopilothq/lg"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSON schema", func() {
+
+	var tlo *TestLogOutput
+
+	BeforeEach(func() {
+		tlo = &TestLogOutput{}
+		lg.RemoveOutput(os.Stdout)
+	})
+
+	AfterEach(func() {
+		lg.RemoveOutput(tlo)
+		lg.AddOutput(os.Stdout)
+	})
+
+	It("uses the default keys when none are given", func() {
+		lg.AddOutput(tlo, lg.Schema(lg.JSONSchema{}))
+
+		lg.ExtendWithPrefix("Server").Info("hello", lg.F{"id", 1})
+
+		Expect(tlo.String()).To(MatchRegexp(
+			`^\{"t":"[^"]+","l":"info","p":"Server","f":\{"id":1\},"m":"hello"\}\n$`))
+	})
+
+	It("renames the core keys", func() {
+		lg.AddOutput(tlo, lg.Schema(lg.JSONSchema{
+			TimeKey:    "timestamp",
+			LevelKey:   "severity",
+			PrefixKey:  "logger",
+			MessageKey: "message",
+			FieldsKey:  "data",
+		}))
+
+		lg.ExtendWithPrefix("Server").Info("hello", lg.F{"id", 1})
+
+		Expect(tlo.String()).To(MatchRegexp(
+			`^\{"timestamp":"[^"]+","severity":"info","logger":"Server",` +
+				`"data":\{"id":1\},"message":"hello"\}\n$`))
+	})
+
+	It("flattens fields and adds static keys", func() {
+		lg.AddOutput(tlo, lg.Schema(lg.JSONSchema{
+			FlattenFields: true,
+			Static:        []lg.F{{"service", "api"}},
+		}))
+
+		lg.Info("hello", lg.F{"id", 1}, lg.F{"user", "bob"})
+
+		Expect(tlo.String()).To(MatchRegexp(
+			`^\{"t":"[^"]+","l":"info","service":"api","id":1,"user":"bob",` +
+				`"m":"hello"\}\n$`))
+	})
+
+	It("renames flattened fields which collide with other keys", func() {
+		lg.AddOutput(tlo, lg.Schema(lg.JSONSchema{
+			MessageKey:      "message",
+			FlattenFields:   true,
+			CollisionPrefix: "field_",
+			Static:          []lg.F{{"service", "api"}},
+		}))
+
+		lg.Info("hello", lg.F{"message", "hi"}, lg.F{"service", "db"})
+
+		Expect(tlo.String()).To(MatchRegexp(
+			`^\{"t":"[^"]+","l":"info","service":"api","field_message":"hi",` +
+				`"field_service":"db","message":"hello"\}\n$`))
+	})
+	It("rejects static keys which collide with other keys", func() {
+		Expect(func() {
+			lg.Schema(lg.JSONSchema{Static: []lg.F{{"m", "static"}}})
+		}).To(Panic())
+
+		Expect(func() {
+			lg.Schema(lg.JSONSchema{Static: []lg.F{{"f", "static"}}})
+		}).To(Panic())
+
+		Expect(func() {
+			lg.Schema(lg.JSONSchema{
+				FlattenFields: true,
+				Static:        []lg.F{{"f", "static"}},
+			})
+		}).NotTo(Panic())
+	})
+})
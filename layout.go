@@ -0,0 +1,343 @@
+package lg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/autopilothq/lg/encoding"
+	text "github.com/autopilothq/lg/encoding/text"
+	"github.com/autopilothq/lg/encoding/types"
+)
+
+// layoutSegment appends part of a rendered entry to b, rendering any times in
+// the given format
+type layoutSegment func(b []byte, e *Entry, tf *types.TimeFormat) []byte
+
+// textLayout is a compiled layout for rendering entries as plain text
+type textLayout struct {
+	segments []layoutSegment
+
+	// fieldKeys holds the keys of fields that are rendered individually, and
+	// are therefore left out of {fields}
+	fieldKeys map[string]bool
+
+	// caller is true if the layout renders the caller of entries
+	caller bool
+}
+
+var (
+	startTime = time.Now()
+
+	layoutSpecPattern = regexp.MustCompile(`^(>)?([0-9]+)?(?:\.([0-9]+))?$`)
+)
+
+// compileLayout compiles a layout such as:
+//
+//   "{time:15:04:05} {level:5} {prefix} {message} {fields}"
+//
+// into a renderer. See Layout for the supported verbs.
+func compileLayout(layout string) (*textLayout, error) {
+	l := &textLayout{fieldKeys: make(map[string]bool)}
+
+	var literal []byte
+	for i := 0; i < len(layout); i++ {
+		c := layout[i]
+		switch {
+		case c == '{' && i+1 < len(layout) && layout[i+1] == '{',
+			c == '}' && i+1 < len(layout) && layout[i+1] == '}':
+			literal = append(literal, c)
+			i++
+
+		case c == '{':
+			end := strings.IndexByte(layout[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated verb in layout '%s'", layout)
+			}
+
+			if len(literal) > 0 {
+				l.segments = append(l.segments, literalSegment(string(literal)))
+				literal = nil
+			}
+
+			segment, err := l.compileVerb(layout[i+1 : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid layout '%s': %s", layout, err)
+			}
+
+			l.segments = append(l.segments, segment)
+			i += end
+
+		case c == '}':
+			return nil, fmt.Errorf("unexpected '}' in layout '%s'", layout)
+
+		default:
+			literal = append(literal, c)
+		}
+	}
+
+	if len(literal) > 0 {
+		l.segments = append(l.segments, literalSegment(string(literal)))
+	}
+
+	return l, nil
+}
+
+// compileVerb compiles a single {verb:arg|spec}
+func (l *textLayout) compileVerb(verb string) (layoutSegment, error) {
+	var arg, spec string
+
+	if idx := strings.LastIndexByte(verb, '|'); idx >= 0 {
+		verb, spec = verb[:idx], verb[idx+1:]
+	}
+
+	if idx := strings.IndexByte(verb, ':'); idx >= 0 {
+		verb, arg = verb[:idx], verb[idx+1:]
+	}
+
+	var segment layoutSegment
+
+	switch verb {
+	case "time":
+		segment = timeSegment(arg)
+		arg = ""
+
+	case "elapsed":
+		round := time.Millisecond
+		if arg != "" {
+			var err error
+			if round, err = time.ParseDuration(arg); err != nil {
+				return nil, fmt.Errorf("invalid elapsed rounding '%s'", arg)
+			}
+		}
+		segment = elapsedSegment(round)
+		arg = ""
+
+	case "field":
+		if arg == "" {
+			return nil, fmt.Errorf("{field} requires a key, e.g. {field:id}")
+		}
+		l.fieldKeys[arg] = true
+		segment = fieldSegment(arg)
+		arg = ""
+
+	case "caller":
+		l.caller = true
+		switch arg {
+		case "", "short":
+			segment = shortCallerSegment
+		case "long":
+			segment = longCallerSegment
+		case "func":
+			segment = funcCallerSegment
+		default:
+			return nil, fmt.Errorf("invalid caller form '%s'", arg)
+		}
+		arg = ""
+
+	case "level":
+		segment = levelSegment
+
+	case "LEVEL":
+		segment = upperLevelSegment
+
+	case "prefix":
+		segment = prefixSegment
+
+	case "message":
+		segment = messageSegment
+
+	case "err":
+		l.fieldKeys[ErrKey] = true
+		segment = fieldSegment(ErrKey)
+
+	case "fields":
+		segment = l.fieldsSegment
+
+	default:
+		return nil, fmt.Errorf("unknown verb '%s'", verb)
+	}
+
+	// verbs which don't take an argument accept the padding spec in its place
+	if arg != "" {
+		if spec != "" {
+			return nil, fmt.Errorf("unexpected argument '%s' for {%s}", arg, verb)
+		}
+		spec = arg
+	}
+
+	if spec == "" {
+		return segment, nil
+	}
+
+	return padSegment(segment, spec)
+}
+
+func literalSegment(s string) layoutSegment {
+	return func(b []byte, e *Entry, tf *types.TimeFormat) []byte {
+		return append(b, s...)
+	}
+}
+
+// timeSegment renders the timestamp with the given layout, or in the time
+// format of the output if layout is empty
+func timeSegment(layout string) layoutSegment {
+	return func(b []byte, e *Entry, tf *types.TimeFormat) []byte {
+		if layout != "" {
+			return e.Timestamp.AppendFormat(b, layout)
+		}
+		return append(b, formatTime(e.Timestamp, tf)...)
+	}
+}
+
+func elapsedSegment(round time.Duration) layoutSegment {
+	return func(b []byte, e *Entry, tf *types.TimeFormat) []byte {
+		return append(b, e.Timestamp.Sub(startTime).Round(round).String()...)
+	}
+}
+
+func levelSegment(b []byte, e *Entry, tf *types.TimeFormat) []byte {
+	return append(b, e.Level.String()...)
+}
+
+func upperLevelSegment(b []byte, e *Entry, tf *types.TimeFormat) []byte {
+	return append(b, strings.ToUpper(e.Level.String())...)
+}
+
+func prefixSegment(b []byte, e *Entry, tf *types.TimeFormat) []byte {
+	return append(b, e.Prefix...)
+}
+
+func messageSegment(b []byte, e *Entry, tf *types.TimeFormat) []byte {
+	return append(b, e.Message...)
+}
+
+func shortCallerSegment(b []byte, e *Entry, tf *types.TimeFormat) []byte {
+	if e.Caller == nil {
+		return b
+	}
+	return append(b, e.Caller.String()...)
+}
+
+func longCallerSegment(b []byte, e *Entry, tf *types.TimeFormat) []byte {
+	if e.Caller == nil {
+		return b
+	}
+	b = append(b, e.Caller.File...)
+	b = append(b, ':')
+	return strconv.AppendInt(b, int64(e.Caller.Line), 10)
+}
+
+func funcCallerSegment(b []byte, e *Entry, tf *types.TimeFormat) []byte {
+	if e.Caller == nil {
+		return b
+	}
+	return append(b, e.Caller.Function...)
+}
+
+// fieldSegment renders the value of the field with the given key, if the
+// entry has one. Strings and errors are rendered without quotes.
+func fieldSegment(key string) layoutSegment {
+	return func(b []byte, e *Entry, tf *types.TimeFormat) []byte {
+		for _, fld := range e.Fields.contents {
+			if fld.Key != key {
+				continue
+			}
+
+			switch val := fld.Val.(type) {
+			case string:
+				return append(b, val...)
+			case error:
+				return append(b, val.Error()...)
+			}
+
+			enc := text.NewEncoder()
+			enc.SetTimeFormat(tf)
+			if err := encoding.EncodeValue(enc, fld.Val); err != nil {
+				return append(b, RenderMessage(fld.Val)...)
+			}
+			return append(b, enc.Bytes()...)
+		}
+
+		return b
+	}
+}
+
+// fieldsSegment renders all fields that aren't rendered individually
+func (l *textLayout) fieldsSegment(b []byte, e *Entry, tf *types.TimeFormat) []byte {
+	enc := text.NewEncoder()
+	enc.SetTimeFormat(tf)
+	for _, fld := range e.Fields.contents {
+		if l.fieldKeys[fld.Key] {
+			continue
+		}
+
+		if err := encoding.EncodeKeyValue(enc, fld.Key, fld.Val); err != nil {
+			return append(b, err.Error()...)
+		}
+	}
+
+	return append(b, enc.Bytes()...)
+}
+
+// padSegment wraps segment so that its output is padded and/or truncated
+// according to spec, which is of the form [>][width][.max]
+func padSegment(segment layoutSegment, spec string) (layoutSegment, error) {
+	match := layoutSpecPattern.FindStringSubmatch(spec)
+	if match == nil || (match[2] == "" && match[3] == "") {
+		return nil, fmt.Errorf("invalid padding '%s'", spec)
+	}
+
+	rightAlign := match[1] == ">"
+	width, _ := strconv.Atoi(match[2])
+	max := -1
+	if match[3] != "" {
+		max, _ = strconv.Atoi(match[3])
+	}
+
+	return func(b []byte, e *Entry, tf *types.TimeFormat) []byte {
+		start := len(b)
+		b = segment(b, e, tf)
+		n := utf8.RuneCount(b[start:])
+
+		if max >= 0 && n > max {
+			cut := start
+			for i := 0; i < max; i++ {
+				_, size := utf8.DecodeRune(b[cut:])
+				cut += size
+			}
+			b = b[:cut]
+			n = max
+		}
+
+		if n >= width {
+			return b
+		}
+
+		padding := width - n
+		if !rightAlign {
+			for ; padding > 0; padding-- {
+				b = append(b, ' ')
+			}
+			return b
+		}
+
+		rendered := string(b[start:])
+		b = b[:start]
+		for ; padding > 0; padding-- {
+			b = append(b, ' ')
+		}
+		return append(b, rendered...)
+	}, nil
+}
+
+func (l *textLayout) format(e *Entry, tf *types.TimeFormat) []byte {
+	b := make([]byte, 0, 256)
+	for _, segment := range l.segments {
+		b = segment(b, e, tf)
+	}
+	return append(b, '\n')
+}
@@ -0,0 +1,109 @@
+package lg_test
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/autopilothq/lg"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("layout output", func() {
+
+	var tlo *TestLogOutput
+
+	BeforeEach(func() {
+		tlo = &TestLogOutput{}
+		lg.RemoveOutput(os.Stdout)
+	})
+
+	AfterEach(func() {
+		lg.RemoveOutput(tlo)
+		lg.AddOutput(os.Stdout)
+		lg.SetReportCaller(false)
+	})
+
+	It("renders entries according to the layout", func() {
+		lg.AddOutput(tlo, lg.Layout("{level:5}|{prefix}|{message}|{fields}"))
+		lg.ExtendWithPrefix("Srv").Info("hi", lg.F{"a", 1}, lg.F{"b", "x"})
+
+		Expect(tlo.String()).To(Equal("info |Srv|hi|a:1 b:\"x\"\n"))
+	})
+
+	It("renders times with the given layout", func() {
+		lg.AddOutput(tlo, lg.Layout("{time:2006} {time}"))
+		lg.Info("hi")
+
+		Expect(tlo.String()).To(MatchRegexp(
+			`^[0-9]{4} [0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9:.]{12}\n$`))
+	})
+
+	It("renders individual fields, leaving them out of {fields}", func() {
+		lg.AddOutput(tlo, lg.Layout("[{field:id}] {message} {fields}: {err}"))
+		lg.Error("oops", lg.F{"id", "abc"}, lg.F{"n", 2},
+			lg.Err(errors.New("it broke")))
+
+		Expect(tlo.String()).To(Equal("[abc] oops n:2: it broke\n"))
+	})
+
+	It("pads and truncates", func() {
+		lg.AddOutput(tlo, lg.Layout(
+			"{LEVEL|>6}|{prefix|6.3}|{message|.2}|{{literal}}"))
+		lg.ExtendWithPrefix("Server").Warn("hello")
+
+		Expect(tlo.String()).To(Equal("  WARN|Ser   |he|{literal}\n"))
+	})
+
+	It("renders the caller", func() {
+		lg.AddOutput(tlo, lg.Layout("{caller} {caller:func}"))
+		lg.Info("hi")
+
+		Expect(tlo.String()).To(MatchRegexp(
+			`^[^/ ]+/layout_test.go:[0-9]+ .*lg_test\..*\n$`))
+	})
+
+	It("stops recording the caller once no output needs it", func() {
+		var caller *lg.Caller
+		hookID := lg.AddHook(func(e *lg.Entry) error {
+			caller = e.Caller
+			return nil
+		})
+		defer lg.RemoveHook(hookID)
+
+		lg.Formatter(tlo, lg.Layout("{caller}"))
+		lg.Info("formatter only")
+		Expect(caller).To(BeNil())
+
+		lg.AddOutput(tlo, lg.Layout("{caller}"))
+		lg.Info("with output")
+		Expect(caller).NotTo(BeNil())
+
+		lg.RemoveOutput(tlo)
+		lg.Info("after removal")
+		Expect(caller).To(BeNil())
+	})
+
+	It("renders the caller through extended logs", func() {
+		lg.AddOutput(tlo, lg.Layout("{caller}"))
+		lg.Extend(lg.F{"a", 1}).Infof("hi %d", 1)
+
+		Expect(tlo.String()).To(MatchRegexp(`^[^/ ]+/layout_test.go:[0-9]+\n$`))
+	})
+
+	It("renders the elapsed time", func() {
+		lg.AddOutput(tlo, lg.Layout("{elapsed:1h}"))
+		lg.Info("hi")
+
+		Expect(tlo.String()).To(Equal(time.Duration(0).String() + "\n"))
+	})
+
+	It("panics on invalid layouts", func() {
+		Expect(func() { lg.Layout("{nope}") }).To(Panic())
+		Expect(func() { lg.Layout("{message") }).To(Panic())
+		Expect(func() { lg.Layout("{level|x}") }).To(Panic())
+		Expect(func() { lg.Layout("{field}") }).To(Panic())
+	})
+})
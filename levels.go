@@ -62,6 +62,22 @@ func (l Level) MarshalJSON() ([]byte, error) {
 	return json.Marshal(l.String())
 }
 
+// UnmarshalJSON parses a level written by MarshalJSON
+func (l *Level) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	level, err := ParseLevel(s)
+	if err != nil {
+		return err
+	}
+
+	*l = level
+	return nil
+}
+
 // ParseLevel transforms a string log level into a Level type. Returns an error
 // if the given level is invalid.
 func ParseLevel(level string) (Level, error) {
@@ -166,3 +166,18 @@ func Panicf(pattern string, args ...interface{}) {
 	entry, _ := addFormattedEntry(LevelFatal, "", pattern, args)
 	panic(entry.Message)
 }
+
+// Emit logs a message at the given level, and returns an error if any output
+// failed to write it. Combined with durable outputs, such as a FileOutput
+// with SyncEvery(1), it returns only once the entry is on disk.
+func Emit(level Level, args ...interface{}) error {
+	_, err := addEntry(level, "", args)
+	return err
+}
+
+// Emitf logs a formatted message at the given level, and returns an error if
+// any output failed to write it
+func Emitf(level Level, pattern string, args ...interface{}) error {
+	_, err := addFormattedEntry(level, "", pattern, args)
+	return err
+}
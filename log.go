@@ -41,3 +41,10 @@ type Log interface {
 	Extend(f ...F) Log
 	ExtendPrefix(prefix string, f ...F) Log
 }
+
+// Emitter is implemented by logs which can report whether entries were
+// written, such as those returned by Extend
+type Emitter interface {
+	Emit(level Level, args ...interface{}) error
+	Emitf(level Level, pattern string, args ...interface{}) error
+}
@@ -0,0 +1,206 @@
+package lg
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/autopilothq/lg/encoding"
+	fancy "github.com/autopilothq/lg/encoding/json"
+	"github.com/autopilothq/lg/encoding/logfmt"
+	"github.com/autopilothq/lg/encoding/types"
+)
+
+var lokiInvalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// lokiLabelName returns a valid label name for key, with characters that
+// aren't allowed replaced with underscores
+func lokiLabelName(key string) string {
+	name := lokiInvalidLabelChars.ReplaceAllLiteralString(key, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// lokiFormatter renders entries as Loki streams with a single value, i.e.
+// {"stream":{"level":"info"},"values":[["<ns>","msg=hello"]]}
+type lokiFormatter struct {
+	levelLabel  bool
+	prefixLabel bool
+	fieldLabels map[string]string
+	static      []F
+	timeFormat  *types.TimeFormat
+}
+
+func newLokiFormatter(options *Options) *lokiFormatter {
+	keys := options.lokiLabels
+	if keys == nil {
+		keys = []string{"level", "prefix"}
+	}
+
+	l := &lokiFormatter{
+		fieldLabels: make(map[string]string),
+		timeFormat:  options.timeFormat,
+	}
+
+	for _, key := range keys {
+		switch key {
+		case "level":
+			l.levelLabel = true
+		case "prefix":
+			l.prefixLabel = true
+		default:
+			l.fieldLabels[key] = lokiLabelName(key)
+		}
+	}
+
+	for _, label := range options.lokiStaticLabels {
+		l.static = append(l.static, F{lokiLabelName(label.Key), label.Val})
+	}
+
+	return l
+}
+
+func (l *lokiFormatter) format(e *Entry) []byte {
+	enc := fancy.NewEncoder()
+
+	if err := l.encode(enc, e); err != nil {
+		return makeJSONError(enc, err)
+	}
+
+	return append(enc.Bytes(), '\n')
+}
+
+func (l *lokiFormatter) encode(enc *fancy.Encoder, e *Entry) (err error) {
+	if err = enc.StartObject(); err != nil {
+		return err
+	}
+
+	if err = enc.AddKey("stream"); err != nil {
+		return err
+	}
+
+	if err = l.encodeLabels(enc, e); err != nil {
+		return err
+	}
+
+	if err = enc.AddKey("values"); err != nil {
+		return err
+	}
+
+	if err = enc.StartArray(); err != nil {
+		return err
+	}
+
+	if err = enc.StartArray(); err != nil {
+		return err
+	}
+
+	if err = enc.AddString(strconv.FormatInt(e.Timestamp.UnixNano(), 10)); err != nil {
+		return err
+	}
+
+	line, err := l.line(e)
+	if err != nil {
+		return err
+	}
+
+	if err = enc.AddString(line); err != nil {
+		return err
+	}
+
+	if err = enc.EndArray(); err != nil {
+		return err
+	}
+
+	if err = enc.EndArray(); err != nil {
+		return err
+	}
+
+	return enc.EndObject()
+}
+
+func (l *lokiFormatter) encodeLabels(enc *fancy.Encoder, e *Entry) (err error) {
+	if err = enc.StartObject(); err != nil {
+		return err
+	}
+
+	for _, label := range l.static {
+		if err = encoding.EncodeKeyValue(enc, label.Key, label.Val); err != nil {
+			return err
+		}
+	}
+
+	if l.levelLabel {
+		if err = encoding.EncodeStringKeyValue(enc, "level", e.Level.String()); err != nil {
+			return err
+		}
+	}
+
+	if l.prefixLabel && e.Prefix != "" {
+		if err = encoding.EncodeStringKeyValue(enc, "prefix", e.Prefix); err != nil {
+			return err
+		}
+	}
+
+	for _, fld := range e.Fields.contents {
+		if name, ok := l.fieldLabels[fld.Key]; ok {
+			err = encoding.EncodeStringKeyValue(enc, name, renderRawValue(fld.Val))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return enc.EndObject()
+}
+
+// line renders everything about an entry that isn't written as a label in
+// logfmt, with the err field last
+func (l *lokiFormatter) line(e *Entry) (string, error) {
+	enc := logfmt.NewEncoder()
+	enc.SetTimeFormat(l.timeFormat)
+
+	if !l.levelLabel {
+		if err := encoding.EncodeStringKeyValue(enc, "level", e.Level.String()); err != nil {
+			return "", err
+		}
+	}
+
+	if !l.prefixLabel && e.Prefix != "" {
+		if err := encoding.EncodeStringKeyValue(enc, "prefix", e.Prefix); err != nil {
+			return "", err
+		}
+	}
+
+	if err := encoding.EncodeStringKeyValue(enc, "msg", e.Message); err != nil {
+		return "", err
+	}
+
+	var errVal interface{}
+	hasErr := false
+
+	for _, fld := range e.Fields.contents {
+		if _, ok := l.fieldLabels[fld.Key]; ok {
+			continue
+		}
+
+		if fld.Key == ErrKey {
+			errVal, hasErr = fld.Val, true
+			continue
+		}
+
+		if err := encoding.EncodeKeyValue(enc, fld.Key, fld.Val); err != nil {
+			return "", err
+		}
+	}
+
+	if hasErr {
+		err := encoding.EncodeStringKeyValue(enc, ErrKey, RenderMessage(errVal))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return enc.String(), nil
+}
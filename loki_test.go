@@ -0,0 +1,173 @@
+package lg_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/autopilothq/lg"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type lokiPush struct {
+	Streams []struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	} `json:"streams"`
+}
+
+var _ = Describe("Loki output", func() {
+
+	var tlo *TestLogOutput
+
+	BeforeEach(func() {
+		tlo = &TestLogOutput{}
+		lg.RemoveOutput(os.Stdout)
+	})
+
+	AfterEach(func() {
+		lg.RemoveOutput(tlo)
+		lg.AddOutput(os.Stdout)
+	})
+
+	It("writes the level and prefix as labels, and the rest as logfmt", func() {
+		lg.AddOutput(tlo, lg.Loki())
+		lg.ExtendWithPrefix("Server").Warn("request done",
+			lg.Err(errors.New("it broke")), lg.F{"status", 500})
+
+		Expect(tlo.String()).To(MatchRegexp(
+			`^\{"stream":\{"level":"warn","prefix":"Server"\},` +
+				`"values":\[\["[0-9]{19}","msg=\\"request done\\" status=500 err=\\"it broke\\""\]\]\}\n$`))
+	})
+
+	It("writes the given fields and static labels as labels", func() {
+		lg.AddOutput(tlo, lg.LokiLabels("region", "user id"),
+			lg.LokiStaticLabel("app-name", "api"))
+		lg.Info("hello", lg.F{"region", "eu"}, lg.F{"user id", 42}, lg.F{"path", "/"})
+
+		var s struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		}
+		Expect(json.Unmarshal(tlo.Bytes(), &s)).To(Succeed())
+		Expect(s.Stream).To(Equal(map[string]string{
+			"app_name": "api",
+			"region":   "eu",
+			"user_id":  "42",
+		}))
+		Expect(s.Values[0][1]).To(Equal("level=info msg=hello path=/"))
+	})
+
+	Describe("LokiWriter", func() {
+
+		var server *ingestServer
+
+		BeforeEach(func() {
+			server = newIngestServer()
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		decodePush := func(body string) lokiPush {
+			var push lokiPush
+			Expect(json.Unmarshal([]byte(body), &push)).To(Succeed())
+			return push
+		}
+
+		It("groups entries into streams", func() {
+			w := lg.LokiOutput(server.URL)
+			lg.AddOutput(w, lg.Loki())
+			lg.Info("one")
+			lg.Warn("two")
+			lg.Info("three")
+			lg.RemoveOutput(w)
+			Expect(w.Close()).To(Succeed())
+
+			Expect(server.Requests()[0].header.Get("Content-Type")).To(
+				Equal("application/json"))
+
+			push := decodePush(server.Bodies()[0])
+			Expect(push.Streams).To(HaveLen(2))
+			Expect(push.Streams[0].Stream).To(Equal(map[string]string{"level": "info"}))
+			Expect(push.Streams[0].Values).To(HaveLen(2))
+			Expect(push.Streams[0].Values[0][1]).To(Equal("msg=one"))
+			Expect(push.Streams[0].Values[1][1]).To(Equal("msg=three"))
+			Expect(push.Streams[1].Stream).To(Equal(map[string]string{"level": "warn"}))
+			Expect(push.Streams[1].Values[0][1]).To(Equal("msg=two"))
+		})
+
+		It("sends entries in timestamp order within each stream", func() {
+			w := lg.LokiOutput(server.URL,
+				lg.LokiHTTP(lg.HTTPBatch(2, 1<<20, time.Hour)))
+			w.Write([]byte(`{"stream":{"level":"info"},"values":[["300","c"]]}`))
+			w.Write([]byte(`{"stream":{"level":"info"},"values":[["200","b"]]}`))
+			Eventually(server.Bodies).Should(HaveLen(1))
+
+			// older than the last entry sent
+			w.Write([]byte(`{"stream":{"level":"info"},"values":[["100","a"]]}`))
+			Expect(w.Close()).To(Succeed())
+
+			first := decodePush(server.Bodies()[0])
+			Expect(first.Streams[0].Values).To(Equal([][2]string{{"200", "b"}, {"300", "c"}}))
+
+			second := decodePush(server.Bodies()[1])
+			Expect(second.Streams[0].Values).To(Equal([][2]string{{"300", "a"}}))
+		})
+
+		It("forgets streams which aren't in the last batch", func() {
+			w := lg.LokiOutput(server.URL,
+				lg.LokiHTTP(lg.HTTPBatch(2, 1<<20, time.Hour)))
+			w.Write([]byte(`{"stream":{"level":"info"},"values":[["300","c"]]}`))
+			w.Write([]byte(`{"stream":{"level":"info"},"values":[["200","b"]]}`))
+			Eventually(server.Bodies).Should(HaveLen(1))
+			w.Write([]byte(`{"stream":{"level":"warn"},"values":[["400","d"]]}`))
+			w.Write([]byte(`{"stream":{"level":"warn"},"values":[["500","e"]]}`))
+			Eventually(server.Bodies).Should(HaveLen(2))
+
+			w.Write([]byte(`{"stream":{"level":"info"},"values":[["100","a"]]}`))
+			Expect(w.Close()).To(Succeed())
+
+			third := decodePush(server.Bodies()[2])
+			Expect(third.Streams[0].Values).To(Equal([][2]string{{"100", "a"}}))
+		})
+
+		It("sends snappy compressed protocol buffers", func() {
+			w := lg.LokiOutput(server.URL, lg.LokiProtobuf())
+			ts := time.Date(2017, 9, 15, 0, 16, 43, 848000000, time.UTC).UnixNano()
+			w.Write([]byte(`{"stream":{"level":"info"},"values":[["` +
+				strconv.FormatInt(ts, 10) + `","msg=hello"]]}`))
+			Expect(w.Close()).To(Succeed())
+
+			req := server.Requests()[0]
+			Expect(req.header.Get("Content-Type")).To(Equal("application/x-protobuf"))
+
+			body, err := snappy.Decode(nil, []byte(req.body))
+			Expect(err).NotTo(HaveOccurred())
+
+			// PushRequest{streams: [{labels: `{level="info"}`, entries: [
+			//   {timestamp: {seconds: 1505434603, nanos: 848000000},
+			//    line: "msg=hello"}]}]}
+			Expect(body).To(Equal([]byte("\x0a\x2b" +
+				"\x0a\x0e" + `{level="info"}` +
+				"\x12\x19" +
+				"\x0a\x0c\x08\xeb\xb7\xec\xcd\x05\x10\x80\xe8\xad\x94\x03" +
+				"\x12\x09msg=hello")))
+		})
+
+		It("only accepts entries in the Loki format", func() {
+			w := lg.LokiOutput(server.URL)
+			defer w.Close()
+
+			_, err := w.Write([]byte(`{"m":"hello"}`))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
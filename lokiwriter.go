@@ -0,0 +1,260 @@
+package lg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang/snappy"
+)
+
+var errNotLokiFormat = errors.New(
+	"lg: entries written to a LokiWriter must be in the Loki format")
+
+// LokiOptions configures a LokiWriter
+type LokiOptions struct {
+	HTTPOptions
+	protobuf bool
+}
+
+// LokiHTTP applies options for HTTP writers, e.g. HTTPHeader, to a LokiWriter
+func LokiHTTP(opts ...func(*HTTPOptions)) func(*LokiOptions) {
+	return func(o *LokiOptions) {
+		for _, opt := range opts {
+			opt(&o.HTTPOptions)
+		}
+	}
+}
+
+// LokiProtobuf sends batches to Loki as snappy compressed protocol buffers,
+// rather than as JSON. HTTPGzip has no effect on them.
+func LokiProtobuf() func(*LokiOptions) {
+	return func(o *LokiOptions) {
+		o.protobuf = true
+	}
+}
+
+// lokiStream is a stream of entries in the Loki push API, and is also what
+// the Loki format writes each entry as
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// lokiValue is an entry in a stream
+type lokiValue struct {
+	ts   int64
+	line string
+}
+
+// LokiWriter pushes entries in the Loki format to Grafana Loki, in batches
+// sent as JSON or, with LokiProtobuf, as snappy compressed protocol buffers.
+// Entries are grouped into streams by their labels, and are sent in
+// timestamp order within each stream. Batching, retries and headers, e.g.
+// X-Scope-OrgID for multi-tenant Loki, are configured as for an HTTPWriter,
+// with LokiHTTP.
+//
+// Example:
+//
+//   w := lg.LokiOutput("http://loki:3100/loki/api/v1/push", lg.LokiProtobuf())
+//   defer w.Close()
+//   lg.AddOutput(w, lg.LokiLabels("level", "prefix", "region"),
+//     lg.LokiStaticLabel("app", "api"))
+type LokiWriter struct {
+	url     string
+	options LokiOptions
+	batcher *batcher
+
+	// last is the timestamp of the last entry sent to each stream in the last
+	// batch, as an entry older than that would be rejected. Streams which
+	// aren't in a batch are forgotten, so it doesn't grow with every stream
+	// that has ever been written to.
+	mutex sync.Mutex
+	last  map[string]int64
+}
+
+// LokiOutput returns a writer which pushes entries to url, i.e. the
+// /loki/api/v1/push endpoint of a Loki server
+func LokiOutput(url string, opts ...func(*LokiOptions)) *LokiWriter {
+	w := &LokiWriter{
+		url:     url,
+		options: LokiOptions{HTTPOptions: makeHTTPOptions(nil)},
+		last:    make(map[string]int64),
+	}
+
+	for _, opt := range opts {
+		opt(&w.options)
+	}
+
+	w.batcher = newBatcher(redactURL(url), w.options.batch, w.send)
+
+	return w
+}
+
+// Write queues p, which must be an entry in the Loki format, to be pushed. It
+// fails with ErrQueueFull if the queue is full.
+func (w *LokiWriter) Write(p []byte) (int, error) {
+	if !bytes.HasPrefix(p, []byte(`{"stream":`)) {
+		return 0, errNotLokiFormat
+	}
+
+	if err := w.batcher.add(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of entries that have been dropped, because the
+// queue was full or they couldn't be sent
+func (w *LokiWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.batcher.dropped)
+}
+
+// Close pushes any queued entries, waiting at most the flush timeout
+func (w *LokiWriter) Close() error {
+	return w.batcher.close()
+}
+
+// lokiLabels renders labels in the form Loki identifies streams by, e.g.
+// {app="api", level="info"}
+func lokiLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[name]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func (w *LokiWriter) send(ctx context.Context, batch [][]byte) error {
+	var keys []string
+	labels := make(map[string]map[string]string)
+	values := make(map[string][]lokiValue)
+
+	for _, entry := range batch {
+		var s lokiStream
+		if err := json.Unmarshal(entry, &s); err != nil {
+			return err
+		}
+
+		key := lokiLabels(s.Stream)
+		if _, ok := labels[key]; !ok {
+			keys = append(keys, key)
+			labels[key] = s.Stream
+		}
+
+		for _, v := range s.Values {
+			ts, err := strconv.ParseInt(v[0], 10, 64)
+			if err != nil {
+				return err
+			}
+			values[key] = append(values[key], lokiValue{ts, v[1]})
+		}
+	}
+
+	// sort each stream, and move any entry that is older than one that has
+	// already been sent up to the time of that one
+	w.mutex.Lock()
+	last := make(map[string]int64, len(keys))
+	for _, key := range keys {
+		vals := values[key]
+		sort.SliceStable(vals, func(i, j int) bool {
+			return vals[i].ts < vals[j].ts
+		})
+
+		for i := range vals {
+			if vals[i].ts < w.last[key] {
+				vals[i].ts = w.last[key]
+			}
+		}
+		last[key] = vals[len(vals)-1].ts
+	}
+	w.mutex.Unlock()
+
+	var (
+		err     error
+		body    []byte
+		options = w.options.HTTPOptions
+	)
+	contentType := "application/json"
+	if w.options.protobuf {
+		contentType = "application/x-protobuf"
+		options.gzip = false
+		body = snappy.Encode(nil, encodeLokiProtobuf(keys, values))
+	} else if body, err = encodeLokiJSON(keys, labels, values); err != nil {
+		return err
+	}
+
+	if err = postBatch(ctx, w.url, &options, contentType, body); err != nil {
+		return err
+	}
+
+	w.mutex.Lock()
+	w.last = last
+	w.mutex.Unlock()
+
+	return nil
+}
+
+// encodeLokiJSON returns the body of a JSON push request
+func encodeLokiJSON(
+	keys []string,
+	labels map[string]map[string]string,
+	values map[string][]lokiValue,
+) ([]byte, error) {
+	var req struct {
+		Streams []lokiStream `json:"streams"`
+	}
+
+	for _, key := range keys {
+		s := lokiStream{Stream: labels[key]}
+		for _, v := range values[key] {
+			s.Values = append(s.Values,
+				[2]string{strconv.FormatInt(v.ts, 10), v.line})
+		}
+		req.Streams = append(req.Streams, s)
+	}
+
+	return json.Marshal(req)
+}
+
+// encodeLokiProtobuf returns the body of a protocol buffer push request,
+// before it is compressed, i.e. a logproto.PushRequest
+func encodeLokiProtobuf(keys []string, values map[string][]lokiValue) []byte {
+	var req, stream, entry, ts []byte
+
+	for _, key := range keys {
+		stream = appendProtoString(stream[:0], 1, key)
+
+		for _, v := range values[key] {
+			ts = appendProtoVarint(ts[:0], 1, uint64(v.ts/1e9))
+			ts = appendProtoVarint(ts, 2, uint64(v.ts%1e9))
+
+			entry = appendProtoBytes(entry[:0], 1, ts)
+			entry = appendProtoString(entry, 2, v.line)
+
+			stream = appendProtoBytes(stream, 2, entry)
+		}
+
+		req = appendProtoBytes(req, 1, stream)
+	}
+
+	return req
+}
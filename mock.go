@@ -104,7 +104,7 @@ func (m *MockLog) Dump() string {
 	defer m.mutex.RUnlock()
 
 	for _, e := range m.entries {
-		contents.Write(e.toPlainText())
+		contents.Write(e.toPlainText(nil))
 	}
 	return contents.String()
 }
@@ -316,3 +316,17 @@ func (m *MockLog) Panicln(args ...interface{}) {
 func (m *MockLog) Panicf(pattern string, args ...interface{}) {
 	panic(m.addFormattedEntry(LevelFatal, m.prefix, pattern, m.mergeArgs(args)).Message)
 }
+
+// Emit logs a message at the given level. It never fails.
+func (m *MockLog) Emit(level Level, args ...interface{}) error {
+	m.addEntry(level, m.prefix, m.mergeArgs(args))
+	return nil
+}
+
+// Emitf logs a formatted message at the given level. It never fails.
+func (m *MockLog) Emitf(
+	level Level, pattern string, args ...interface{},
+) error {
+	m.addFormattedEntry(level, m.prefix, pattern, m.mergeArgs(args))
+	return nil
+}
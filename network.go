@@ -0,0 +1,346 @@
+package lg
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBufferFull is returned when an entry is dropped because a network
+// output's buffer is full
+var ErrBufferFull = errors.New("lg: network output buffer is full")
+
+// NetworkOptions configures a NetworkWriter
+type NetworkOptions struct {
+	tlsConfig      *tls.Config
+	lengthPrefixed bool
+	bufferSize     int
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+	dialTimeout    time.Duration
+	flushTimeout   time.Duration
+}
+
+// NetworkTLS connects to the collector with TLS, using the given configuration
+func NetworkTLS(config *tls.Config) func(*NetworkOptions) {
+	return func(o *NetworkOptions) {
+		o.tlsConfig = config
+	}
+}
+
+// LengthPrefixed frames entries with their length, as a 4 byte big endian
+// integer, rather than terminating them with a new line
+func LengthPrefixed() func(*NetworkOptions) {
+	return func(o *NetworkOptions) {
+		o.lengthPrefixed = true
+	}
+}
+
+// NetworkBufferSize sets how many bytes of entries are held while the collector
+// can't be reached. Entries that don't fit are dropped. Defaults to 1MB.
+func NetworkBufferSize(size int) func(*NetworkOptions) {
+	return func(o *NetworkOptions) {
+		o.bufferSize = size
+	}
+}
+
+// NetworkBackoff sets the delay before reconnecting after a failure, which starts at
+// min and doubles with each failure up to max. Defaults to 100ms and 30s.
+func NetworkBackoff(min, max time.Duration) func(*NetworkOptions) {
+	return func(o *NetworkOptions) {
+		o.minBackoff = min
+		o.maxBackoff = max
+	}
+}
+
+// NetworkFlushTimeout sets how long Close waits for buffered entries to be sent.
+// Defaults to 5s.
+func NetworkFlushTimeout(timeout time.Duration) func(*NetworkOptions) {
+	return func(o *NetworkOptions) {
+		o.flushTimeout = timeout
+	}
+}
+
+// NetworkWriter streams entries to a collector over TCP or TLS. Writes don't
+// block: entries are buffered and sent in the background, reconnecting with
+// exponential backoff whenever the connection fails. While the collector
+// can't be reached entries are held in a buffer of limited size, and any that
+// don't fit are dropped and counted.
+//
+// Example:
+//
+//   w := lg.NetworkOutput("tcp", "collector:5170",
+//     lg.NetworkTLS(&tls.Config{ServerName: "collector"}))
+//   defer w.Close()
+//   lg.AddOutput(w, lg.JSON())
+type NetworkWriter struct {
+	network string
+	address string
+	options NetworkOptions
+
+	mutex       sync.Mutex
+	queue       [][]byte
+	queuedBytes int
+	closed      bool
+
+	pending chan struct{}
+	closing chan struct{}
+	abort   chan struct{}
+	done    chan struct{}
+
+	dropped         uint64
+	droppedReported uint64
+	conn            net.Conn
+}
+
+// NetworkOutput returns a writer which streams entries to address, over
+// network, e.g. "tcp". It connects in the background, so doesn't fail if the
+// collector can't be reached.
+func NetworkOutput(
+	network, address string, opts ...func(*NetworkOptions),
+) *NetworkWriter {
+	w := &NetworkWriter{
+		network: network,
+		address: address,
+		options: NetworkOptions{
+			bufferSize:   1 << 20,
+			minBackoff:   100 * time.Millisecond,
+			maxBackoff:   30 * time.Second,
+			dialTimeout:  10 * time.Second,
+			flushTimeout: 5 * time.Second,
+		},
+		pending: make(chan struct{}, 1),
+		closing: make(chan struct{}),
+		abort:   make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(&w.options)
+	}
+
+	go w.run()
+
+	return w
+}
+
+// frame returns p framed for sending
+func (w *NetworkWriter) frame(p []byte) []byte {
+	if w.options.lengthPrefixed {
+		msg := trimNewLine(p)
+		framed := make([]byte, 4, 4+len(msg))
+		binary.BigEndian.PutUint32(framed, uint32(len(msg)))
+		return append(framed, msg...)
+	}
+
+	framed := make([]byte, len(p), len(p)+1)
+	copy(framed, p)
+	if len(framed) == 0 || framed[len(framed)-1] != '\n' {
+		framed = append(framed, '\n')
+	}
+	return framed
+}
+
+func trimNewLine(p []byte) []byte {
+	if len(p) > 0 && p[len(p)-1] == '\n' {
+		return p[:len(p)-1]
+	}
+	return p
+}
+
+// Write queues p to be sent as a single entry. It fails with ErrBufferFull if
+// the entry doesn't fit in the buffer.
+func (w *NetworkWriter) Write(p []byte) (int, error) {
+	framed := w.frame(p)
+
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return 0, os.ErrClosed
+	}
+
+	if w.queuedBytes+len(framed) > w.options.bufferSize {
+		w.mutex.Unlock()
+		atomic.AddUint64(&w.dropped, 1)
+		return 0, ErrBufferFull
+	}
+
+	w.queue = append(w.queue, framed)
+	w.queuedBytes += len(framed)
+	w.mutex.Unlock()
+
+	select {
+	case w.pending <- struct{}{}:
+	default:
+	}
+
+	return len(p), nil
+}
+
+// Dropped returns the number of entries that have been dropped because the
+// buffer was full
+func (w *NetworkWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Close sends any buffered entries, waiting at most the flush timeout, and
+// closes the connection
+func (w *NetworkWriter) Close() error {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mutex.Unlock()
+
+	close(w.closing)
+
+	select {
+	case <-w.done:
+		return nil
+	case <-time.After(w.options.flushTimeout):
+		close(w.abort)
+		<-w.done
+		return errors.New("lg: timed out sending buffered entries")
+	}
+}
+
+// next returns the entry at the head of the queue, if there is one
+func (w *NetworkWriter) next() ([]byte, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if len(w.queue) == 0 {
+		return nil, false
+	}
+	return w.queue[0], true
+}
+
+// pop removes the entry at the head of the queue, once it has been sent
+func (w *NetworkWriter) pop() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.queuedBytes -= len(w.queue[0])
+	w.queue[0] = nil
+	w.queue = w.queue[1:]
+}
+
+// run sends queued entries in the background until the writer is closed and
+// the queue is empty, or Close gives up waiting
+func (w *NetworkWriter) run() {
+	defer close(w.done)
+	defer func() {
+		if w.conn != nil {
+			w.conn.Close()
+		}
+	}()
+
+	// cancel any connection attempt in progress if Close gives up waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-w.abort:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	draining := false
+	backoff := w.options.minBackoff
+	for {
+		msg, ok := w.next()
+		if !ok {
+			if draining {
+				return
+			}
+
+			select {
+			case <-w.pending:
+			case <-w.closing:
+				// nothing more can be queued, so stop once the queue is empty
+				draining = true
+			case <-w.abort:
+				return
+			}
+			continue
+		}
+
+		if err := w.send(ctx, msg); err != nil {
+			if w.conn != nil {
+				w.conn.Close()
+				w.conn = nil
+			}
+
+			select {
+			case <-time.After(backoff):
+			case <-w.abort:
+				return
+			}
+
+			if backoff *= 2; backoff > w.options.maxBackoff {
+				backoff = w.options.maxBackoff
+			}
+			continue
+		}
+
+		backoff = w.options.minBackoff
+		w.pop()
+	}
+}
+
+func (w *NetworkWriter) send(ctx context.Context, msg []byte) error {
+	if w.conn == nil {
+		if err := w.connect(ctx); err != nil {
+			return err
+		}
+		w.reportDropped()
+	}
+
+	// don't let a collector that has stopped reading stall the queue forever
+	if err := w.conn.SetWriteDeadline(
+		time.Now().Add(w.options.dialTimeout)); err != nil {
+		return err
+	}
+
+	_, err := w.conn.Write(msg)
+	return err
+}
+
+func (w *NetworkWriter) connect(ctx context.Context) (err error) {
+	dialer := &net.Dialer{Timeout: w.options.dialTimeout}
+	if w.options.tlsConfig != nil {
+		// tls.DialWithDialer doesn't take a context, so the dial is cancelled
+		// through the dialer instead, and the handshake is bounded by its
+		// timeout
+		dialer.Cancel = ctx.Done()
+		w.conn, err = tls.DialWithDialer(
+			dialer, w.network, w.address, w.options.tlsConfig)
+	} else {
+		w.conn, err = dialer.DialContext(ctx, w.network, w.address)
+	}
+	return err
+}
+
+// reportDropped writes the number of entries dropped since the last report
+// to stderr, as they can't be reported to the collector itself
+func (w *NetworkWriter) reportDropped() {
+	dropped := atomic.LoadUint64(&w.dropped)
+	if dropped == w.droppedReported {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr,
+		"lg: dropped %d entries for %s while it was unreachable\n",
+		dropped-w.droppedReported, w.address)
+	w.droppedReported = dropped
+}
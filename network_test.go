@@ -0,0 +1,252 @@
+package lg_test
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"time"
+
+	"github.com/autopilothq/lg"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// collector accepts connections from a NetworkWriter, and reads the
+// entries sent over them
+type collector struct {
+	listener net.Listener
+	lines    chan string
+}
+
+func newCollector(listener net.Listener, lengthPrefixed bool) *collector {
+	c := &collector{listener: listener, lines: make(chan string, 100)}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					var line string
+					if lengthPrefixed {
+						var size uint32
+						if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+							return
+						}
+						buf := make([]byte, size)
+						if _, err := io.ReadFull(r, buf); err != nil {
+							return
+						}
+						line = string(buf)
+					} else {
+						if line, err = r.ReadString('\n'); err != nil {
+							return
+						}
+					}
+					c.lines <- line
+				}
+			}()
+		}
+	}()
+
+	return c
+}
+
+func listenTCP(address string) net.Listener {
+	listener, err := net.Listen("tcp", address)
+	Expect(err).NotTo(HaveOccurred())
+	return listener
+}
+
+func selfSignedCert() tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "collector"},
+		DNSNames:     []string{"collector"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(
+		rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+var _ = Describe("Network output", func() {
+
+	var w *lg.NetworkWriter
+
+	BeforeEach(func() {
+		lg.RemoveOutput(os.Stdout)
+	})
+
+	AfterEach(func() {
+		if w != nil {
+			lg.RemoveOutput(w)
+			w.Close()
+			w = nil
+		}
+		lg.AddOutput(os.Stdout)
+	})
+
+	It("sends entries terminated by new lines", func() {
+		listener := listenTCP("127.0.0.1:0")
+		defer listener.Close()
+		c := newCollector(listener, false)
+
+		w = lg.NetworkOutput("tcp", listener.Addr().String())
+		lg.AddOutput(w, lg.JSON())
+		lg.Info("one")
+		lg.Info("two")
+
+		Eventually(c.lines).Should(Receive(ContainSubstring(`"m":"one"}` + "\n")))
+		Eventually(c.lines).Should(Receive(ContainSubstring(`"m":"two"}` + "\n")))
+	})
+
+	It("sends entries prefixed with their length", func() {
+		listener := listenTCP("127.0.0.1:0")
+		defer listener.Close()
+		c := newCollector(listener, true)
+
+		w = lg.NetworkOutput("tcp", listener.Addr().String(), lg.LengthPrefixed())
+		lg.AddOutput(w, lg.JSON())
+		lg.Info("one")
+
+		var line string
+		Eventually(c.lines).Should(Receive(&line))
+		Expect(line).To(HavePrefix("{"))
+		Expect(line).To(HaveSuffix(`"m":"one"}`))
+	})
+
+	It("buffers entries until the collector can be reached", func() {
+		listener := listenTCP("127.0.0.1:0")
+		address := listener.Addr().String()
+		listener.Close()
+
+		w = lg.NetworkOutput("tcp", address,
+			lg.NetworkBackoff(10*time.Millisecond, 50*time.Millisecond))
+		lg.AddOutput(w, lg.JSON())
+		lg.Info("one")
+		lg.Info("two")
+		time.Sleep(100 * time.Millisecond)
+
+		listener = listenTCP(address)
+		defer listener.Close()
+		c := newCollector(listener, false)
+
+		Eventually(c.lines, 2*time.Second).Should(Receive(ContainSubstring(`"m":"one"`)))
+		Eventually(c.lines).Should(Receive(ContainSubstring(`"m":"two"`)))
+	})
+
+	It("reconnects when the connection is lost", func() {
+		first := listenTCP("127.0.0.1:0")
+		address := first.Addr().String()
+
+		// accept a single connection, and close it straight away
+		go func() {
+			conn, err := first.Accept()
+			if err == nil {
+				conn.Close()
+			}
+			first.Close()
+		}()
+
+		w = lg.NetworkOutput("tcp", address,
+			lg.NetworkBackoff(10*time.Millisecond, 50*time.Millisecond))
+		lg.AddOutput(w, lg.JSON())
+		lg.Info("first")
+		time.Sleep(50 * time.Millisecond)
+
+		listener := listenTCP(address)
+		defer listener.Close()
+		c := newCollector(listener, false)
+
+		lg.Info("second")
+		Eventually(c.lines, 2*time.Second).Should(Receive(ContainSubstring(`"m":"second"`)))
+	})
+
+	It("drops entries that don't fit in the buffer", func() {
+		listener := listenTCP("127.0.0.1:0")
+		address := listener.Addr().String()
+		listener.Close()
+
+		w = lg.NetworkOutput("tcp", address, lg.NetworkBufferSize(100))
+		lg.AddOutput(w, lg.JSON())
+
+		Expect(lg.Emit(lg.LevelInfo, "fits")).To(Succeed())
+		err := lg.Emit(lg.LevelInfo, "doesn't fit, as the buffer only has room for one entry")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(lg.ErrBufferFull.Error()))
+		Expect(w.Dropped()).To(Equal(uint64(1)))
+	})
+
+	It("sends buffered entries when closed", func() {
+		listener := listenTCP("127.0.0.1:0")
+		defer listener.Close()
+		c := newCollector(listener, false)
+
+		nw := lg.NetworkOutput("tcp", listener.Addr().String())
+		for i := 0; i < 50; i++ {
+			nw.Write([]byte("entry\n"))
+		}
+		Expect(nw.Close()).To(Succeed())
+		Eventually(c.lines).Should(HaveLen(50))
+
+		_, err := nw.Write([]byte("late\n"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("gives up sending buffered entries after the flush timeout", func() {
+		listener := listenTCP("127.0.0.1:0")
+		address := listener.Addr().String()
+		listener.Close()
+
+		nw := lg.NetworkOutput("tcp", address,
+			lg.NetworkFlushTimeout(50*time.Millisecond))
+		nw.Write([]byte("entry\n"))
+
+		start := time.Now()
+		Expect(nw.Close()).NotTo(Succeed())
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+	})
+
+	It("connects with TLS", func() {
+		listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+			Certificates: []tls.Certificate{selfSignedCert()},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		defer listener.Close()
+		c := newCollector(listener, false)
+
+		w = lg.NetworkOutput("tcp", listener.Addr().String(),
+			lg.NetworkTLS(&tls.Config{
+				ServerName:         "collector",
+				InsecureSkipVerify: true,
+			}))
+		lg.AddOutput(w, lg.JSON())
+		lg.Info("secret")
+
+		Eventually(c.lines).Should(Receive(ContainSubstring(`"m":"secret"`)))
+	})
+})
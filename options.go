@@ -3,6 +3,8 @@ package lg
 import (
 	"fmt"
 	"regexp"
+
+	"github.com/autopilothq/lg/encoding/types"
 )
 
 type OutputFormat uint
@@ -15,11 +17,43 @@ type PrefixLevel struct {
 type Options struct {
 	minLevels []PrefixLevel
 	format    OutputFormat
+	color     colorMode
+	pretty    bool
+	layout    *textLayout
+
+	timeFormat *types.TimeFormat
+	schema     *JSONSchema
+
+	ecsNamespace string
+	serviceName  string
+	hostname     string
+
+	syslogProtocol      syslogProtocol
+	syslogFacility      SyslogFacility
+	syslogPrefixAppName bool
+	syslogSDID          string
+
+	lokiLabels       []string
+	lokiStaticLabels []F
+
+	otlpTraceKey string
+	otlpSpanKey  string
+
+	chain *hashChain
 }
 
 const (
 	FormatPlainText OutputFormat = iota
 	FormatJSON
+	FormatLogfmt
+	FormatConsole
+	FormatECS
+	FormatGELF
+	FormatSyslog
+	FormatJournald
+	FormatLoki
+	FormatOTLP
+	FormatCBOR
 )
 
 var (
@@ -30,6 +64,8 @@ func makeOptions(opts ...func(*Options)) *Options {
 	result := &Options{
 		format:    FormatPlainText,
 		minLevels: nil,
+
+		syslogFacility: FacilityUser,
 	}
 	for _, opt := range opts {
 		opt(result)
@@ -49,6 +85,256 @@ func JSON() func(*Options) {
 	}
 }
 
+// Logfmt outputs entries as space separated key=value pairs, with the keys
+// ts, level, prefix and msg, followed by the fields and then the err field.
+// As logfmt can't nest values, the keys of nested objects are joined with a
+// '.', e.g. req.path=/, and arrays are rendered as a single quoted value,
+// e.g. ids="[1 2]".
+func Logfmt() func(*Options) {
+	return func(o *Options) {
+		o.format = FormatLogfmt
+	}
+}
+
+// Console outputs entries in a human friendly format for developer consoles.
+// Levels, timestamps, prefixes and field keys are colored when the output is
+// a terminal, unless the NO_COLOR environment variable is set. Use Color to
+// override this.
+func Console() func(*Options) {
+	return func(o *Options) {
+		o.format = FormatConsole
+	}
+}
+
+// ECS outputs entries in JSON format, laid out according to the Elastic
+// Common Schema. The prefix is written as log.logger, the err field as
+// error.message (and error.stack_trace if the error has one), and all other
+// fields are nested within the "labels" object, or the key set with
+// ECSNamespace. As ECS requires labels to be flat strings, values within
+// "labels" are written as strings, e.g. 1 as "1" and ["a"] as "[\"a\"]";
+// set a namespace of your own to keep their types and structure.
+//
+// Timestamps are written in RFC3339 format with milliseconds, unless set with
+// TimeLayout.
+func ECS() func(*Options) {
+	return func(o *Options) {
+		o.format = FormatECS
+	}
+}
+
+// ECSNamespace sets the key that the ECS format nests fields within
+func ECSNamespace(namespace string) func(*Options) {
+	return func(o *Options) {
+		o.ecsNamespace = namespace
+	}
+}
+
+// ServiceName sets the name of the service that is written with each entry
+// by formats that support it, e.g. service.name in the ECS format. It
+// defaults to the name of the executable.
+func ServiceName(name string) func(*Options) {
+	return func(o *Options) {
+		o.serviceName = name
+	}
+}
+
+// GELF outputs entries as Graylog Extended Log Format messages. Levels are
+// mapped onto syslog severities, and fields are written as additional fields,
+// prefixed with an underscore. Use a GELFWriter to send them to Graylog.
+func GELF() func(*Options) {
+	return func(o *Options) {
+		o.format = FormatGELF
+	}
+}
+
+// Hostname sets the name of the host that is written with each entry by
+// formats that support it, e.g. host in the GELF format. It defaults to the
+// hostname reported by the kernel.
+func Hostname(name string) func(*Options) {
+	return func(o *Options) {
+		o.hostname = name
+	}
+}
+
+// Syslog outputs entries as RFC 5424 syslog messages. Levels are mapped onto
+// syslog severities, the prefix is written as the MSGID (or the APP-NAME, with
+// SyslogPrefixAsAppName) and fields are written as structured data. Use a
+// SyslogWriter to deliver them to a syslog daemon.
+func Syslog() func(*Options) {
+	return func(o *Options) {
+		o.format = FormatSyslog
+		o.syslogProtocol = syslogRFC5424
+	}
+}
+
+// SyslogRFC3164 outputs entries as traditional BSD syslog messages, for
+// daemons that don't support RFC 5424
+func SyslogRFC3164() func(*Options) {
+	return func(o *Options) {
+		o.format = FormatSyslog
+		o.syslogProtocol = syslogRFC3164
+	}
+}
+
+// SyslogFacilityOf sets the facility that syslog messages are logged with.
+// It defaults to FacilityUser.
+func SyslogFacilityOf(facility SyslogFacility) func(*Options) {
+	return func(o *Options) {
+		o.syslogFacility = facility
+	}
+}
+
+// SyslogPrefixAsAppName writes the prefix of entries as the APP-NAME of
+// syslog messages, rather than the MSGID
+func SyslogPrefixAsAppName() func(*Options) {
+	return func(o *Options) {
+		o.syslogPrefixAppName = true
+	}
+}
+
+// SyslogSDID sets the SD-ID that RFC 5424 messages write fields under, e.g.
+// "app@12345" with your organisation's private enterprise number. It defaults
+// to SyslogStructuredDataID.
+func SyslogSDID(id string) func(*Options) {
+	return func(o *Options) {
+		o.syslogSDID = id
+	}
+}
+
+// Journald outputs entries in the native systemd-journald protocol, for use
+// with a JournaldWriter. The message, level, prefix and caller are written as
+// MESSAGE, PRIORITY, LG_PREFIX and CODE_FILE, CODE_LINE and CODE_FUNC, and
+// fields are written as journal fields with upper case names, e.g. the field
+// "user id" is written as USER_ID.
+func Journald() func(*Options) {
+	return func(o *Options) {
+		o.format = FormatJournald
+	}
+}
+
+// Loki outputs entries as Grafana Loki streams, for use with a LokiWriter.
+// The level and prefix of each entry are written as the labels of its
+// stream, or the labels given with LokiLabels, and everything else is written
+// as a logfmt line, e.g. msg="request done" status=500 err="it broke".
+func Loki() func(*Options) {
+	return func(o *Options) {
+		o.format = FormatLoki
+	}
+}
+
+// LokiLabels sets the labels of the streams that the Loki format writes
+// entries to: "level" and "prefix" for the level and prefix of an entry, and
+// any other key for the value of the field with that key. Entries without the
+// field don't get the label. Labels should only be used for values with a
+// small number of possibilities.
+func LokiLabels(keys ...string) func(*Options) {
+	return func(o *Options) {
+		o.format = FormatLoki
+		o.lokiLabels = keys
+	}
+}
+
+// LokiStaticLabel adds a label with a fixed value to every stream that the
+// Loki format writes entries to, e.g. the name of the service
+func LokiStaticLabel(name, value string) func(*Options) {
+	return func(o *Options) {
+		o.format = FormatLoki
+		o.lokiStaticLabels = append(o.lokiStaticLabels, F{name, value})
+	}
+}
+
+// OTLP outputs entries as OpenTelemetry log records in OTLP/JSON, for use
+// with an OTLPWriter. The level is mapped onto the severity number and text,
+// the message is written as the body, and the prefix, caller and fields are
+// written as attributes, with the err field as exception.message (and
+// exception.stacktrace if the error has one). Slice and map values are
+// written as arrays and key-value lists. The trace_id and span_id
+// fields, if they're hex encoded ids, are written as the trace and span ids.
+func OTLP() func(*Options) {
+	return func(o *Options) {
+		o.format = FormatOTLP
+	}
+}
+
+// OTLPTraceKeys sets the keys of the fields that the OTLP format takes trace
+// and span ids from
+func OTLPTraceKeys(traceKey, spanKey string) func(*Options) {
+	return func(o *Options) {
+		o.format = FormatOTLP
+		o.otlpTraceKey = traceKey
+		o.otlpSpanKey = spanKey
+	}
+}
+
+// CBOR outputs entries in a compact binary format, as CBOR maps of the
+// timestamp, level, prefix, message, fields and caller, written one after
+// another without delimiters. Use the binlog package to read them back.
+func CBOR() func(*Options) {
+	return func(o *Options) {
+		o.format = FormatCBOR
+	}
+}
+
+// Color forces the colors used by the Console format on or off, regardless
+// of whether the output is a terminal
+func Color(enabled bool) func(*Options) {
+	return func(o *Options) {
+		if enabled {
+			o.color = colorAlways
+		} else {
+			o.color = colorNever
+		}
+	}
+}
+
+// PrettyFields causes the Console format to render multi-line string field
+// values, and object and array field values, indented on the lines following
+// the entry
+func PrettyFields() func(*Options) {
+	return func(o *Options) {
+		o.pretty = true
+	}
+}
+
+// Layout outputs entries in plain text, rendered according to the given
+// layout. Verbs in the layout are enclosed in braces, and are replaced with
+// the corresponding part of each entry:
+//
+//   {time}          the timestamp, optionally with a layout: {time:15:04:05}
+//   {level}         the level, e.g. info ({LEVEL} for upper case)
+//   {prefix}        the prefix
+//   {message}       the message
+//   {err}           the value of the err field
+//   {field:key}     the value of the field with the given key
+//   {fields}        all fields that aren't rendered by {field} or {err}
+//   {caller}        the file and line that the entry was logged from, with
+//                   the forms {caller:short}, {caller:long} and {caller:func}
+//   {elapsed}       the time since the process started, optionally rounded
+//                   to the given duration: {elapsed:1s}
+//
+// The output of any verb can be padded and/or truncated by following it with
+// |[>][width][.max], e.g. {prefix|10.10}. Padding is added on the right
+// unless > is given. Verbs which don't take an argument also accept this in
+// its place, e.g. {level:5}. Literal braces are written as {{ and }}.
+//
+// The layout is compiled once, and Layout panics if it's invalid.
+//
+// Example:
+//
+//   lg.SetOutput(os.Stdout,
+//     lg.Layout("{time:15:04:05} {level:5} {prefix} {message} {fields}"))
+func Layout(layout string) func(*Options) {
+	l, err := compileLayout(layout)
+	if err != nil {
+		panic(err)
+	}
+
+	return func(o *Options) {
+		o.format = FormatPlainText
+		o.layout = l
+	}
+}
+
 func MinLevel(l Level) func(*Options) {
 	return func(o *Options) {
 		if o.minLevels == nil {
@@ -0,0 +1,377 @@
+package lg
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/autopilothq/lg/encoding"
+	fancy "github.com/autopilothq/lg/encoding/json"
+)
+
+const (
+	// DefaultOTLPTraceKey and DefaultOTLPSpanKey are the keys of the fields
+	// that the OTLP format takes trace and span ids from, unless set with
+	// OTLPTraceKeys
+	DefaultOTLPTraceKey = "trace_id"
+	DefaultOTLPSpanKey  = "span_id"
+)
+
+var (
+	otlpTraceID = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+	otlpSpanID  = regexp.MustCompile(`^[0-9a-fA-F]{16}$`)
+)
+
+// otlpSeverity returns the OpenTelemetry severity number of a level
+func otlpSeverity(level Level) int {
+	switch level {
+	case LevelTrace:
+		return 1
+	case LevelDebug:
+		return 5
+	case LevelInfo:
+		return 9
+	case LevelWarn:
+		return 13
+	case LevelError:
+		return 17
+	default:
+		return 21
+	}
+}
+
+// otlpFormatter renders entries as OTLP/JSON log records
+type otlpFormatter struct {
+	traceKey string
+	spanKey  string
+}
+
+func newOTLPFormatter(options *Options) *otlpFormatter {
+	f := &otlpFormatter{
+		traceKey: options.otlpTraceKey,
+		spanKey:  options.otlpSpanKey,
+	}
+
+	if f.traceKey == "" {
+		f.traceKey = DefaultOTLPTraceKey
+	}
+
+	if f.spanKey == "" {
+		f.spanKey = DefaultOTLPSpanKey
+	}
+
+	return f
+}
+
+func (f *otlpFormatter) format(e *Entry) []byte {
+	enc := fancy.NewEncoder()
+
+	if err := f.encode(enc, e); err != nil {
+		return makeJSONError(enc, err)
+	}
+
+	return append(enc.Bytes(), '\n')
+}
+
+func (f *otlpFormatter) encode(enc *fancy.Encoder, e *Entry) (err error) {
+	if err = enc.StartObject(); err != nil {
+		return err
+	}
+
+	ts := strconv.FormatInt(e.Timestamp.UnixNano(), 10)
+	if err = encoding.EncodeStringKeyValue(enc, "timeUnixNano", ts); err != nil {
+		return err
+	}
+
+	if err = encoding.EncodeStringKeyValue(enc, "observedTimeUnixNano", ts); err != nil {
+		return err
+	}
+
+	err = encoding.EncodeKeyValue(enc, "severityNumber", otlpSeverity(e.Level))
+	if err != nil {
+		return err
+	}
+
+	err = encoding.EncodeStringKeyValue(
+		enc, "severityText", strings.ToUpper(e.Level.String()))
+	if err != nil {
+		return err
+	}
+
+	if err = enc.AddKey("body"); err != nil {
+		return err
+	}
+
+	if err = encodeOTLPValue(enc, e.Message); err != nil {
+		return err
+	}
+
+	var traceID, spanID string
+	for _, fld := range e.Fields.contents {
+		if s, ok := fld.Val.(string); ok {
+			if fld.Key == f.traceKey && otlpTraceID.MatchString(s) {
+				traceID = strings.ToLower(s)
+			} else if fld.Key == f.spanKey && otlpSpanID.MatchString(s) {
+				spanID = strings.ToLower(s)
+			}
+		}
+	}
+
+	if err = f.encodeAttributes(enc, e, traceID != "", spanID != ""); err != nil {
+		return err
+	}
+
+	if traceID != "" {
+		if err = encoding.EncodeStringKeyValue(enc, "traceId", traceID); err != nil {
+			return err
+		}
+	}
+
+	if spanID != "" {
+		if err = encoding.EncodeStringKeyValue(enc, "spanId", spanID); err != nil {
+			return err
+		}
+	}
+
+	return enc.EndObject()
+}
+
+// encodeAttributes writes the prefix, caller and fields as attributes. The
+// err field is mapped onto exception.message and, if the error has one,
+// exception.stacktrace.
+func (f *otlpFormatter) encodeAttributes(
+	enc *fancy.Encoder, e *Entry, hasTrace, hasSpan bool,
+) (err error) {
+	if err = enc.AddKey("attributes"); err != nil {
+		return err
+	}
+
+	if err = enc.StartArray(); err != nil {
+		return err
+	}
+
+	if e.Prefix != "" {
+		if err = encodeOTLPAttribute(enc, "lg.prefix", e.Prefix); err != nil {
+			return err
+		}
+	}
+
+	if e.Caller != nil {
+		if err = encodeOTLPAttribute(enc, "code.filepath", e.Caller.File); err != nil {
+			return err
+		}
+
+		if err = encodeOTLPAttribute(enc, "code.lineno", e.Caller.Line); err != nil {
+			return err
+		}
+
+		if err = encodeOTLPAttribute(enc, "code.function", e.Caller.Function); err != nil {
+			return err
+		}
+	}
+
+	for _, fld := range e.Fields.contents {
+		switch {
+		case fld.Key == f.traceKey && hasTrace, fld.Key == f.spanKey && hasSpan:
+			continue
+
+		case fld.Key == ErrKey:
+			err = encodeOTLPAttribute(enc, "exception.message", RenderMessage(fld.Val))
+			if err != nil {
+				return err
+			}
+
+			if stack := errorStack(fld.Val); stack != "" {
+				if err = encodeOTLPAttribute(enc, "exception.stacktrace", stack); err != nil {
+					return err
+				}
+			}
+
+		default:
+			if err = encodeOTLPAttribute(enc, fld.Key, fld.Val); err != nil {
+				return err
+			}
+		}
+	}
+
+	return enc.EndArray()
+}
+
+func encodeOTLPAttribute(enc *fancy.Encoder, key string, val interface{}) (err error) {
+	if err = enc.StartObject(); err != nil {
+		return err
+	}
+
+	if err = encoding.EncodeStringKeyValue(enc, "key", key); err != nil {
+		return err
+	}
+
+	if err = enc.AddKey("value"); err != nil {
+		return err
+	}
+
+	if err = encodeOTLPValue(enc, val); err != nil {
+		return err
+	}
+
+	return enc.EndObject()
+}
+
+// encodeOTLPValue writes an AnyValue. Strings, booleans and numbers are
+// written as such, and any other values are rendered as JSON, and then
+// written as the AnyValue of that, e.g. slices as arrayValues and maps as
+// kvlistValues.
+func encodeOTLPValue(enc *fancy.Encoder, val interface{}) (err error) {
+	switch val.(type) {
+	case string, error, bool, float32, float64,
+		int, int8, int16, int32, int64, uint, uint8, uint16, uint32:
+	default:
+		valEnc := fancy.NewEncoder()
+		if err = encoding.EncodeValue(valEnc, val); err != nil {
+			return err
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(valEnc.Bytes()))
+		dec.UseNumber()
+		return encodeOTLPJSONValue(enc, dec)
+	}
+
+	if err = enc.StartObject(); err != nil {
+		return err
+	}
+
+	switch v := val.(type) {
+	case string:
+		err = encoding.EncodeStringKeyValue(enc, "stringValue", v)
+
+	case error:
+		err = encoding.EncodeStringKeyValue(enc, "stringValue", v.Error())
+
+	case bool:
+		err = encoding.EncodeKeyValue(enc, "boolValue", v)
+
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32:
+		// 64 bit integers are written as strings in OTLP/JSON
+		err = encoding.EncodeStringKeyValue(enc, "intValue", RenderMessage(v))
+
+	case float32, float64:
+		err = encoding.EncodeKeyValue(enc, "doubleValue", v)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return enc.EndObject()
+}
+
+// encodeOTLPJSONValue writes the next value read from dec as an AnyValue.
+// Arrays are written as arrayValues, and objects as kvlistValues, keeping the
+// order of their keys. Nulls are written as empty AnyValues.
+func encodeOTLPJSONValue(enc *fancy.Encoder, dec *json.Decoder) (err error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if err = enc.StartObject(); err != nil {
+		return err
+	}
+
+	switch v := tok.(type) {
+	case string:
+		err = encoding.EncodeStringKeyValue(enc, "stringValue", v)
+
+	case bool:
+		err = encoding.EncodeKeyValue(enc, "boolValue", v)
+
+	case json.Number:
+		if _, ierr := v.Int64(); ierr == nil {
+			err = encoding.EncodeStringKeyValue(enc, "intValue", v.String())
+		} else {
+			f, _ := v.Float64()
+			err = encoding.EncodeKeyValue(enc, "doubleValue", f)
+		}
+
+	case json.Delim:
+		err = encodeOTLPJSONList(enc, dec, v)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return enc.EndObject()
+}
+
+// encodeOTLPJSONList writes the rest of the array or object opened by delim
+// as the values of an arrayValue or kvlistValue
+func encodeOTLPJSONList(enc *fancy.Encoder, dec *json.Decoder, delim json.Delim) (err error) {
+	kind := "arrayValue"
+	if delim == '{' {
+		kind = "kvlistValue"
+	}
+
+	if err = enc.AddKey(kind); err != nil {
+		return err
+	}
+
+	if err = enc.StartObject(); err != nil {
+		return err
+	}
+
+	if err = enc.AddKey("values"); err != nil {
+		return err
+	}
+
+	if err = enc.StartArray(); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		if delim == '[' {
+			if err = encodeOTLPJSONValue(enc, dec); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var key string
+		if key, err = readJSONKey(dec); err != nil {
+			return err
+		}
+
+		if err = enc.StartObject(); err != nil {
+			return err
+		}
+
+		if err = encoding.EncodeStringKeyValue(enc, "key", key); err != nil {
+			return err
+		}
+
+		if err = enc.AddKey("value"); err != nil {
+			return err
+		}
+
+		if err = encodeOTLPJSONValue(enc, dec); err != nil {
+			return err
+		}
+
+		if err = enc.EndObject(); err != nil {
+			return err
+		}
+	}
+
+	// the closing delimiter
+	if _, err = dec.Token(); err != nil {
+		return err
+	}
+
+	if err = enc.EndArray(); err != nil {
+		return err
+	}
+
+	return enc.EndObject()
+}
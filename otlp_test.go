@@ -0,0 +1,273 @@
+package lg_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"os"
+	"time"
+
+	"github.com/autopilothq/lg"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// protoMessage is a decoded protocol buffer message, with the values of each
+// field number in order. Varints and fixed64s are decoded as uint64s, and
+// length delimited fields as []byte.
+type protoMessage map[int][]interface{}
+
+func decodeProto(b []byte) protoMessage {
+	msg := protoMessage{}
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		Expect(n).To(BeNumerically(">", 0))
+		b = b[n:]
+
+		field := int(key >> 3)
+		switch key & 7 {
+		case 0:
+			val, n := binary.Uvarint(b)
+			Expect(n).To(BeNumerically(">", 0))
+			msg[field] = append(msg[field], val)
+			b = b[n:]
+		case 1:
+			msg[field] = append(msg[field], binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		case 2:
+			size, n := binary.Uvarint(b)
+			Expect(n).To(BeNumerically(">", 0))
+			msg[field] = append(msg[field], b[n:n+int(size)])
+			b = b[n+int(size):]
+		default:
+			Fail("unexpected wire type")
+		}
+	}
+	return msg
+}
+
+func (m protoMessage) message(field int) protoMessage {
+	return decodeProto(m[field][0].([]byte))
+}
+
+func (m protoMessage) str(field int) string {
+	return string(m[field][0].([]byte))
+}
+
+var _ = Describe("OTLP output", func() {
+
+	var tlo *TestLogOutput
+
+	BeforeEach(func() {
+		tlo = &TestLogOutput{}
+		lg.RemoveOutput(os.Stdout)
+	})
+
+	AfterEach(func() {
+		lg.RemoveOutput(tlo)
+		lg.AddOutput(os.Stdout)
+	})
+
+	decode := func(b []byte) map[string]interface{} {
+		var result map[string]interface{}
+		Expect(json.Unmarshal(b, &result)).To(Succeed())
+		return result
+	}
+
+	attributes := func(record map[string]interface{}) map[string]interface{} {
+		result := make(map[string]interface{})
+		for _, attr := range record["attributes"].([]interface{}) {
+			kv := attr.(map[string]interface{})
+			result[kv["key"].(string)] = kv["value"]
+		}
+		return result
+	}
+
+	It("renders entries as log records", func() {
+		lg.AddOutput(tlo, lg.OTLP())
+		lg.ExtendWithPrefix("Server").Warn("careful",
+			lg.F{"user", "bob"},
+			lg.F{"id", 42},
+			lg.F{"ratio", 0.5},
+			lg.F{"ok", false},
+			lg.F{"tags", []string{"a", "b"}},
+			lg.F{"when", time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)},
+			lg.F{"req", map[string]interface{}{"path": "/", "status": 200}},
+			lg.Err(tracedError{"it broke"}),
+		)
+
+		Expect(tlo.String()).To(MatchRegexp(
+			`^\{"timeUnixNano":"[0-9]{19}","observedTimeUnixNano":"[0-9]{19}",` +
+				`"severityNumber":13,"severityText":"WARN","body":\{"stringValue":"careful"\},`))
+
+		record := decode(tlo.Bytes())
+		Expect(attributes(record)).To(Equal(map[string]interface{}{
+			"lg.prefix": map[string]interface{}{"stringValue": "Server"},
+			"user":      map[string]interface{}{"stringValue": "bob"},
+			"id":        map[string]interface{}{"intValue": "42"},
+			"ratio":     map[string]interface{}{"doubleValue": 0.5},
+			"ok":        map[string]interface{}{"boolValue": false},
+			"tags": map[string]interface{}{"arrayValue": map[string]interface{}{
+				"values": []interface{}{
+					map[string]interface{}{"stringValue": "a"},
+					map[string]interface{}{"stringValue": "b"},
+				},
+			}},
+			"when": map[string]interface{}{"stringValue": "2017-01-02T03:04:05.000"},
+			"req": map[string]interface{}{"kvlistValue": map[string]interface{}{
+				"values": []interface{}{
+					map[string]interface{}{"key": "path",
+						"value": map[string]interface{}{"stringValue": "/"}},
+					map[string]interface{}{"key": "status",
+						"value": map[string]interface{}{"intValue": "200"}},
+				},
+			}},
+			"exception.message":    map[string]interface{}{"stringValue": "it broke"},
+			"exception.stacktrace": map[string]interface{}{"stringValue": "it broke\nmain.main\n\tmain.go:12"},
+		}))
+		Expect(record).NotTo(HaveKey("traceId"))
+	})
+
+	It("writes trace and span ids", func() {
+		lg.AddOutput(tlo, lg.OTLP())
+		lg.Info("traced",
+			lg.F{"trace_id", "5B8EFFF798038103D269B633813FC60C"},
+			lg.F{"span_id", "eee19b7ec3c1b174"})
+
+		record := decode(tlo.Bytes())
+		Expect(record).To(HaveKeyWithValue("traceId", "5b8efff798038103d269b633813fc60c"))
+		Expect(record).To(HaveKeyWithValue("spanId", "eee19b7ec3c1b174"))
+		Expect(attributes(record)).To(BeEmpty())
+	})
+
+	It("leaves ids that aren't valid as attributes", func() {
+		lg.AddOutput(tlo, lg.OTLPTraceKeys("traceid", "spanid"))
+		lg.Info("traced", lg.F{"traceid", "1234abcd"})
+
+		record := decode(tlo.Bytes())
+		Expect(record).NotTo(HaveKey("traceId"))
+		Expect(attributes(record)).To(HaveKey("traceid"))
+	})
+
+	Describe("OTLPWriter", func() {
+
+		var server *ingestServer
+
+		BeforeEach(func() {
+			server = newIngestServer()
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("exports log records as JSON", func() {
+			w := lg.OTLPOutput(server.URL, lg.OTLPResource("service.name", "api"),
+				lg.OTLPResource("deployment.environment", "test"))
+			lg.AddOutput(w, lg.OTLP())
+			lg.Info("one")
+			lg.Error("two")
+			lg.RemoveOutput(w)
+			Expect(w.Close()).To(Succeed())
+
+			req := server.Requests()[0]
+			Expect(req.header.Get("Content-Type")).To(Equal("application/json"))
+
+			var export struct {
+				ResourceLogs []struct {
+					Resource struct {
+						Attributes []map[string]interface{} `json:"attributes"`
+					} `json:"resource"`
+					ScopeLogs []struct {
+						Scope      map[string]string        `json:"scope"`
+						LogRecords []map[string]interface{} `json:"logRecords"`
+					} `json:"scopeLogs"`
+				} `json:"resourceLogs"`
+			}
+			Expect(json.Unmarshal([]byte(req.body), &export)).To(Succeed())
+
+			resourceLogs := export.ResourceLogs[0]
+			Expect(resourceLogs.Resource.Attributes).To(Equal([]map[string]interface{}{
+				{"key": "service.name", "value": map[string]interface{}{"stringValue": "api"}},
+				{"key": "deployment.environment", "value": map[string]interface{}{"stringValue": "test"}},
+			}))
+			Expect(resourceLogs.ScopeLogs[0].Scope).To(Equal(
+				map[string]string{"name": "github.com/autopilothq/lg"}))
+
+			records := resourceLogs.ScopeLogs[0].LogRecords
+			Expect(records).To(HaveLen(2))
+			Expect(records[0]["body"]).To(Equal(map[string]interface{}{"stringValue": "one"}))
+			Expect(records[1]["severityNumber"]).To(Equal(float64(17)))
+		})
+
+		It("exports log records as protocol buffers", func() {
+			w := lg.OTLPOutput(server.URL, lg.OTLPProtobuf())
+			w.Write([]byte(`{"timeUnixNano":"1505434603848000000",` +
+				`"observedTimeUnixNano":"1505434603848000000",` +
+				`"severityNumber":9,"severityText":"INFO",` +
+				`"body":{"stringValue":"hello"},"attributes":[` +
+				`{"key":"id","value":{"intValue":"-1"}},` +
+				`{"key":"ratio","value":{"doubleValue":0.5}},` +
+				`{"key":"ok","value":{"boolValue":false}},` +
+				`{"key":"tags","value":{"arrayValue":{"values":[{"stringValue":"a"}]}}},` +
+				`{"key":"req","value":{"kvlistValue":{"values":[` +
+				`{"key":"path","value":{"stringValue":"/"}}]}}}],` +
+				`"traceId":"5b8efff798038103d269b633813fc60c","spanId":"eee19b7ec3c1b174"}`))
+			Expect(w.Close()).To(Succeed())
+
+			req := server.Requests()[0]
+			Expect(req.header.Get("Content-Type")).To(Equal("application/x-protobuf"))
+
+			resourceLogs := decodeProto([]byte(req.body)).message(1)
+
+			serviceName := resourceLogs.message(1).message(1)
+			Expect(serviceName.str(1)).To(Equal("service.name"))
+			Expect(serviceName.message(2).str(1)).NotTo(BeEmpty())
+
+			scopeLogs := resourceLogs.message(2)
+			Expect(scopeLogs.message(1).str(1)).To(Equal("github.com/autopilothq/lg"))
+
+			record := scopeLogs.message(2)
+			Expect(record[1]).To(Equal([]interface{}{uint64(1505434603848000000)}))
+			Expect(record[11]).To(Equal([]interface{}{uint64(1505434603848000000)}))
+			Expect(record[2]).To(Equal([]interface{}{uint64(9)}))
+			Expect(record.str(3)).To(Equal("INFO"))
+			Expect(record.message(5).str(1)).To(Equal("hello"))
+			Expect(record[9]).To(Equal([]interface{}{[]byte{
+				0x5b, 0x8e, 0xff, 0xf7, 0x98, 0x03, 0x81, 0x03,
+				0xd2, 0x69, 0xb6, 0x33, 0x81, 0x3f, 0xc6, 0x0c}}))
+			Expect(record[10]).To(Equal([]interface{}{[]byte{
+				0xee, 0xe1, 0x9b, 0x7e, 0xc3, 0xc1, 0xb1, 0x74}}))
+
+			Expect(record[6]).To(HaveLen(5))
+			id := decodeProto(record[6][0].([]byte))
+			Expect(id.str(1)).To(Equal("id"))
+			Expect(id.message(2)[3]).To(Equal([]interface{}{uint64(math.MaxUint64)}))
+
+			ratio := decodeProto(record[6][1].([]byte))
+			Expect(ratio.message(2)[4]).To(Equal([]interface{}{math.Float64bits(0.5)}))
+
+			ok := decodeProto(record[6][2].([]byte))
+			Expect(ok.message(2)[2]).To(Equal([]interface{}{uint64(0)}))
+
+			// ArrayValue{values: [{string_value: "a"}]}
+			tags := decodeProto(record[6][3].([]byte))
+			Expect(tags.message(2).message(5).message(1).str(1)).To(Equal("a"))
+
+			// KeyValueList{values: [{key: "path", value: {string_value: "/"}}]}
+			reqAttr := decodeProto(record[6][4].([]byte))
+			path := reqAttr.message(2).message(6).message(1)
+			Expect(path.str(1)).To(Equal("path"))
+			Expect(path.message(2).str(1)).To(Equal("/"))
+		})
+
+		It("only accepts entries in the OTLP format", func() {
+			w := lg.OTLPOutput(server.URL)
+			defer w.Close()
+
+			_, err := w.Write([]byte(`{"m":"hello"}`))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
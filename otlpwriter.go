@@ -0,0 +1,332 @@
+package lg
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+
+	fancy "github.com/autopilothq/lg/encoding/json"
+)
+
+// otlpScopeName is the name of the instrumentation scope that log records
+// are exported with
+const otlpScopeName = "github.com/autopilothq/lg"
+
+var errNotOTLPFormat = errors.New(
+	"lg: entries written to an OTLPWriter must be in the OTLP format")
+
+// OTLPOptions configures an OTLPWriter
+type OTLPOptions struct {
+	HTTPOptions
+	protobuf bool
+	resource []F
+}
+
+// OTLPHTTP applies options for HTTP writers, e.g. HTTPHeader, to an
+// OTLPWriter
+func OTLPHTTP(opts ...func(*HTTPOptions)) func(*OTLPOptions) {
+	return func(o *OTLPOptions) {
+		for _, opt := range opts {
+			opt(&o.HTTPOptions)
+		}
+	}
+}
+
+// OTLPProtobuf exports log records as protocol buffers, rather than as JSON
+func OTLPProtobuf() func(*OTLPOptions) {
+	return func(o *OTLPOptions) {
+		o.protobuf = true
+	}
+}
+
+// OTLPResource adds an attribute to the resource that log records are
+// exported with, e.g. deployment.environment. The service.name attribute
+// defaults to the name of the executable.
+func OTLPResource(key, value string) func(*OTLPOptions) {
+	return func(o *OTLPOptions) {
+		o.resource = append(o.resource, F{key, value})
+	}
+}
+
+// otlpAnyValue is an AnyValue, as written by the OTLP format
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue"`
+	BoolValue   *bool    `json:"boolValue"`
+	IntValue    *string  `json:"intValue"`
+	DoubleValue *float64 `json:"doubleValue"`
+	ArrayValue  *struct {
+		Values []otlpAnyValue `json:"values"`
+	} `json:"arrayValue"`
+	KvlistValue *struct {
+		Values []otlpKeyValue `json:"values"`
+	} `json:"kvlistValue"`
+}
+
+// otlpKeyValue is a KeyValue, i.e. an attribute, or an entry in a kvlistValue
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+// otlpLogRecord is a LogRecord, as written by the OTLP format
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes"`
+	TraceID              string         `json:"traceId"`
+	SpanID               string         `json:"spanId"`
+}
+
+// OTLPWriter exports entries in the OTLP format to an OpenTelemetry
+// collector, or any other OTLP/HTTP endpoint, in batches sent as JSON or,
+// with OTLPProtobuf, as protocol buffers. Batching, retries and headers are
+// configured as for an HTTPWriter, with OTLPHTTP.
+//
+// Example:
+//
+//   w := lg.OTLPOutput("http://collector:4318/v1/logs", lg.OTLPProtobuf(),
+//     lg.OTLPResource("service.name", "api"))
+//   defer w.Close()
+//   lg.AddOutput(w, lg.OTLP())
+type OTLPWriter struct {
+	url     string
+	options OTLPOptions
+	batcher *batcher
+
+	// resource is the resource that log records are exported with, as JSON
+	// and as a protocol buffer
+	resourceJSON  []byte
+	resourceProto []byte
+}
+
+// OTLPOutput returns a writer which exports entries to url, i.e. the
+// /v1/logs endpoint of an OpenTelemetry collector
+func OTLPOutput(url string, opts ...func(*OTLPOptions)) *OTLPWriter {
+	w := &OTLPWriter{
+		url:     url,
+		options: OTLPOptions{HTTPOptions: makeHTTPOptions(nil)},
+	}
+
+	for _, opt := range opts {
+		opt(&w.options)
+	}
+
+	resource := w.options.resource
+	hasService := false
+	for _, attr := range resource {
+		hasService = hasService || attr.Key == "service.name"
+	}
+	if !hasService {
+		resource = append([]F{{"service.name", filepath.Base(os.Args[0])}},
+			resource...)
+	}
+
+	w.resourceJSON = encodeOTLPResourceJSON(resource)
+	w.resourceProto = encodeOTLPResourceProto(resource)
+
+	w.batcher = newBatcher(redactURL(url), w.options.batch, w.send)
+
+	return w
+}
+
+// Write queues p, which must be an entry in the OTLP format, to be exported.
+// It fails with ErrQueueFull if the queue is full.
+func (w *OTLPWriter) Write(p []byte) (int, error) {
+	if !bytes.HasPrefix(p, []byte(`{"timeUnixNano":`)) {
+		return 0, errNotOTLPFormat
+	}
+
+	if err := w.batcher.add(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of entries that have been dropped, because the
+// queue was full or they couldn't be exported
+func (w *OTLPWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.batcher.dropped)
+}
+
+// Close exports any queued entries, waiting at most the flush timeout
+func (w *OTLPWriter) Close() error {
+	return w.batcher.close()
+}
+
+func (w *OTLPWriter) send(ctx context.Context, batch [][]byte) error {
+	options := &w.options.HTTPOptions
+	if w.options.protobuf {
+		body, err := w.encodeProto(batch)
+		if err != nil {
+			return err
+		}
+		return postBatch(ctx, w.url, options, "application/x-protobuf", body)
+	}
+
+	return postBatch(
+		ctx, w.url, options, "application/json", w.encodeJSON(batch))
+}
+
+// encodeJSON returns an ExportLogsServiceRequest in OTLP/JSON. The log
+// records are already JSON, so are written as they are.
+func (w *OTLPWriter) encodeJSON(batch [][]byte) []byte {
+	var body bytes.Buffer
+	body.WriteString(`{"resourceLogs":[{"resource":`)
+	body.Write(w.resourceJSON)
+	body.WriteString(`,"scopeLogs":[{"scope":{"name":"` + otlpScopeName +
+		`"},"logRecords":[`)
+	for i, record := range batch {
+		if i > 0 {
+			body.WriteByte(',')
+		}
+		body.Write(record)
+	}
+	body.WriteString(`]}]}]}`)
+	return body.Bytes()
+}
+
+func encodeOTLPResourceJSON(attrs []F) []byte {
+	enc := fancy.NewEncoder()
+	enc.StartObject()
+	enc.AddKey("attributes")
+	enc.StartArray()
+	for _, attr := range attrs {
+		encodeOTLPAttribute(enc, attr.Key, attr.Val)
+	}
+	enc.EndArray()
+	enc.EndObject()
+	return enc.Bytes()
+}
+
+// encodeProto returns an ExportLogsServiceRequest as a protocol buffer
+func (w *OTLPWriter) encodeProto(batch [][]byte) ([]byte, error) {
+	scope := appendProtoString(nil, 1, otlpScopeName)
+
+	scopeLogs := appendProtoBytes(nil, 1, scope)
+	for _, entry := range batch {
+		var record otlpLogRecord
+		if err := json.Unmarshal(entry, &record); err != nil {
+			return nil, err
+		}
+
+		encoded, err := encodeOTLPRecordProto(&record)
+		if err != nil {
+			return nil, err
+		}
+		scopeLogs = appendProtoBytes(scopeLogs, 2, encoded)
+	}
+
+	resourceLogs := appendProtoBytes(nil, 1, w.resourceProto)
+	resourceLogs = appendProtoBytes(resourceLogs, 2, scopeLogs)
+
+	return appendProtoBytes(nil, 1, resourceLogs), nil
+}
+
+func encodeOTLPResourceProto(attrs []F) []byte {
+	var resource []byte
+	for _, attr := range attrs {
+		value := attr.Val.(string)
+		kv := otlpKeyValue{Key: attr.Key, Value: otlpAnyValue{StringValue: &value}}
+		resource = appendProtoBytes(resource, 1, encodeOTLPKeyValueProto(kv))
+	}
+	return resource
+}
+
+func encodeOTLPRecordProto(r *otlpLogRecord) ([]byte, error) {
+	ts, err := strconv.ParseUint(r.TimeUnixNano, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	observed, err := strconv.ParseUint(r.ObservedTimeUnixNano, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	b := appendProtoFixed64(nil, 1, ts)
+	b = appendProtoVarint(b, 2, uint64(r.SeverityNumber))
+	b = appendProtoString(b, 3, r.SeverityText)
+	b = appendProtoBytes(b, 5, encodeOTLPValueProto(r.Body))
+
+	for _, attr := range r.Attributes {
+		b = appendProtoBytes(b, 6, encodeOTLPKeyValueProto(attr))
+	}
+
+	if r.TraceID != "" {
+		id, err := hex.DecodeString(r.TraceID)
+		if err != nil {
+			return nil, err
+		}
+		b = appendProtoBytes(b, 9, id)
+	}
+
+	if r.SpanID != "" {
+		id, err := hex.DecodeString(r.SpanID)
+		if err != nil {
+			return nil, err
+		}
+		b = appendProtoBytes(b, 10, id)
+	}
+
+	return appendProtoFixed64(b, 11, observed), nil
+}
+
+// encodeOTLPKeyValueProto encodes a KeyValue
+func encodeOTLPKeyValueProto(kv otlpKeyValue) []byte {
+	b := appendProtoString(nil, 1, kv.Key)
+	return appendProtoBytes(b, 2, encodeOTLPValueProto(kv.Value))
+}
+
+// encodeOTLPValueProto encodes an AnyValue. Its value is a oneof, so is
+// written even if it's the zero value.
+func encodeOTLPValueProto(v otlpAnyValue) []byte {
+	var b []byte
+	switch {
+	case v.StringValue != nil:
+		b = appendProtoTag(b, 1, protoBytes)
+		b = appendUvarint(b, uint64(len(*v.StringValue)))
+		b = append(b, *v.StringValue...)
+
+	case v.BoolValue != nil:
+		b = appendProtoTag(b, 2, protoVarint)
+		if *v.BoolValue {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+
+	case v.IntValue != nil:
+		i, _ := strconv.ParseInt(*v.IntValue, 10, 64)
+		b = appendProtoTag(b, 3, protoVarint)
+		b = appendUvarint(b, uint64(i))
+
+	case v.DoubleValue != nil:
+		b = appendProtoTag(b, 4, protoFixed64)
+		b = appendFixed64(b, math.Float64bits(*v.DoubleValue))
+
+	case v.ArrayValue != nil:
+		var values []byte
+		for _, elem := range v.ArrayValue.Values {
+			values = appendProtoBytes(values, 1, encodeOTLPValueProto(elem))
+		}
+		b = appendProtoBytes(b, 5, values)
+
+	case v.KvlistValue != nil:
+		var values []byte
+		for _, kv := range v.KvlistValue.Values {
+			values = appendProtoBytes(values, 1, encodeOTLPKeyValueProto(kv))
+		}
+		b = appendProtoBytes(b, 6, values)
+	}
+	return b
+}
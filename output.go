@@ -28,14 +28,18 @@ var (
 	nextHookID uint32
 )
 
-func makePlainTexthookFn(output io.Writer, options *Options) hookFn {
+// makeFormattedHookFn returns a hook that writes entries, rendered by the
+// format function, to output
+func makeFormattedHookFn(
+	output io.Writer, options *Options, format func(*Entry) []byte,
+) hookFn {
 	return func(e *Entry) (err error) {
 		if shouldSkip(e, options) {
 			return nil
 		}
 
 		var n int
-		data := e.toPlainText()
+		data := format(e)
 		n, err = output.Write(data)
 		if err != nil {
 			return err
@@ -49,27 +53,6 @@ func makePlainTexthookFn(output io.Writer, options *Options) hookFn {
 	}
 }
 
-func makePlainJSONhookFn(output io.Writer, options *Options) hookFn {
-	return func(e *Entry) (err error) {
-		if shouldSkip(e, options) {
-			return nil
-		}
-
-		var n int
-		data := e.toJSON()
-		n, err = output.Write(data)
-		if err != nil {
-			return
-		}
-
-		if n != len(data) {
-			return io.ErrShortWrite
-		}
-
-		return nil
-	}
-}
-
 func shouldSkip(e *Entry, options *Options) bool {
 	if options.minLevels != nil {
 		for _, prefixLevel := range options.minLevels {
@@ -84,19 +67,86 @@ func shouldSkip(e *Entry, options *Options) bool {
 	return false
 }
 
-func makeOutputHookFn(output io.Writer, options *Options) hookFn {
+// makeFormatFn returns the function that renders entries for output in the
+// format given by options
+func makeFormatFn(output io.Writer, options *Options) func(*Entry) []byte {
 	switch options.format {
 	case FormatPlainText:
-		return makePlainTexthookFn(output, options)
+		if options.layout != nil {
+			return func(e *Entry) []byte {
+				return options.layout.format(e, options.timeFormat)
+			}
+		}
+		return func(e *Entry) []byte {
+			return e.toPlainText(options.timeFormat)
+		}
 
 	case FormatJSON:
-		return makePlainJSONhookFn(output, options)
+		return func(e *Entry) []byte {
+			return e.toJSON(options.timeFormat, options.schema)
+		}
+
+	case FormatLogfmt:
+		return func(e *Entry) []byte {
+			return e.toLogfmt(options.timeFormat)
+		}
+
+	case FormatConsole:
+		formatter := newConsoleFormatter(output, options)
+		return formatter.format
+
+	case FormatECS:
+		formatter := newECSFormatter(options)
+		return formatter.format
+
+	case FormatGELF:
+		formatter := newGELFFormatter(options)
+		return formatter.format
+
+	case FormatSyslog:
+		formatter := newSyslogFormatter(options)
+		return formatter.format
+
+	case FormatJournald:
+		formatter := newJournaldFormatter(options)
+		return formatter.format
+
+	case FormatLoki:
+		formatter := newLokiFormatter(options)
+		return formatter.format
+
+	case FormatOTLP:
+		formatter := newOTLPFormatter(options)
+		return formatter.format
+
+	case FormatCBOR:
+		return func(e *Entry) []byte {
+			return e.toCBOR()
+		}
 
 	default:
 		panic(fmt.Errorf("Invalid log output format %#v", options.format))
 	}
 }
 
+// Formatter returns a function which renders entries as they would be
+// written to output by an output with the given options, e.g. to render
+// entries read back with a Parser in another format. The output is only used
+// to decide whether the Console format is colored, and isn't written to.
+func Formatter(output io.Writer, opts ...func(*Options)) func(*Entry) []byte {
+	return makeFormatFn(output, makeOptions(opts...))
+}
+
+func makeOutputHookFn(output io.Writer, options *Options) hookFn {
+	format := makeFormatFn(output, options)
+
+	if options.chain != nil {
+		return options.chain.makeHookFn(output, options, format)
+	}
+
+	return makeFormattedHookFn(output, options, format)
+}
+
 // AddOutput causes logging to be written to the given io.Writer
 func AddOutput(output io.Writer, opts ...func(*Options)) {
 	options := makeOptions(opts...)
@@ -121,7 +171,7 @@ func SetOutput(output io.Writer, opts ...func(*Options)) {
 
 	hookID, exists := outputs[output]
 	if exists {
-		delete(hookFns, hookID)
+		removeHook(hookID)
 		delete(outputs, output)
 	}
 
@@ -138,7 +188,7 @@ func RemoveOutput(output io.Writer) {
 	hookID, exists := outputs[output]
 	if exists {
 		delete(outputs, output)
-		delete(hookFns, hookID)
+		removeHook(hookID)
 	}
 }
 
@@ -148,7 +198,7 @@ func RemoveAllOutputs() {
 	defer mutex.Unlock()
 
 	for _, p := range outputs {
-		delete(hookFns, p)
+		removeHook(p)
 	}
 
 	outputs = make(map[io.Writer]uint32)
@@ -166,6 +216,9 @@ func AddHook(fn hookFn, opts ...func(*Options)) uint32 {
 func addHook(fn hookFn, options *Options) uint32 {
 	hookID := atomic.AddUint32(&nextHookID, uint32(1))
 	hookFns[hookID] = hook{fn, options}
+	if options.needsCaller() {
+		atomic.AddInt32(&callerHooks, 1)
+	}
 	return hookID
 }
 
@@ -173,6 +226,13 @@ func addHook(fn hookFn, options *Options) uint32 {
 func RemoveHook(hookID uint32) {
 	mutex.Lock()
 	defer mutex.Unlock()
+	removeHook(hookID)
+}
+
+func removeHook(hookID uint32) {
+	if h, exists := hookFns[hookID]; exists && h.options.needsCaller() {
+		atomic.AddInt32(&callerHooks, -1)
+	}
 	delete(hookFns, hookID)
 }
 
@@ -2,9 +2,11 @@ package lg_test
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/autopilothq/lg"
 
@@ -61,3 +63,81 @@ var _ = Describe("log output", func() {
 		Expect(tlo.lastEntry()).To(Equal("5"))
 	})
 })
+
+var _ = Describe("logfmt output", func() {
+
+	var tlo *TestLogOutput
+
+	BeforeEach(func() {
+		tlo = &TestLogOutput{}
+		lg.RemoveOutput(os.Stdout)
+		lg.AddOutput(tlo, lg.Logfmt())
+	})
+
+	AfterEach(func() {
+		lg.RemoveOutput(tlo)
+		lg.AddOutput(os.Stdout)
+	})
+
+	It("writes entries as key=value pairs", func() {
+		log := lg.ExtendWithPrefix("Server")
+		log.Info("request done",
+			lg.Err(errors.New("it broke")),
+			lg.F{"status", 500},
+			lg.F{"req", map[string]interface{}{"path": "/a b"}},
+		)
+
+		Expect(tlo.String()).To(MatchRegexp(
+			`^ts=[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9:.]{12} level=info ` +
+				`prefix=Server msg="request done" status=500 req.path="/a b" ` +
+				`err="it broke"\n$`))
+	})
+})
+
+var _ = Describe("time formats", func() {
+
+	var tlo *TestLogOutput
+
+	AfterEach(func() {
+		lg.RemoveOutput(tlo)
+		lg.AddOutput(os.Stdout)
+	})
+
+	It("renders timestamps with a custom layout and location", func() {
+		tlo = &TestLogOutput{}
+		lg.RemoveOutput(os.Stdout)
+		lg.AddOutput(tlo, lg.JSON(),
+			lg.TimeLayout(lg.TimeRFC3339Nano),
+			lg.TimeLocation(time.FixedZone("AEST", 10*60*60)),
+		)
+
+		when := time.Date(2017, 3, 4, 5, 6, 7, 8, time.UTC)
+		lg.Info("hello", lg.F{"when", when})
+
+		Expect(tlo.String()).To(MatchRegexp(
+			`^\{"t":"[0-9-]{10}T[0-9:.]+\+10:00",.*` +
+				`"when":"2017-03-04T15:06:07.000000008\+10:00"`))
+	})
+
+	It("renders timestamps as Unix milliseconds", func() {
+		tlo = &TestLogOutput{}
+		lg.RemoveOutput(os.Stdout)
+		lg.AddOutput(tlo, lg.JSON(), lg.TimeLayout(lg.TimeUnixMilli))
+
+		when := time.Date(2017, 3, 4, 5, 6, 7, 8e6, time.UTC)
+		lg.Info("hello", lg.F{"when", when})
+
+		Expect(tlo.String()).To(MatchRegexp(
+			`^\{"t":[0-9]{13},.*"when":1488603967008`))
+	})
+
+	It("renders plain text timestamps in the given layout", func() {
+		tlo = &TestLogOutput{}
+		lg.RemoveOutput(os.Stdout)
+		lg.AddOutput(tlo, lg.TimeLayout(lg.TimeUnix))
+
+		lg.Info("hello")
+
+		Expect(tlo.String()).To(MatchRegexp(`^[0-9]{10} info  hello\n$`))
+	})
+})
@@ -0,0 +1,568 @@
+package lg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errNotEntry = errors.New("not an lg entry")
+
+	// plainTextEntry matches an entry in the plain text format: its time,
+	// level, and everything after them
+	plainTextEntry = regexp.MustCompile(
+		`^(\S+) (trace|debug|info |warn |error|fatal) (.*)$`)
+
+	// parseTimeLayouts are the layouts of the times that the parsers
+	// understand, in the order they're tried
+	parseTimeLayouts = []string{
+		TimeFormat,
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.999999999",
+	}
+)
+
+// ParseError is returned by a Parser for a line which isn't an entry in its
+// format. Parsing can carry on with the next line.
+type ParseError struct {
+	// Line is the number of the line, from 1
+	Line int
+
+	// Text is the line itself, without its trailing newline
+	Text string
+
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+// Parser reads entries written by lg, one per line, back into Entry values.
+//
+// Example:
+//
+//   p := lg.ParseJSON(os.Stdin)
+//   for {
+//     entry, err := p.Next()
+//     if err == io.EOF {
+//       break
+//     }
+//     if _, ok := err.(*lg.ParseError); ok {
+//       continue
+//     }
+//     ...
+//   }
+type Parser struct {
+	r     *bufio.Reader
+	line  int
+	parse func(line []byte) (*Entry, error)
+}
+
+// ParseJSON returns a parser for entries written in the JSON format, with
+// the default schema. Keys other than the time, level, prefix, message and
+// fields, e.g. fields written by a schema with FlattenFields, are read as
+// fields. Numbers are read as int64, or float64 if they aren't integers, and
+// strings in one of the layouts lg writes times in are read as times.
+func ParseJSON(r io.Reader) *Parser {
+	return &Parser{
+		r:     bufio.NewReader(r),
+		parse: parseJSONEntry,
+	}
+}
+
+// ParsePlainText returns a parser for entries written in the plain text
+// format, with the default layout. Field values are read as the types they
+// were written as where it's possible to tell, i.e. strings, int64s,
+// float64s, bools, times, nil, and slices and maps of these. The err field is
+// written after the message, following ": ", so it's read from after the
+// first ": " in the message of an entry with fields. A message which contains
+// ": " itself will be split there too.
+func ParsePlainText(r io.Reader) *Parser {
+	return &Parser{
+		r:     bufio.NewReader(r),
+		parse: parsePlainTextEntry,
+	}
+}
+
+// Parse returns a parser for entries written in either the JSON or the
+// plain text format, which is decided line by line, e.g. for logs from
+// several processes that have been mixed together
+func Parse(r io.Reader) *Parser {
+	return &Parser{
+		r:     bufio.NewReader(r),
+		parse: parseAnyEntry,
+	}
+}
+
+// Next returns the next entry, or a *ParseError if the next line isn't an
+// entry. It returns io.EOF if there are no more lines.
+func (p *Parser) Next() (*Entry, error) {
+	line, err := p.r.ReadBytes('\n')
+	if len(line) == 0 && err != nil {
+		return nil, err
+	}
+	p.line++
+
+	line = bytes.TrimRight(line, "\r\n")
+
+	entry, perr := p.parse(line)
+	if perr != nil {
+		return nil, &ParseError{Line: p.line, Text: string(line), Err: perr}
+	}
+	return entry, nil
+}
+
+// parseTime parses a time written in one of the layouts that the parsers
+// understand, or as a number of seconds, milliseconds, microseconds or
+// nanoseconds since the Unix epoch, which is told from its number of digits
+func parseTime(s string) (time.Time, error) {
+	if t, ok := parseLayoutTime(s); ok {
+		return t, nil
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", s)
+	}
+
+	switch digits := len(strings.TrimPrefix(s, "-")); {
+	case digits <= 10:
+		return time.Unix(n, 0).UTC(), nil
+	case digits <= 13:
+		return time.Unix(0, n*int64(time.Millisecond)).UTC(), nil
+	case digits <= 16:
+		return time.Unix(0, n*int64(time.Microsecond)).UTC(), nil
+	default:
+		return time.Unix(0, n).UTC(), nil
+	}
+}
+
+// parseLayoutTime parses a time written in one of the layouts that the
+// parsers understand, but not as a Unix time, which can't be told apart from
+// a number
+func parseLayoutTime(s string) (time.Time, bool) {
+	for _, layout := range parseTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// UnmarshalJSON reads fields from a JSON object, keeping them in their order
+func (f *Fields) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	if err := expectJSONDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	*f = Fields{}
+	for dec.More() {
+		key, val, err := readJSONPair(dec)
+		if err != nil {
+			return err
+		}
+		f.set(F{key, val})
+	}
+
+	return expectJSONDelim(dec, '}')
+}
+
+func expectJSONDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if tok != delim {
+		return fmt.Errorf("expected %v, not %v", delim, tok)
+	}
+	return nil
+}
+
+// readJSONPair reads the next key and value of an object
+func readJSONPair(dec *json.Decoder) (string, interface{}, error) {
+	key, err := readJSONKey(dec)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var val interface{}
+	if err = dec.Decode(&val); err != nil {
+		return "", nil, err
+	}
+
+	return key, typedJSONValue(val), nil
+}
+
+func readJSONKey(dec *json.Decoder) (string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+
+	key, ok := tok.(string)
+	if !ok {
+		return "", fmt.Errorf("expected a key, not %v", tok)
+	}
+	return key, nil
+}
+
+// typedJSONValue converts the json.Numbers in a value into int64s, or
+// float64s if they aren't integers, and the strings which are times into
+// times
+func typedJSONValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case string:
+		if t, ok := parseLayoutTime(v); ok {
+			return t
+		}
+
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+
+	case []interface{}:
+		for i := range v {
+			v[i] = typedJSONValue(v[i])
+		}
+
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = typedJSONValue(v[key])
+		}
+	}
+	return val
+}
+
+func parseAnyEntry(line []byte) (*Entry, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(line), []byte("{")) {
+		return parseJSONEntry(line)
+	}
+	return parsePlainTextEntry(line)
+}
+
+func parseJSONEntry(line []byte) (*Entry, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(line), []byte("{")) {
+		return nil, errNotEntry
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+
+	if err := expectJSONDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	schema := &defaultJSONSchema
+	entry := &Entry{}
+	var hasTime, hasLevel, hasMessage bool
+	var extra []F
+
+	for dec.More() {
+		key, err := readJSONKey(dec)
+		if err != nil {
+			return nil, err
+		}
+
+		if key == schema.FieldsKey {
+			var raw json.RawMessage
+			if err = dec.Decode(&raw); err != nil {
+				return nil, err
+			}
+			if err = entry.Fields.UnmarshalJSON(raw); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var val interface{}
+		if err = dec.Decode(&val); err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case schema.TimeKey:
+			entry.Timestamp, err = parseJSONTime(typedJSONValue(val))
+			hasTime = true
+
+		case schema.LevelKey:
+			s, _ := val.(string)
+			entry.Level, err = ParseLevel(s)
+			hasLevel = true
+
+		case schema.PrefixKey:
+			entry.Prefix, _ = val.(string)
+
+		case schema.MessageKey:
+			entry.Message, _ = val.(string)
+			hasMessage = true
+
+		default:
+			extra = append(extra, F{key, typedJSONValue(val)})
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := expectJSONDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+
+	if !hasTime || !hasLevel || !hasMessage {
+		return nil, errNotEntry
+	}
+
+	for _, fld := range extra {
+		entry.Fields.set(fld)
+	}
+
+	return entry, nil
+}
+
+// parseJSONTime parses a time written as a string, or as a number by one of
+// the Unix time layouts
+func parseJSONTime(val interface{}) (time.Time, error) {
+	switch v := val.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return parseTime(v)
+	case int64:
+		return parseTime(strconv.FormatInt(v, 10))
+	case float64:
+		secs, frac := math.Modf(v)
+		return time.Unix(int64(secs), int64(frac*1e9)).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %v", val)
+}
+
+func parsePlainTextEntry(line []byte) (*Entry, error) {
+	match := plainTextEntry.FindSubmatch(line)
+	if match == nil {
+		return nil, errNotEntry
+	}
+
+	ts, err := parseTime(string(match[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	level, _ := ParseLevel(strings.TrimSpace(string(match[2])))
+
+	entry := &Entry{
+		Timestamp: ts,
+		Level:     level,
+	}
+
+	rest := string(match[3])
+	if strings.HasPrefix(rest, "@") {
+		end := strings.IndexByte(rest, ' ')
+		if end < 0 {
+			return nil, errNotEntry
+		}
+		entry.Prefix, rest = rest[1:end], rest[end+1:]
+	}
+
+	// what looks like fields might just be the start of the message, if it
+	// doesn't parse
+	hasFields := false
+	if strings.HasPrefix(rest, "[") {
+		s := &textScanner{s: rest}
+		if fields, ok := s.fields(); ok && s.consume(' ') {
+			entry.Fields, rest = fields, s.s[s.pos:]
+			hasFields = true
+		}
+	}
+
+	// the err field is written after the message rather than with the other
+	// fields, which are always written when there is one
+	if i := strings.Index(rest, ": "); hasFields && i >= 0 {
+		entry.Fields.set(F{ErrKey, rest[i+2:]})
+		rest = rest[:i]
+	}
+
+	entry.Message = rest
+	return entry, nil
+}
+
+// textScanner reads field values written by the text encoder
+type textScanner struct {
+	s   string
+	pos int
+}
+
+func (s *textScanner) peek() byte {
+	if s.pos >= len(s.s) {
+		return 0
+	}
+	return s.s[s.pos]
+}
+
+// consume skips c, if it's next
+func (s *textScanner) consume(c byte) bool {
+	if s.peek() == c {
+		s.pos++
+		return true
+	}
+	return false
+}
+
+func (s *textScanner) skipSpaces() {
+	for s.consume(' ') {
+	}
+}
+
+// fields reads the bracketed list of key:value pairs that the fields of an
+// entry are written as
+func (s *textScanner) fields() (fields Fields, ok bool) {
+	if !s.consume('[') {
+		return fields, false
+	}
+
+	for {
+		s.skipSpaces()
+		if s.consume(']') {
+			return fields, true
+		}
+
+		key, val, ok := s.pair()
+		if !ok {
+			return fields, false
+		}
+		fields.set(F{key, val})
+	}
+}
+
+// pair reads a key:value pair
+func (s *textScanner) pair() (string, interface{}, bool) {
+	end := strings.IndexByte(s.s[s.pos:], ':')
+	if end <= 0 {
+		return "", nil, false
+	}
+
+	key := s.s[s.pos : s.pos+end]
+	if strings.ContainsAny(key, " []{}\"") {
+		return "", nil, false
+	}
+	s.pos += end + 1
+
+	val, ok := s.value()
+	return key, val, ok
+}
+
+func (s *textScanner) value() (interface{}, bool) {
+	switch s.peek() {
+	case '"':
+		return s.quoted()
+
+	case '[':
+		s.pos++
+		arr := []interface{}{}
+		for {
+			s.skipSpaces()
+			if s.consume(']') {
+				return arr, true
+			}
+
+			val, ok := s.value()
+			if !ok {
+				return nil, false
+			}
+			arr = append(arr, val)
+		}
+
+	case '{':
+		s.pos++
+		obj := map[string]interface{}{}
+		for {
+			s.skipSpaces()
+			if s.consume('}') {
+				return obj, true
+			}
+
+			key, val, ok := s.pair()
+			if !ok {
+				return nil, false
+			}
+			obj[key] = val
+		}
+
+	default:
+		return s.bare()
+	}
+}
+
+// quoted reads a string quoted by strconv.Quote
+func (s *textScanner) quoted() (interface{}, bool) {
+	for end := s.pos + 1; end < len(s.s); end++ {
+		switch s.s[end] {
+		case '\\':
+			end++
+		case '"':
+			str, err := strconv.Unquote(s.s[s.pos : end+1])
+			if err != nil {
+				return nil, false
+			}
+			s.pos = end + 1
+			return str, true
+		}
+	}
+	return nil, false
+}
+
+// bare reads a value which isn't quoted or bracketed, i.e. a number, bool,
+// null or time, or anything rendered by a custom encoder, which is read as a
+// string
+func (s *textScanner) bare() (interface{}, bool) {
+	end := s.pos
+	for end < len(s.s) && !strings.ContainsRune(" ]}", rune(s.s[end])) {
+		end++
+	}
+	if end == s.pos {
+		return nil, false
+	}
+
+	token := s.s[s.pos:end]
+	s.pos = end
+
+	switch token {
+	case "null":
+		return nil, true
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+
+	if i, err := strconv.ParseInt(token, 10, 64); err == nil {
+		return i, true
+	}
+
+	if f, err := strconv.ParseFloat(token, 64); err == nil {
+		return f, true
+	}
+
+	if t, ok := parseLayoutTime(token); ok {
+		return t, true
+	}
+
+	return token, true
+}
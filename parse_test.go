@@ -0,0 +1,257 @@
+package lg_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/autopilothq/lg"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// parseAll returns the entries that p reads, and the lines that it couldn't
+func parseAll(p *lg.Parser) (entries []*lg.Entry, skipped []string) {
+	for {
+		entry, err := p.Next()
+		if err == io.EOF {
+			return entries, skipped
+		}
+
+		if perr, ok := err.(*lg.ParseError); ok {
+			skipped = append(skipped, perr.Text)
+			continue
+		}
+
+		Expect(err).NotTo(HaveOccurred())
+		entries = append(entries, entry)
+	}
+}
+
+var _ = Describe("parsing", func() {
+
+	var tlo *TestLogOutput
+
+	BeforeEach(func() {
+		tlo = &TestLogOutput{}
+		lg.RemoveOutput(os.Stdout)
+	})
+
+	AfterEach(func() {
+		lg.RemoveOutput(tlo)
+		lg.AddOutput(os.Stdout)
+	})
+
+	logEntries := func() {
+		lg.ExtendWithPrefix("Server").Warn("careful now",
+			lg.F{"user", "bob \"the builder\""},
+			lg.F{"id", 42},
+			lg.F{"ratio", 0.5},
+			lg.F{"ok", false},
+			lg.F{"tags", []string{"a", "b c"}},
+			lg.F{"req", map[string]interface{}{"path": "/", "status": 500}},
+			lg.F{"at", time.Date(2017, 9, 15, 12, 30, 0, 0, time.UTC)},
+		)
+		lg.Info("second")
+	}
+
+	Describe("ParseJSON()", func() {
+		It("reads entries back", func() {
+			lg.AddOutput(tlo, lg.JSON())
+			before := time.Now().Truncate(time.Millisecond)
+			logEntries()
+
+			entries, skipped := parseAll(lg.ParseJSON(strings.NewReader(tlo.String())))
+			Expect(skipped).To(BeEmpty())
+			Expect(entries).To(HaveLen(2))
+
+			entry := entries[0]
+			Expect(entry.Timestamp).To(BeTemporally("~", before, time.Second))
+			Expect(entry.Level).To(Equal(lg.LevelWarn))
+			Expect(entry.Prefix).To(Equal("Server"))
+			Expect(entry.Message).To(Equal("careful now"))
+			Expect(entry.Fields.All()).To(Equal([]lg.F{
+				{"user", "bob \"the builder\""},
+				{"id", int64(42)},
+				{"ratio", 0.5},
+				{"ok", false},
+				{"tags", []interface{}{"a", "b c"}},
+				{"req", map[string]interface{}{"path": "/", "status": int64(500)}},
+				{"at", time.Date(2017, 9, 15, 12, 30, 0, 0, time.UTC)},
+			}))
+
+			Expect(entries[1].Message).To(Equal("second"))
+			Expect(entries[1].Fields.Len()).To(Equal(0))
+		})
+
+		It("reads flattened fields and numeric times", func() {
+			lg.AddOutput(tlo, lg.TimeLayout(lg.TimeUnixMilli), lg.Schema(lg.JSONSchema{
+				FlattenFields: true,
+			}))
+			lg.Info("flat", lg.F{"a", 1}, lg.F{"b", "two"})
+
+			entries, _ := parseAll(lg.ParseJSON(strings.NewReader(tlo.String())))
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Timestamp).To(BeTemporally("~", time.Now(), time.Second))
+			Expect(entries[0].Fields.All()).To(Equal([]lg.F{{"a", int64(1)}, {"b", "two"}}))
+		})
+
+		It("reports lines which aren't entries, and carries on", func() {
+			p := lg.ParseJSON(strings.NewReader(
+				"starting up\n" +
+					`{"level":"info"}` + "\n" +
+					`{"t":"2017-09-15T12:30:00.000","l":"info","m":"hi"}` + "\r\n" +
+					`{"t":"2017-09-15T12:30:00.000","l":"info",` + "\n"))
+
+			_, err := p.Next()
+			Expect(err).To(BeAssignableToTypeOf(&lg.ParseError{}))
+			Expect(err.(*lg.ParseError).Line).To(Equal(1))
+			Expect(err.(*lg.ParseError).Text).To(Equal("starting up"))
+
+			_, err = p.Next()
+			Expect(err).To(MatchError("line 2: not an lg entry"))
+
+			entry, err := p.Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entry.Message).To(Equal("hi"))
+			Expect(entry.Timestamp).To(Equal(time.Date(2017, 9, 15, 12, 30, 0, 0, time.UTC)))
+
+			_, err = p.Next()
+			Expect(err).To(BeAssignableToTypeOf(&lg.ParseError{}))
+
+			_, err = p.Next()
+			Expect(err).To(Equal(io.EOF))
+		})
+	})
+
+	Describe("ParsePlainText()", func() {
+		It("reads entries back", func() {
+			lg.AddOutput(tlo)
+			before := time.Now().Truncate(time.Millisecond)
+			logEntries()
+
+			entries, skipped := parseAll(lg.ParsePlainText(strings.NewReader(tlo.String())))
+			Expect(skipped).To(BeEmpty())
+			Expect(entries).To(HaveLen(2))
+
+			entry := entries[0]
+			Expect(entry.Timestamp).To(BeTemporally("~", before, time.Second))
+			Expect(entry.Level).To(Equal(lg.LevelWarn))
+			Expect(entry.Prefix).To(Equal("Server"))
+			Expect(entry.Message).To(Equal("careful now"))
+			Expect(entry.Fields.All()).To(Equal([]lg.F{
+				{"user", "bob \"the builder\""},
+				{"id", int64(42)},
+				{"ratio", 0.5},
+				{"ok", false},
+				{"tags", []interface{}{"a", "b c"}},
+				{"req", map[string]interface{}{"path": "/", "status": int64(500)}},
+				{"at", time.Date(2017, 9, 15, 12, 30, 0, 0, time.UTC)},
+			}))
+
+			Expect(entries[1].Level).To(Equal(lg.LevelInfo))
+			Expect(entries[1].Prefix).To(BeEmpty())
+			Expect(entries[1].Message).To(Equal("second"))
+		})
+
+		It("reads the err field from the end of the message", func() {
+			lg.AddOutput(tlo)
+			lg.Error("failed", lg.Err(os.ErrNotExist))
+			lg.Error("failed again", lg.F{"id", 1}, lg.Err(errors.New("open x: boom")))
+			lg.Info("note: no fields")
+
+			entries, _ := parseAll(lg.ParsePlainText(strings.NewReader(tlo.String())))
+			Expect(entries[0].Message).To(Equal("failed"))
+			Expect(entries[0].Fields.All()).To(Equal([]lg.F{{"err", "file does not exist"}}))
+			Expect(entries[1].Message).To(Equal("failed again"))
+			Expect(entries[1].Fields.All()).To(Equal([]lg.F{{"id", int64(1)}, {"err", "open x: boom"}}))
+			Expect(entries[2].Message).To(Equal("note: no fields"))
+			Expect(entries[2].Fields.Len()).To(Equal(0))
+		})
+
+		It("reads messages which only look like fields as messages", func() {
+			entries, _ := parseAll(lg.ParsePlainText(strings.NewReader(
+				"2017-09-15T12:30:00.000 info  [not fields] here\n" +
+					"2017-09-15T12:30:00.000 debug @Db [n:1] \n")))
+
+			Expect(entries).To(HaveLen(2))
+			Expect(entries[0].Message).To(Equal("[not fields] here"))
+			Expect(entries[0].Fields.Len()).To(Equal(0))
+
+			Expect(entries[1].Prefix).To(Equal("Db"))
+			Expect(entries[1].Message).To(BeEmpty())
+			Expect(entries[1].Fields.All()).To(Equal([]lg.F{{"n", int64(1)}}))
+		})
+
+		It("reports lines which aren't entries", func() {
+			_, skipped := parseAll(lg.ParsePlainText(strings.NewReader(
+				"panic: oh no\n\n2017-09-15 info  spaced out\n")))
+			Expect(skipped).To(Equal([]string{"panic: oh no", "", "2017-09-15 info  spaced out"}))
+		})
+	})
+
+	Describe("Parse()", func() {
+		It("reads JSON and plain text entries mixed together", func() {
+			entries, skipped := parseAll(lg.Parse(strings.NewReader(
+				`{"t":"2017-09-15T12:30:00.000","l":"info","m":"from json"}` + "\n" +
+					"2017-09-15T12:30:01.000 warn  [n:1] from text\n" +
+					"{not json\n")))
+
+			Expect(entries).To(HaveLen(2))
+			Expect(entries[0].Message).To(Equal("from json"))
+			Expect(entries[1].Message).To(Equal("from text"))
+			Expect(entries[1].Level).To(Equal(lg.LevelWarn))
+			Expect(skipped).To(Equal([]string{"{not json"}))
+		})
+	})
+
+	Describe("Formatter()", func() {
+		It("renders parsed entries in another format", func() {
+			entries, _ := parseAll(lg.ParseJSON(strings.NewReader(
+				`{"t":"2017-09-15T12:30:00.000","l":"info","p":"Db","f":{"n":1},"m":"hi"}` + "\n")))
+
+			render := lg.Formatter(tlo, lg.Console(), lg.TimeLayout(lg.TimeFormat))
+			Expect(string(render(entries[0]))).To(Equal(
+				"2017-09-15T12:30:00.000 info  @Db hi n=1\n"))
+
+			render = lg.Formatter(tlo, lg.Logfmt())
+			Expect(string(render(entries[0]))).To(Equal(
+				"ts=2017-09-15T12:30:00.000 level=info prefix=Db msg=hi n=1\n"))
+		})
+	})
+
+	Describe("Level", func() {
+		It("round trips through JSON", func() {
+			var level lg.Level
+			Expect(json.Unmarshal([]byte(`"warn"`), &level)).To(Succeed())
+			Expect(level).To(Equal(lg.LevelWarn))
+
+			Expect(json.Unmarshal([]byte(`"loud"`), &level)).NotTo(Succeed())
+		})
+	})
+
+	Describe("Fields", func() {
+		It("unmarshals from JSON in order", func() {
+			var entry lg.Entry
+			Expect(json.Unmarshal(
+				[]byte(`{"m":"hi","l":"error","f":{"z":1,"a":[1.5,"x"]}}`), &entry)).To(Succeed())
+
+			Expect(entry.Level).To(Equal(lg.LevelError))
+			Expect(entry.Fields.All()).To(Equal([]lg.F{
+				{"z", int64(1)},
+				{"a", []interface{}{1.5, "x"}},
+			}))
+
+			val, ok := entry.Fields.Get("z")
+			Expect(ok).To(BeTrue())
+			Expect(val).To(Equal(int64(1)))
+
+			_, ok = entry.Fields.Get("missing")
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
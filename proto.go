@@ -0,0 +1,67 @@
+package lg
+
+import (
+	"encoding/binary"
+)
+
+// Protocol buffer wire types
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+// appendUvarint appends val as a varint
+func appendUvarint(b []byte, val uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], val)
+	return append(b, buf[:n]...)
+}
+
+// appendFixed64 appends val as 8 little endian bytes
+func appendFixed64(b []byte, val uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], val)
+	return append(b, buf[:]...)
+}
+
+// appendProtoTag appends the key of a field with the given number and wire
+// type
+func appendProtoTag(b []byte, field int, wireType int) []byte {
+	return appendUvarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+// appendProtoVarint appends a varint field, unless it's zero
+func appendProtoVarint(b []byte, field int, val uint64) []byte {
+	if val == 0 {
+		return b
+	}
+	b = appendProtoTag(b, field, protoVarint)
+	return appendUvarint(b, val)
+}
+
+// appendProtoFixed64 appends a fixed64 field, unless it's zero
+func appendProtoFixed64(b []byte, field int, val uint64) []byte {
+	if val == 0 {
+		return b
+	}
+	b = appendProtoTag(b, field, protoFixed64)
+	return appendFixed64(b, val)
+}
+
+// appendProtoBytes appends a length delimited field, e.g. an embedded message
+func appendProtoBytes(b []byte, field int, val []byte) []byte {
+	b = appendProtoTag(b, field, protoBytes)
+	b = appendUvarint(b, uint64(len(val)))
+	return append(b, val...)
+}
+
+// appendProtoString appends a string field, unless it's empty
+func appendProtoString(b []byte, field int, val string) []byte {
+	if val == "" {
+		return b
+	}
+	b = appendProtoTag(b, field, protoBytes)
+	b = appendUvarint(b, uint64(len(val)))
+	return append(b, val...)
+}
@@ -0,0 +1,476 @@
+package lg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSpoolFull is returned when an entry is dropped because a spool has
+// reached its maximum size
+var ErrSpoolFull = errors.New("lg: spool is full")
+
+const (
+	spoolSegmentExt = ".seg"
+	spoolCursorName = "cursor"
+)
+
+// wrapSegment wraps the files that entries are spooled to
+var wrapSegment = func(f *os.File) io.WriteCloser { return f }
+
+// SpoolOptions configures a SpoolWriter
+type SpoolOptions struct {
+	maxSize     int64
+	segmentSize int64
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+}
+
+// SpoolMaxSize sets how many bytes of undelivered entries the spool holds on
+// disk. Entries that don't fit are dropped. Defaults to 1GB.
+func SpoolMaxSize(size int64) func(*SpoolOptions) {
+	return func(o *SpoolOptions) {
+		o.maxSize = size
+	}
+}
+
+// SpoolSegmentSize sets the size of the segment files that entries are
+// spooled to. Segments are removed once all of their entries have been
+// delivered. Defaults to 16MB, and is limited to the maximum size.
+func SpoolSegmentSize(size int64) func(*SpoolOptions) {
+	return func(o *SpoolOptions) {
+		o.segmentSize = size
+	}
+}
+
+// SpoolBackoff sets the delay before retrying the destination after it
+// fails, which starts at min and doubles with each failure up to max.
+// Defaults to 100ms and 30s.
+func SpoolBackoff(min, max time.Duration) func(*SpoolOptions) {
+	return func(o *SpoolOptions) {
+		o.maxBackoff = max
+		o.minBackoff = min
+	}
+}
+
+// SpoolWriter persists entries to segment files in a directory, and delivers
+// them in order to another writer in the background. Entries which the
+// destination fails to write are retried, with exponential backoff, until it
+// recovers, and entries which haven't been delivered when the process exits
+// are delivered by the next SpoolWriter to use the directory.
+//
+// Delivery is at least once: the position of the last delivered entry is
+// recorded after every write, but entries may be repeated after a crash.
+//
+// Example:
+//
+//   w := lg.NetworkOutput("tcp", "collector:5170", lg.NetworkBufferSize(64<<10))
+//   s, err := lg.SpoolOutput("/var/spool/app", w)
+//   if err != nil {
+//     panic(err)
+//   }
+//   lg.AddOutput(s, lg.JSON())
+type SpoolWriter struct {
+	dir     string
+	dest    io.Writer
+	options SpoolOptions
+
+	mutex       sync.Mutex
+	segment     io.WriteCloser
+	segmentID   uint64
+	segmentSize int64
+	closed      bool
+
+	// totalSize is the number of bytes of undelivered entries
+	totalSize int64
+
+	dropped uint64
+	cursor  *os.File
+
+	pending chan struct{}
+	closing chan struct{}
+	done    chan struct{}
+}
+
+// SpoolOutput returns a writer which spools entries to dir, creating it if
+// needed, and delivers them to dest. Any entries left in dir by a previous
+// SpoolWriter are delivered first.
+func SpoolOutput(
+	dir string, dest io.Writer, opts ...func(*SpoolOptions),
+) (*SpoolWriter, error) {
+	w := &SpoolWriter{
+		dir:  dir,
+		dest: dest,
+		options: SpoolOptions{
+			maxSize:     1 << 30,
+			segmentSize: 16 << 20,
+			minBackoff:  100 * time.Millisecond,
+			maxBackoff:  30 * time.Second,
+		},
+		pending: make(chan struct{}, 1),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(&w.options)
+	}
+
+	if w.options.segmentSize > w.options.maxSize {
+		w.options.segmentSize = w.options.maxSize
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	segments, err := w.segments()
+	if err != nil {
+		return nil, err
+	}
+
+	w.cursor, err = os.OpenFile(
+		filepath.Join(dir, spoolCursorName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	readID, readOffset, err := w.readCursor()
+	if err != nil {
+		w.cursor.Close()
+		return nil, err
+	}
+
+	// remove any segments which were delivered before the cursor was moved on
+	// from them, and start from the oldest segment if the cursor doesn't point
+	// at one
+	for len(segments) > 0 && segments[0].id < readID {
+		os.Remove(w.segmentPath(segments[0].id))
+		segments = segments[1:]
+	}
+	if len(segments) == 0 || segments[0].id != readID {
+		readOffset = 0
+		if len(segments) > 0 {
+			readID = segments[0].id
+		}
+	}
+
+	for _, s := range segments {
+		w.totalSize += s.size
+		if s.id >= w.segmentID {
+			w.segmentID = s.id + 1
+		}
+	}
+	w.totalSize -= readOffset
+	if len(segments) == 0 {
+		// nothing is spooled, so start with the first segment the cursor hasn't
+		// moved past
+		if readID > w.segmentID {
+			w.segmentID = readID
+		}
+		readID = w.segmentID
+	}
+
+	// entries are never appended to a segment from a previous run, as it may
+	// end with a partially written entry
+	if err = w.openSegment(w.segmentID); err != nil {
+		w.cursor.Close()
+		return nil, err
+	}
+
+	go w.run(readID, readOffset)
+
+	return w, nil
+}
+
+type spoolSegment struct {
+	id   uint64
+	size int64
+}
+
+// segments returns the segments in the spool directory, oldest first
+func (w *SpoolWriter) segments() ([]spoolSegment, error) {
+	infos, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []spoolSegment
+	for _, info := range infos {
+		name := info.Name()
+		if !strings.HasSuffix(name, spoolSegmentExt) {
+			continue
+		}
+
+		id, err := strconv.ParseUint(
+			strings.TrimSuffix(name, spoolSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, spoolSegment{id, info.Size()})
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].id < segments[j].id
+	})
+
+	return segments, nil
+}
+
+func (w *SpoolWriter) segmentPath(id uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%020d%s", id, spoolSegmentExt))
+}
+
+// readCursor returns the segment and offset of the next entry to deliver
+func (w *SpoolWriter) readCursor() (id uint64, offset int64, err error) {
+	var buf [16]byte
+	n, err := w.cursor.ReadAt(buf[:], 0)
+	if err == io.EOF && n < len(buf) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return binary.BigEndian.Uint64(buf[:8]),
+		int64(binary.BigEndian.Uint64(buf[8:])), nil
+}
+
+func (w *SpoolWriter) writeCursor(id uint64, offset int64) error {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], id)
+	binary.BigEndian.PutUint64(buf[8:], uint64(offset))
+	_, err := w.cursor.WriteAt(buf[:], 0)
+	return err
+}
+
+func (w *SpoolWriter) openSegment(id uint64) error {
+	f, err := os.OpenFile(
+		w.segmentPath(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	w.segment = wrapSegment(f)
+	w.segmentID = id
+	w.segmentSize = 0
+	return nil
+}
+
+// Write spools p as a single entry. It fails with ErrSpoolFull if the spool
+// has reached its maximum size.
+func (w *SpoolWriter) Write(p []byte) (int, error) {
+	record := make([]byte, 4+len(p))
+	binary.BigEndian.PutUint32(record, uint32(len(p)))
+	copy(record[4:], p)
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	if w.totalSize+int64(len(record)) > w.options.maxSize {
+		atomic.AddUint64(&w.dropped, 1)
+		return 0, ErrSpoolFull
+	}
+
+	if w.segmentSize > 0 &&
+		w.segmentSize+int64(len(record)) > w.options.segmentSize {
+		if err := w.nextSegment(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.segment.Write(record)
+	w.segmentSize += int64(n)
+	w.totalSize += int64(n)
+	if err != nil && n > 0 {
+		// nothing can follow a partly written entry, so later entries go in
+		// a new segment, and the reader skips the end of this one
+		w.nextSegment()
+	}
+
+	select {
+	case w.pending <- struct{}{}:
+	default:
+	}
+
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// nextSegment closes the current segment, and starts writing to the next one
+func (w *SpoolWriter) nextSegment() error {
+	if err := w.segment.Close(); err != nil {
+		return err
+	}
+	return w.openSegment(w.segmentID + 1)
+}
+
+// Dropped returns the number of entries that have been dropped because the
+// spool was full
+func (w *SpoolWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Close stops delivering entries and closes the spool. Entries which haven't
+// been delivered are kept on disk, for the next SpoolWriter to use the
+// directory. The destination isn't closed.
+func (w *SpoolWriter) Close() error {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mutex.Unlock()
+
+	close(w.closing)
+	<-w.done
+
+	err := w.segment.Close()
+	if cerr := w.cursor.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// isCurrent returns whether entries are being written to the segment id
+func (w *SpoolWriter) isCurrent(id uint64) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return id == w.segmentID
+}
+
+// delivered moves the cursor on to offset in the segment id, once the entry
+// before it has been delivered
+func (w *SpoolWriter) delivered(id uint64, offset int64, size int64) {
+	w.writeCursor(id, offset)
+
+	w.mutex.Lock()
+	w.totalSize -= size
+	w.mutex.Unlock()
+}
+
+// removeSegment removes a segment once all of its entries have been
+// delivered. Anything after offset, the end of the last complete entry, was
+// never delivered, and is no longer counted.
+func (w *SpoolWriter) removeSegment(id uint64, offset int64) {
+	path := w.segmentPath(id)
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	if os.Remove(path) == nil {
+		w.mutex.Lock()
+		w.totalSize -= info.Size() - offset
+		w.mutex.Unlock()
+	}
+}
+
+// readRecord reads the entry at offset in r, returning nil if there isn't a
+// complete entry there. Entries can't be larger than max, so a larger length
+// can only come from a corrupt segment.
+func readRecord(r io.ReaderAt, offset int64, max int64) []byte {
+	var header [4]byte
+	if _, err := r.ReadAt(header[:], offset); err != nil {
+		return nil
+	}
+
+	size := int64(binary.BigEndian.Uint32(header[:]))
+	if size > max {
+		return nil
+	}
+
+	record := make([]byte, size)
+	if _, err := r.ReadAt(record, offset+4); err != nil {
+		return nil
+	}
+	return record
+}
+
+// run delivers spooled entries, starting from the given position, until the
+// spool is closed
+func (w *SpoolWriter) run(id uint64, offset int64) {
+	defer close(w.done)
+
+	var segment *os.File
+	defer func() {
+		if segment != nil {
+			segment.Close()
+		}
+	}()
+
+	backoff := w.options.minBackoff
+	for {
+		if segment == nil {
+			var err error
+			if segment, err = os.Open(w.segmentPath(id)); err != nil {
+				// the segment can only be missing if it was removed from
+				// outside, so move on to the next one
+				if !w.isCurrent(id) {
+					id, offset = id+1, 0
+					continue
+				}
+				return
+			}
+		}
+
+		// check whether the segment is still being written before reading it,
+		// so that an incomplete entry at the end of an old segment, left by a
+		// crash, isn't waited on
+		current := w.isCurrent(id)
+
+		record := readRecord(segment, offset, w.options.maxSize)
+		if record == nil {
+			if !current {
+				segment.Close()
+				segment = nil
+				w.removeSegment(id, offset)
+				id, offset = id+1, 0
+				w.writeCursor(id, offset)
+				continue
+			}
+
+			select {
+			case <-w.pending:
+				continue
+			case <-w.closing:
+				return
+			}
+		}
+
+		if _, err := w.dest.Write(record); err != nil {
+			select {
+			case <-time.After(backoff):
+			case <-w.closing:
+				return
+			}
+
+			if backoff *= 2; backoff > w.options.maxBackoff {
+				backoff = w.options.maxBackoff
+			}
+			continue
+		}
+
+		backoff = w.options.minBackoff
+		size := 4 + int64(len(record))
+		offset += size
+		w.delivered(id, offset, size)
+	}
+}
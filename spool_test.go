@@ -0,0 +1,243 @@
+package lg_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/autopilothq/lg"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// destination records the entries written to it, and fails while it's down
+type destination struct {
+	mutex   sync.Mutex
+	down    bool
+	entries []string
+}
+
+func (d *destination) Write(p []byte) (int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.down {
+		return 0, errors.New("destination is down")
+	}
+	d.entries = append(d.entries, string(p))
+	return len(p), nil
+}
+
+func (d *destination) setDown(down bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.down = down
+}
+
+func (d *destination) Entries() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]string(nil), d.entries...)
+}
+
+// tornWriter writes only part of the entry given to it on its nth write
+type tornWriter struct {
+	io.WriteCloser
+	writes *int32
+	n      int32
+}
+
+func (t tornWriter) Write(p []byte) (int, error) {
+	if atomic.AddInt32(t.writes, 1) == t.n {
+		n, _ := t.WriteCloser.Write(p[:len(p)/2])
+		return n, errors.New("disk full")
+	}
+	return t.WriteCloser.Write(p)
+}
+
+var _ = Describe("Spool output", func() {
+
+	var (
+		dir  string
+		dest *destination
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "lg-spool")
+		Expect(err).NotTo(HaveOccurred())
+		dest = &destination{}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	segments := func() []string {
+		names, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+		Expect(err).NotTo(HaveOccurred())
+		return names
+	}
+
+	backoff := lg.SpoolBackoff(5*time.Millisecond, 20*time.Millisecond)
+
+	It("delivers entries in order", func() {
+		s, err := lg.SpoolOutput(dir, dest)
+		Expect(err).NotTo(HaveOccurred())
+		defer s.Close()
+
+		s.Write([]byte("one\n"))
+		s.Write([]byte("two\n"))
+		s.Write([]byte("three\n"))
+
+		Eventually(dest.Entries).Should(Equal([]string{"one\n", "two\n", "three\n"}))
+	})
+
+	It("retries entries until the destination recovers", func() {
+		dest.setDown(true)
+		s, err := lg.SpoolOutput(dir, dest, backoff)
+		Expect(err).NotTo(HaveOccurred())
+		defer s.Close()
+
+		s.Write([]byte("one\n"))
+		s.Write([]byte("two\n"))
+		Consistently(dest.Entries, 50*time.Millisecond).Should(BeEmpty())
+
+		dest.setDown(false)
+		Eventually(dest.Entries).Should(Equal([]string{"one\n", "two\n"}))
+	})
+
+	It("delivers entries spooled before a restart", func() {
+		s, err := lg.SpoolOutput(dir, dest, backoff)
+		Expect(err).NotTo(HaveOccurred())
+		s.Write([]byte("one\n"))
+		Eventually(dest.Entries).Should(HaveLen(1))
+
+		dest.setDown(true)
+		s.Write([]byte("two\n"))
+		s.Write([]byte("three\n"))
+		Expect(s.Close()).To(Succeed())
+
+		dest.setDown(false)
+		s, err = lg.SpoolOutput(dir, dest, backoff)
+		Expect(err).NotTo(HaveOccurred())
+		defer s.Close()
+
+		s.Write([]byte("four\n"))
+		Eventually(dest.Entries).Should(Equal(
+			[]string{"one\n", "two\n", "three\n", "four\n"}))
+	})
+
+	It("skips an incomplete entry left by a crash", func() {
+		s, err := lg.SpoolOutput(dir, dest)
+		Expect(err).NotTo(HaveOccurred())
+		dest.setDown(true)
+		s.Write([]byte("one\n"))
+		Expect(s.Close()).To(Succeed())
+
+		// append the start of an entry to the segment
+		f, err := os.OpenFile(segments()[0], os.O_WRONLY|os.O_APPEND, 0644)
+		Expect(err).NotTo(HaveOccurred())
+		f.Write([]byte{0, 0, 0, 10, 't', 'w'})
+		f.Close()
+
+		dest.setDown(false)
+		s, err = lg.SpoolOutput(dir, dest)
+		Expect(err).NotTo(HaveOccurred())
+		defer s.Close()
+
+		s.Write([]byte("three\n"))
+		Eventually(dest.Entries).Should(Equal([]string{"one\n", "three\n"}))
+	})
+
+	It("removes segments once their entries have been delivered", func() {
+		s, err := lg.SpoolOutput(dir, dest, lg.SpoolSegmentSize(20))
+		Expect(err).NotTo(HaveOccurred())
+		defer s.Close()
+
+		dest.setDown(true)
+		for i := 0; i < 5; i++ {
+			s.Write([]byte("entry\n"))
+		}
+		Expect(len(segments())).To(BeNumerically(">", 1))
+
+		dest.setDown(false)
+		Eventually(dest.Entries, 2*time.Second).Should(HaveLen(5))
+		Eventually(segments).Should(HaveLen(1))
+	})
+
+	It("drops entries once it is full", func() {
+		dest.setDown(true)
+		s, err := lg.SpoolOutput(dir, dest, lg.SpoolMaxSize(20))
+		Expect(err).NotTo(HaveOccurred())
+		defer s.Close()
+
+		_, err = s.Write([]byte("fits\n"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = s.Write([]byte("doesn't fit\n"))
+		Expect(err).To(Equal(lg.ErrSpoolFull))
+		Expect(s.Dropped()).To(Equal(uint64(1)))
+	})
+
+	It("accepts entries again once earlier ones have been delivered", func() {
+		s, err := lg.SpoolOutput(dir, dest, lg.SpoolMaxSize(100))
+		Expect(err).NotTo(HaveOccurred())
+		defer s.Close()
+
+		var expected []string
+		for i := 0; i < 50; i++ {
+			entry := fmt.Sprintf("entry %d\n", i)
+			_, err = s.Write([]byte(entry))
+			Expect(err).NotTo(HaveOccurred())
+
+			expected = append(expected, entry)
+			Eventually(dest.Entries).Should(Equal(expected))
+		}
+		Expect(s.Dropped()).To(BeZero())
+	})
+
+	It("delivers the entries after a partly written one", func() {
+		var writes int32
+		defer lg.SetSpoolSegmentWrapper(func(f *os.File) io.WriteCloser {
+			return tornWriter{f, &writes, 2}
+		})()
+
+		dest.setDown(true)
+		s, err := lg.SpoolOutput(dir, dest)
+		Expect(err).NotTo(HaveOccurred())
+		defer s.Close()
+
+		_, err = s.Write([]byte("one\n"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = s.Write([]byte("torn\n"))
+		Expect(err).To(MatchError("disk full"))
+		_, err = s.Write([]byte("three\n"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = s.Write([]byte("four\n"))
+		Expect(err).NotTo(HaveOccurred())
+
+		dest.setDown(false)
+		Eventually(dest.Entries).Should(Equal([]string{"one\n", "three\n", "four\n"}))
+	})
+
+	It("can be used as an output", func() {
+		s, err := lg.SpoolOutput(dir, dest)
+		Expect(err).NotTo(HaveOccurred())
+		defer s.Close()
+
+		lg.RemoveOutput(os.Stdout)
+		defer lg.AddOutput(os.Stdout)
+		lg.AddOutput(s, lg.JSON())
+		defer lg.RemoveOutput(s)
+		lg.Info("spooled")
+
+		Eventually(dest.Entries).Should(ConsistOf(ContainSubstring(`"m":"spooled"`)))
+	})
+})
@@ -0,0 +1,268 @@
+package lg
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/autopilothq/lg/encoding"
+	text "github.com/autopilothq/lg/encoding/text"
+)
+
+// SyslogFacility is the facility that syslog messages are logged with
+type SyslogFacility uint
+
+// Syslog facilities
+const (
+	FacilityKern SyslogFacility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLPR
+	FacilityNews
+	FacilityUUCP
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFTP
+	_
+	_
+	_
+	_
+	FacilityLocal0
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+type syslogProtocol uint
+
+const (
+	syslogRFC5424 syslogProtocol = iota
+	syslogRFC3164
+)
+
+// SyslogStructuredDataID is the SD-ID that fields are written under in
+// RFC 5424 messages, unless set with SyslogSDID. It's a placeholder: 32473 is
+// the private enterprise number reserved for documentation by RFC 5612, so
+// organisations with their own number should use it instead.
+const SyslogStructuredDataID = "lg@32473"
+
+const syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// syslogSeverity maps levels onto syslog severities
+func syslogSeverity(l Level) int {
+	switch l {
+	case LevelTrace, LevelDebug:
+		return 7
+	case LevelInfo:
+		return 6
+	case LevelWarn:
+		return 4
+	case LevelError:
+		return 3
+	case LevelFatal:
+		return 2
+	default:
+		return 6
+	}
+}
+
+// syslogFormatter renders entries as syslog messages
+type syslogFormatter struct {
+	protocol      syslogProtocol
+	facility      SyslogFacility
+	hostname      string
+	appName       string
+	procID        string
+	prefixAppName bool
+	sdID          string
+}
+
+func newSyslogFormatter(options *Options) *syslogFormatter {
+	s := &syslogFormatter{
+		protocol:      options.syslogProtocol,
+		facility:      options.syslogFacility,
+		hostname:      options.hostname,
+		appName:       options.serviceName,
+		procID:        strconv.Itoa(os.Getpid()),
+		prefixAppName: options.syslogPrefixAppName,
+		sdID:          options.syslogSDID,
+	}
+
+	if s.sdID == "" {
+		s.sdID = SyslogStructuredDataID
+	}
+
+	if s.hostname == "" {
+		s.hostname, _ = os.Hostname()
+	}
+
+	if s.appName == "" {
+		s.appName = filepath.Base(os.Args[0])
+	}
+
+	return s
+}
+
+// syslogHeaderField returns s as a header field of at most max printable
+// ASCII characters, or "-" if it's empty
+func syslogHeaderField(s string, max int) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(b) < max; i++ {
+		if s[i] >= 33 && s[i] <= 126 {
+			b = append(b, s[i])
+		}
+	}
+
+	if len(b) == 0 {
+		return "-"
+	}
+	return string(b)
+}
+
+func (s *syslogFormatter) format(e *Entry) []byte {
+	var out bytes.Buffer
+
+	out.WriteByte('<')
+	pri := int(s.facility)*8 + syslogSeverity(e.Level)
+	out.WriteString(strconv.Itoa(pri))
+	out.WriteByte('>')
+
+	appName, msgID := s.appName, e.Prefix
+	if s.prefixAppName && e.Prefix != "" {
+		appName, msgID = e.Prefix, ""
+	}
+
+	if s.protocol == syslogRFC3164 {
+		s.format3164(&out, e, appName, msgID)
+	} else {
+		s.format5424(&out, e, appName, msgID)
+	}
+
+	out.WriteByte('\n')
+	return out.Bytes()
+}
+
+// format5424 writes the rest of an RFC 5424 message, with fields as
+// structured data
+func (s *syslogFormatter) format5424(
+	out *bytes.Buffer, e *Entry, appName, msgID string,
+) {
+	out.WriteString("1 ")
+	out.WriteString(e.Timestamp.Format(syslogTimeFormat))
+	out.WriteByte(' ')
+	out.WriteString(syslogHeaderField(s.hostname, 255))
+	out.WriteByte(' ')
+	out.WriteString(syslogHeaderField(appName, 48))
+	out.WriteByte(' ')
+	out.WriteString(syslogHeaderField(s.procID, 128))
+	out.WriteByte(' ')
+	out.WriteString(syslogHeaderField(msgID, 32))
+	out.WriteByte(' ')
+
+	if e.Fields.Len() == 0 {
+		out.WriteByte('-')
+	} else {
+		out.WriteByte('[')
+		out.WriteString(s.sdID)
+		for _, fld := range e.Fields.contents {
+			out.WriteByte(' ')
+			out.WriteString(syslogParamName(fld.Key))
+			out.WriteString(`="`)
+			writeSyslogParamValue(out, renderRawValue(fld.Val))
+			out.WriteByte('"')
+		}
+		out.WriteByte(']')
+	}
+
+	if e.Message != "" {
+		out.WriteByte(' ')
+		out.WriteString(e.Message)
+	}
+}
+
+// format3164 writes the rest of an RFC 3164 message. It has no structured
+// data, so fields are written after the message as in the plain text format.
+func (s *syslogFormatter) format3164(
+	out *bytes.Buffer, e *Entry, appName, msgID string,
+) {
+	out.WriteString(e.Timestamp.Format("Jan _2 15:04:05"))
+	out.WriteByte(' ')
+	out.WriteString(syslogHeaderField(s.hostname, 255))
+	out.WriteByte(' ')
+	out.WriteString(syslogHeaderField(appName, 32))
+	out.WriteByte('[')
+	out.WriteString(s.procID)
+	out.WriteString("]: ")
+
+	if msgID != "" {
+		out.WriteString("@" + msgID + " ")
+	}
+
+	var errMsg string
+	if e.Fields.Len() > 0 {
+		enc := text.NewEncoder()
+		var err error
+		if err = enc.StartArray(); err == nil {
+			if errMsg, err = e.Fields.encodeText(enc); err == nil {
+				err = enc.EndArray()
+			}
+		}
+
+		if err != nil {
+			out.WriteString(err.Error())
+		} else if enc.String() != "[]" {
+			out.Write(enc.Bytes())
+			out.WriteByte(' ')
+		}
+	}
+
+	out.WriteString(e.Message)
+	if errMsg != "" {
+		out.WriteString(": " + errMsg)
+	}
+}
+
+// syslogParamName returns key as a valid SD-PARAM name
+func syslogParamName(key string) string {
+	name := syslogHeaderField(key, 32)
+	return strings.NewReplacer("=", "_", "]", "_", `"`, "_").Replace(name)
+}
+
+// writeSyslogParamValue writes an SD-PARAM value, escaping the characters
+// that must be escaped
+func writeSyslogParamValue(out *bytes.Buffer, val string) {
+	for i := 0; i < len(val); i++ {
+		switch val[i] {
+		case '"', '\\', ']':
+			out.WriteByte('\\')
+		}
+		out.WriteByte(val[i])
+	}
+}
+
+// renderRawValue renders a field value on its own, e.g. for structured data.
+// Strings and errors are written as they are, anything else with the text
+// encoder.
+func renderRawValue(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	}
+
+	enc := text.NewEncoder()
+	if err := encoding.EncodeValue(enc, val); err != nil {
+		return RenderMessage(val)
+	}
+	return enc.String()
+}
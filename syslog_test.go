@@ -0,0 +1,218 @@
+package lg_test
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/autopilothq/lg"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("syslog output", func() {
+
+	var tlo *TestLogOutput
+	pid := strconv.Itoa(os.Getpid())
+
+	BeforeEach(func() {
+		tlo = &TestLogOutput{}
+		lg.RemoveOutput(os.Stdout)
+	})
+
+	AfterEach(func() {
+		lg.RemoveOutput(tlo)
+		lg.AddOutput(os.Stdout)
+	})
+
+	It("renders entries as RFC 5424 messages", func() {
+		lg.AddOutput(tlo, lg.Syslog(), lg.Hostname("web1"),
+			lg.ServiceName("api"), lg.SyslogFacilityOf(lg.FacilityLocal0))
+
+		lg.ExtendWithPrefix("Server").Warn("careful",
+			lg.F{"id", 1}, lg.F{"path", `/a "b" [c]`})
+
+		Expect(tlo.String()).To(MatchRegexp(
+			`^<132>1 [0-9-]{10}T[0-9:]{8}\.[0-9]{6}Z web1 api ` + pid +
+				` Server \[lg@32473 id="1" path="/a \\"b\\" \[c\\]"\] careful\n$`))
+	})
+
+	It("writes fields under the given SD-ID", func() {
+		lg.AddOutput(tlo, lg.Syslog(), lg.SyslogSDID("app@12345"))
+		lg.Info("hello", lg.F{"id", 1})
+
+		Expect(tlo.String()).To(ContainSubstring(` [app@12345 id="1"] hello`))
+	})
+
+	It("writes a nil value for missing parts", func() {
+		lg.AddOutput(tlo, lg.Syslog(), lg.Hostname("web1"), lg.ServiceName("api"))
+		lg.Info("hello")
+
+		Expect(tlo.String()).To(MatchRegexp(
+			`^<14>1 \S+ web1 api ` + pid + ` - - hello\n$`))
+	})
+
+	It("can write the prefix as the APP-NAME", func() {
+		lg.AddOutput(tlo, lg.Syslog(), lg.Hostname("web1"),
+			lg.SyslogPrefixAsAppName())
+		lg.ExtendWithPrefix("Server").Error("failed")
+
+		Expect(tlo.String()).To(MatchRegexp(
+			`^<11>1 \S+ web1 Server ` + pid + ` - - failed\n$`))
+	})
+
+	It("renders entries as RFC 3164 messages", func() {
+		lg.AddOutput(tlo, lg.SyslogRFC3164(), lg.Hostname("web1"),
+			lg.ServiceName("api"))
+		lg.ExtendWithPrefix("Server").Info("hello",
+			lg.F{"id", 1}, lg.Err(errors.New("it broke")))
+
+		Expect(tlo.String()).To(MatchRegexp(
+			`^<14>[A-Z][a-z]{2} [ 0-9]{2} [0-9:]{8} web1 api\[` + pid +
+				`\]: @Server \[id:1\] hello: it broke\n$`))
+	})
+
+	Describe("SyslogWriter", func() {
+
+		It("sends messages over UDP", func() {
+			conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+
+			w, err := lg.NewSyslogWriter("udp", conn.LocalAddr().String())
+			Expect(err).NotTo(HaveOccurred())
+			defer w.Close()
+
+			_, err = w.Write([]byte("<14>1 - - - - - hi\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			buf := make([]byte, 1024)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			n, err := conn.Read(buf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(buf[:n])).To(Equal("<14>1 - - - - - hi"))
+		})
+
+		It("sends messages to unix datagram sockets", func() {
+			dir, err := ioutil.TempDir("", "lg-syslog")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "log")
+			conn, err := net.ListenUnixgram("unixgram",
+				&net.UnixAddr{Name: path, Net: "unixgram"})
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+
+			w, err := lg.NewSyslogWriter("unixgram", path)
+			Expect(err).NotTo(HaveOccurred())
+			defer w.Close()
+
+			_, err = w.Write([]byte("<14>1 - - - - - hi\n"))
+			Expect(err).NotTo(HaveOccurred())
+
+			buf := make([]byte, 1024)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			n, err := conn.Read(buf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(buf[:n])).To(Equal("<14>1 - - - - - hi"))
+		})
+
+		It("separates messages by new lines over a local stream socket it finds", func() {
+			dir, err := ioutil.TempDir("", "lg-syslog")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "log")
+			ln, err := net.Listen("unix", path)
+			Expect(err).NotTo(HaveOccurred())
+			defer ln.Close()
+
+			defer lg.SetSyslogSockets(path)()
+
+			received := make(chan string, 1)
+			go func() {
+				defer GinkgoRecover()
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+
+				r := bufio.NewReader(conn)
+				var lines []string
+				for i := 0; i < 2; i++ {
+					line, _ := r.ReadString('\n')
+					lines = append(lines, line)
+				}
+				received <- strings.Join(lines, "")
+			}()
+
+			w, err := lg.NewSyslogWriter("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer w.Close()
+
+			_, err = w.Write([]byte("<14>1 - - - - - one\n"))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = w.Write([]byte("<14>1 - - - - - two"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(received).Should(Receive(Equal(
+				"<14>1 - - - - - one\n<14>1 - - - - - two\n")))
+		})
+
+		It("frames messages over TCP, reconnecting if needed", func() {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer ln.Close()
+
+			received := make(chan string, 2)
+			go func() {
+				defer GinkgoRecover()
+				for i := 0; i < 2; i++ {
+					conn, err := ln.Accept()
+					if err != nil {
+						return
+					}
+
+					line, _ := bufio.NewReader(conn).ReadString('!')
+					received <- line
+					conn.Close()
+				}
+			}()
+
+			w, err := lg.NewSyslogWriter("tcp", ln.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			defer w.Close()
+
+			_, err = w.Write([]byte("first!\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(received).Should(Receive(Equal("6 first!")))
+
+			// the server has closed the connection; writes fail until the
+			// closure is noticed, after which the writer reconnects
+			Eventually(func() string {
+				w.Write([]byte("second!\n"))
+				select {
+				case line := <-received:
+					return line
+				case <-time.After(50 * time.Millisecond):
+					return ""
+				}
+			}, time.Second).Should(Equal("7 second!"))
+		})
+
+		It("fails if there is nothing listening", func() {
+			_, err := lg.NewSyslogWriter("unix", "/nonexistent/log")
+			Expect(err).To(HaveOccurred())
+			Expect(strings.Contains(err.Error(), "nonexistent")).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,132 @@
+package lg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// syslogSockets are the paths of the local syslog daemon's socket on
+// various systems
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// ErrSyslogUnavailable is returned when the local syslog daemon can't be
+// found
+var ErrSyslogUnavailable = errors.New("unable to connect to local syslog")
+
+// SyslogWriter delivers syslog messages to a syslog daemon. Each Write is one
+// message. Over TCP, messages are framed with octet counting; over other
+// networks each message is sent as it is. If sending fails the writer
+// reconnects and tries once more.
+//
+// Example:
+//
+//   w, err := lg.NewSyslogWriter("", "")
+//   if err != nil {
+//     panic(err)
+//   }
+//   lg.AddOutput(w, lg.Syslog(), lg.SyslogFacilityOf(lg.FacilityLocal0))
+type SyslogWriter struct {
+	network string
+	address string
+
+	mutex sync.Mutex
+	conn  net.Conn
+
+	// connected is the network of conn, which is decided by connect when
+	// the local daemon is found automatically
+	connected string
+}
+
+// NewSyslogWriter returns a writer which delivers syslog messages to address,
+// over network, which may be "udp", "tcp", "unix" or "unixgram". If both are
+// empty, it connects to the local syslog daemon, e.g. via /dev/log.
+func NewSyslogWriter(network, address string) (*SyslogWriter, error) {
+	w := &SyslogWriter{network: network, address: address}
+
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *SyslogWriter) connect() (err error) {
+	if w.network != "" || w.address != "" {
+		if w.conn, err = net.Dial(w.network, w.address); err == nil {
+			w.connected = w.network
+		}
+		return err
+	}
+
+	for _, path := range syslogSockets {
+		for _, network := range []string{"unixgram", "unix"} {
+			if conn, err := net.Dial(network, path); err == nil {
+				w.conn, w.connected = conn, network
+				return nil
+			}
+		}
+	}
+
+	return ErrSyslogUnavailable
+}
+
+// frame returns msg framed for the network that the writer is connected over
+func (w *SyslogWriter) frame(msg []byte) []byte {
+	switch w.connected {
+	case "tcp", "tcp4", "tcp6":
+		framed := strconv.AppendInt(nil, int64(len(msg)), 10)
+		framed = append(framed, ' ')
+		return append(framed, msg...)
+
+	case "unix":
+		// stream sockets to the local daemon separate messages by new lines.
+		// msg is copied, rather than appended to in place.
+		return append(msg[:len(msg):len(msg)], '\n')
+	}
+
+	return msg
+}
+
+// Write sends p as a single message. A trailing new line is removed.
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	msg := bytes.TrimRight(p, "\n")
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.conn != nil {
+		if _, err := w.conn.Write(w.frame(msg)); err == nil {
+			return len(p), nil
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	if err := w.connect(); err != nil {
+		return 0, fmt.Errorf("syslog: %s", err)
+	}
+
+	if _, err := w.conn.Write(w.frame(msg)); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close closes the connection to the syslog daemon
+func (w *SyslogWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
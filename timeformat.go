@@ -0,0 +1,105 @@
+package lg
+
+import (
+	"time"
+
+	"github.com/autopilothq/lg/encoding/buffer"
+	"github.com/autopilothq/lg/encoding/types"
+)
+
+// Special time layouts that can be passed to TimeLayout
+const (
+	// TimeRFC3339 renders times to the second, with a zone designator
+	TimeRFC3339 = time.RFC3339
+
+	// TimeRFC3339Milli renders times to the millisecond, with a zone
+	// designator
+	TimeRFC3339Milli = "2006-01-02T15:04:05.000Z07:00"
+
+	// TimeRFC3339Micro renders times to the microsecond, with a zone
+	// designator
+	TimeRFC3339Micro = "2006-01-02T15:04:05.000000Z07:00"
+
+	// TimeRFC3339Nano renders times to the nanosecond, with a zone designator.
+	// Trailing zeros are removed from the fractional seconds.
+	TimeRFC3339Nano = time.RFC3339Nano
+
+	// TimeUnix renders times as the number of seconds since the Unix epoch
+	TimeUnix = "unix"
+
+	// TimeUnixMilli renders times as the number of milliseconds since the
+	// Unix epoch
+	TimeUnixMilli = "unixmilli"
+
+	// TimeUnixMicro renders times as the number of microseconds since the
+	// Unix epoch
+	TimeUnixMicro = "unixmicro"
+
+	// TimeUnixNano renders times as the number of nanoseconds since the Unix
+	// epoch
+	TimeUnixNano = "unixnano"
+)
+
+var timeUnits = map[string]time.Duration{
+	TimeUnix:      time.Second,
+	TimeUnixMilli: time.Millisecond,
+	TimeUnixMicro: time.Microsecond,
+	TimeUnixNano:  time.Nanosecond,
+}
+
+func (o *Options) getTimeFormat() *types.TimeFormat {
+	if o.timeFormat == nil {
+		o.timeFormat = &types.TimeFormat{}
+	}
+	return o.timeFormat
+}
+
+// TimeLayout sets the format that an output renders entry timestamps and
+// time field values in. The layout is either a time.Format layout, or one of
+// TimeUnix, TimeUnixMilli, TimeUnixMicro or TimeUnixNano.
+//
+// Examples:
+//
+//   // RFC3339 with nanoseconds and a zone designator
+//   lg.AddOutput(os.Stdout, lg.JSON(), lg.TimeLayout(lg.TimeRFC3339Nano))
+//
+//   // milliseconds since the epoch
+//   lg.AddOutput(os.Stdout, lg.JSON(), lg.TimeLayout(lg.TimeUnixMilli))
+func TimeLayout(layout string) func(*Options) {
+	return func(o *Options) {
+		tf := o.getTimeFormat()
+		if unit, ok := timeUnits[layout]; ok {
+			tf.Unit = unit
+			tf.Layout = ""
+		} else {
+			tf.Unit = 0
+			tf.Layout = layout
+		}
+	}
+}
+
+// TimeLocation sets the location that an output renders entry timestamps and
+// time field values in; use time.Local for the local time zone. By default
+// timestamps are rendered in UTC, and time field values in their own zone.
+func TimeLocation(loc *time.Location) func(*Options) {
+	return func(o *Options) {
+		o.getTimeFormat().Location = loc
+	}
+}
+
+// LocalTime renders times in the local time zone
+func LocalTime() func(*Options) {
+	return TimeLocation(time.Local)
+}
+
+// formatTime renders t in the given format, or the default format if tf is
+// nil
+func formatTime(t time.Time, tf *types.TimeFormat) string {
+	if tf == nil {
+		return t.Format(TimeFormat)
+	}
+
+	buf := buffer.NewBuffer()
+	buf.AppendFormattedTime(t, tf)
+	return buf.String()
+}